@@ -0,0 +1,51 @@
+package common
+
+// Answer 应答部分的一条资源记录，常见类型解码为结构化数据
+type Answer struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  uint32 `json:"ttl"`
+	// 通用文本表示，如 A/AAAA 的地址、CNAME 的目标域名
+	Data string `json:"data,omitempty"`
+	// A/AAAA 地址反向解析得到的名称，仅在启用 -reverse-dns 时填充
+	PTR string `json:"ptr,omitempty"`
+
+	MX   *MXData   `json:"mx,omitempty"`
+	SOA  *SOAData  `json:"soa,omitempty"`
+	SVCB *SVCBData `json:"svcb,omitempty"` // SVCB 和 HTTPS 记录
+
+	// TXT 记录的各个字符串，以及识别出的用途（spf、dkim、dmarc 等）
+	TXT     []string `json:"txt,omitempty"`
+	TXTKind string   `json:"txtKind,omitempty"`
+}
+
+// MXData 邮件交换记录
+type MXData struct {
+	Preference uint16 `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+// SOAData 起始授权记录
+type SOAData struct {
+	MName   string `json:"mname"`
+	RName   string `json:"rname"`
+	Serial  uint32 `json:"serial"`
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	Minimum uint32 `json:"minimum"`
+}
+
+// SVCBData SVCB/HTTPS 记录（RFC 9460），SvcParams 按键解码，未识别的键忽略
+type SVCBData struct {
+	Priority uint16 `json:"priority"`
+	Target   string `json:"target"`
+	// 客户端必须支持的参数键名，如 alpn、port
+	Mandatory     []string `json:"mandatory,omitempty"`
+	ALPN          []string `json:"alpn,omitempty"`
+	NoDefaultALPN bool     `json:"noDefaultAlpn,omitempty"`
+	Port          uint16   `json:"port,omitempty"`
+	IPv4Hint      []string `json:"ipv4hint,omitempty"`
+	ECH           bool     `json:"ech,omitempty"`
+	IPv6Hint      []string `json:"ipv6hint,omitempty"`
+}
@@ -0,0 +1,12 @@
+package common
+
+// Connection 查询结果被进程用于发起的出站连接
+type Connection struct {
+	IP       string `json:"ip"`
+	Port     uint16 `json:"port"`
+	Protocol string `json:"protocol"`
+	// 从得到解析结果到发起连接的间隔（毫秒）
+	DelayMs int64 `json:"delayMs"`
+	// 目标端口不在常用端口列表中
+	Suspicious bool `json:"suspicious,omitempty"`
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// 国际化域名转换规则：按查询时的规则映射大小写，允许 _dmarc、_sip._tcp 等非主机名标签
+var idnProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.Transitional(false))
+
+// ASCIIDomain 将域名转为小写的 ASCII 形式，Unicode 标签编码为 punycode（xn--），
+// 作为过滤、去重和匹配的统一形式；无法编码时只转为小写
+func ASCIIDomain(name string) string {
+	if !hasNonASCII(name) {
+		return strings.ToLower(name)
+	}
+	ascii, err := idnProfile.ToASCII(name)
+	if err != nil {
+		return strings.ToLower(name)
+	}
+	return ascii
+}
+
+// UnicodeDomain 将域名中的 punycode 标签解码为 Unicode 用于展示，
+// 不含 punycode 标签或解码失败时返回空字符串；解码结果不能编码回原域名时（如 xn-- 后为空
+// 或不规范的编码）同样返回空字符串，避免展示与实际查询不同的域名
+func UnicodeDomain(name string) string {
+	lower := strings.ToLower(name)
+	if !strings.Contains(lower, "xn--") {
+		return ""
+	}
+	unicode, err := idnProfile.ToUnicode(name)
+	if err != nil || unicode == lower || ASCIIDomain(unicode) != lower {
+		return ""
+	}
+	return unicode
+}
+
+func hasNonASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
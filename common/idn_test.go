@@ -0,0 +1,48 @@
+package common
+
+import "testing"
+
+func TestASCIIDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"www.Example.COM", "www.example.com"},
+		{"example.com.", "example.com."},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"BÜCHER.de", "xn--bcher-kva.de"},
+		{"XN--BCHER-KVA.DE", "xn--bcher-kva.de"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		// 非主机名标签保持原样
+		{"_DMARC.Example.com", "_dmarc.example.com"},
+		{"_sip._tcp.bücher.de", "_sip._tcp.xn--bcher-kva.de"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ASCIIDomain(tt.name); got != tt.want {
+			t.Errorf("ASCIIDomain(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnicodeDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"xn--bcher-kva.de", "bücher.de"},
+		{"www.XN--BCHER-KVA.de", "www.bücher.de"},
+		{"xn--r8jz45g.xn--zckzah", "例え.テスト"},
+		// 不含 punycode 标签或无法解码时为空
+		{"www.example.com", ""},
+		{"xn--a.example", ""},
+		{"xn--.example", ""},
+		// 解码为纯 ASCII 的标签会让展示的域名与实际查询不同
+		{"xn--zz-.example", ""},
+	}
+	for _, tt := range tests {
+		if got := UnicodeDomain(tt.name); got != tt.want {
+			t.Errorf("UnicodeDomain(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
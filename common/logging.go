@@ -0,0 +1,71 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// LogLevel 诊断日志级别，诊断日志经标准库 log 输出（默认 stderr），与 stdout 上的 DNS 记录分开
+type LogLevel int32
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l LogLevel) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("LEVEL%d", int32(l))
+	}
+	return levelNames[l]
+}
+
+// 当前输出的最低级别，默认 info
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(int32(LevelInfo))
+}
+
+// SetLogLevel 设置输出的最低级别
+func SetLogLevel(level LogLevel) {
+	logLevel.Store(int32(level))
+}
+
+// DebugEnabled 是否输出 debug 级别的日志，用于跳过代价较高的调试信息
+func DebugEnabled() bool {
+	return LogLevel(logLevel.Load()) <= LevelDebug
+}
+
+// Debugf 输出调试信息，默认不输出
+func Debugf(format string, args ...any) {
+	logf(LevelDebug, format, args...)
+}
+
+// Infof 输出运行状态信息
+func Infof(format string, args ...any) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warnf 输出不影响继续运行的问题
+func Warnf(format string, args ...any) {
+	logf(LevelWarn, format, args...)
+}
+
+// Errorf 输出错误
+func Errorf(format string, args ...any) {
+	logf(LevelError, format, args...)
+}
+
+func logf(level LogLevel, format string, args ...any) {
+	if level < LogLevel(logLevel.Load()) {
+		return
+	}
+	// calldepth 3 使 Lshortfile 指向 Debugf 等函数的调用方
+	log.Output(3, level.String()+" "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,15 @@
+package common
+
+import "sync/atomic"
+
+// Stats 运行期间的事件计数
+var Stats struct {
+	// 提交到处理流程的记录数
+	Processed atomic.Uint64
+	// 被过滤规则丢弃的事件数
+	Filtered atomic.Uint64
+	// 因错误或队列已满丢失的事件数
+	Dropped atomic.Uint64
+	// 无法解析的事件或 DNS 报文数
+	ParseErrors atomic.Uint64
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"log"
 	"math/rand"
 	"net"
 	"net/http"
@@ -14,16 +13,105 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// DNSRecord 定义通用的 DNS 记录结构
+// DNSRecord 定义通用的 DNS 记录结构，由两个平台的采集后端填充，平台取不到的字段保持零值
 type DNSRecord struct {
 	Timestamp   time.Time `json:"timestamp"`
 	QueryName   string    `json:"queryName"`
 	QueryType   string    `json:"queryType"`
 	QueryResult string    `json:"queryResult"`
-	ProcessID   uint32    `json:"processId"`
+	ProcessID   uint32    `json:"processId"` // 进程 ID，Linux 上即 tgid
 	ProcessName string    `json:"processName"`
 	ProcessPath string    `json:"processPath"`
 	ClientIP    string    `json:"clientIP"`
+	Status      string    `json:"status,omitempty"`
+	Rcode       string    `json:"rcode,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	ThreadID    uint32    `json:"threadId,omitempty"`
+	ThreadName  string    `json:"threadName,omitempty"`
+	EventID     uint16    `json:"eventId,omitempty"`
+	NetNS       uint64    `json:"netns,omitempty"`
+	// 查询发往的解析器地址，未知时为空（Linux；Windows 仅 3011 事件）
+	ResolverIP string `json:"resolverIp,omitempty"`
+	// 解析器端口，通常为 53（Linux）
+	ResolverPort uint16 `json:"resolverPort,omitempty"`
+	// DNS 事务 ID，同一进程的查询与响应可按此关联
+	TransactionID uint16 `json:"transactionId,omitempty"`
+	// 记录来自收到的响应报文而不是发出的查询（Linux；Windows 为 3011 事件）
+	Response bool `json:"response,omitempty"`
+	// 发起查询的进程所在 cgroup（v2）的 ID（Linux）
+	CgroupID uint64 `json:"cgroupId,omitempty"`
+	// 容器 ID 和 Kubernetes Pod UID，主机进程为空（Linux）
+	ContainerID string `json:"containerId,omitempty"`
+	PodUID      string `json:"podUid,omitempty"`
+	// 套接字 cookie，在同一主机上唯一标识一个套接字，可作为查询与连接事件的关联键（Linux）
+	SocketCookie uint64 `json:"socketCookie,omitempty"`
+	// 进程启动时间，未知时为零值
+	ProcessStartTime time.Time `json:"processStartTime"`
+	// 父进程和完整命令行，用于定位经由 shell 等中间进程启动的程序，读取失败时为空
+	ParentProcessID   uint32 `json:"parentProcessId,omitempty"`
+	ParentProcessName string `json:"parentProcessName,omitempty"`
+	CommandLine       string `json:"commandLine,omitempty"`
+
+	// 记录级别，命中诱饵域名等高危情况为 high
+	Severity string `json:"severity,omitempty"`
+	// 查询了诱饵域名，不受任何过滤条件影响
+	Canary bool `json:"canary,omitempty"`
+
+	// 命中告警规则（威胁情报域名或长度、熵阈值），告警原因记录在 notes 中
+	Alert bool `json:"alert,omitempty"`
+
+	// 查询域名中最长标签的香农熵（比特/字符），仅在启用 DGA 检测时填充
+	Entropy float64 `json:"entropy,omitempty"`
+	// 疑似算法生成（DGA）或隧道编码的域名
+	Suspicious bool `json:"suspicious,omitempty"`
+
+	// 查询到响应的耗时（毫秒），由响应与查询配对（Linux、macOS）或合并查询事件（Windows）得到，未知时为 0
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+
+	// 合并同一次查询的多个事件后得到的应答来源：cache 为本机 DNS 缓存，server 为 DNS 服务器（Windows）
+	AnswerSource string `json:"answerSource,omitempty"`
+
+	// 加密 DNS 的类型（如 DoQ），此时报文内容不可见，没有查询域名
+	EncryptedDNS string `json:"encryptedDns,omitempty"`
+
+	// (进程, 解析器) 组合在本次运行中首次出现
+	Baseline bool `json:"baseline,omitempty"`
+
+	// 检测环节附加的说明
+	Notes []string `json:"notes,omitempty"`
+
+	// 结构化的应答记录，仅在能取得响应报文或 Windows 的 QueryResults 时填充
+	Answers []Answer `json:"answers,omitempty"`
+
+	// 解析后进程连接了结果中的地址，仅出现在关联事件中
+	Connection *Connection `json:"connection,omitempty"`
+
+	// 国际化域名解码后的 Unicode 形式，仅用于展示，queryName 保留 punycode 形式（-decode-idn）
+	QueryNameUnicode string `json:"queryNameUnicode,omitempty"`
+
+	// 展开 CNAME 链后的解析路径，从查询域名到最终地址
+	ResolutionPath []string `json:"resolutionPath,omitempty"`
+
+	// 去重窗口内被抑制的重复查询次数，仅出现在窗口结束时的汇总记录中
+	Suppressed int `json:"suppressed,omitempty"`
+
+	// 解析结果变化时记录上一次的结果
+	PreviousResult string `json:"previousResult,omitempty"`
+
+	// DNS 报文长度（字节），未知时为 0
+	MessageSize int `json:"messageSize,omitempty"`
+	// 报文长度超过 -large-message 阈值
+	LargeMessage bool `json:"largeMessage,omitempty"`
+
+	// EDNS Client Subnet 中解析器告知上游的客户端子网，如 203.0.113.0/24
+	ClientSubnet string `json:"clientSubnet,omitempty"`
+
+	// DNS 头部 AD 位，用于观察 DNSSEC 验证情况
+	AuthenticatedData bool `json:"authenticatedData,omitempty"`
+	// 疑似启用了 QNAME 最小化的解析器发出的部分查询，不应视为畸形或隧道流量
+	QNameMinimization bool `json:"qnameMinimization,omitempty"`
+	// 报文超出采集缓冲区被截断，仅头部和问题部分可信
+	TruncatedCapture bool `json:"truncatedCapture,omitempty"`
 }
 
 var (
@@ -56,7 +144,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket 升级失败: %v", err)
+		Warnf("WebSocket 升级失败: %v", err)
 		return
 	}
 	defer conn.Close()
@@ -86,7 +174,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 func broadcastRecord(record DNSRecord) {
 	data, err := json.Marshal(record)
 	if err != nil {
-		log.Printf("JSON 序列化失败: %v", err)
+		Errorf("JSON 序列化失败: %v", err)
 		return
 	}
 
@@ -96,7 +184,7 @@ func broadcastRecord(record DNSRecord) {
 	for client := range clients {
 		err := client.WriteMessage(websocket.TextMessage, data)
 		if err != nil {
-			log.Printf("发送消息失败: %v", err)
+			Warnf("发送消息失败: %v", err)
 			client.Close()
 			delete(clients, client)
 		}
@@ -126,8 +214,8 @@ func getRandomAvailablePort() int {
 	}
 }
 
-// StartWebServer 启动 Web 服务器
-func StartWebServer() {
+// StartWebServer 启动 Web 服务器，服务器异常退出时返回错误
+func StartWebServer() error {
 	// 获取可用的随机端口
 	port := getRandomAvailablePort()
 
@@ -142,15 +230,21 @@ func StartWebServer() {
 
 	// 启动服务器
 	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Web 服务器启动在 http://localhost%s", addr)
+	Infof("Web 服务器启动在 http://localhost%s", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatalf("Web 服务器启动失败: %v", err)
+		return fmt.Errorf("Web 服务器启动失败: %v", err)
 	}
+	return nil
 }
 
-// handleHome 处理主页请求
+// handleHome 处理主页请求，模板缺失或无效时返回 500 而不是 panic
 func handleHome(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles("templates/index.html"))
+	tmpl, err := template.ParseFiles("templates/index.html")
+	if err != nil {
+		Errorf("加载页面模板失败: %v", err)
+		http.Error(w, "页面模板不可用", http.StatusInternalServerError)
+		return
+	}
 	if err := tmpl.Execute(w, nil); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
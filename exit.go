@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"dnsflux/common"
+	"dnsflux/output"
+	"dnsflux/pipeline"
+	"dnsflux/platform"
+)
+
+// 退出码
+const (
+	exitOK         = 0 // 正常退出（收到信号或采集结束）
+	exitRuntime    = 1 // 运行期间出错
+	exitUsage      = 2 // 参数或配置无效
+	exitPermission = 3 // 权限不足
+	exitSetup      = 4 // 初始化采集失败
+)
+
+// 退出时输出到 stderr 的状态摘要
+type exitStatus struct {
+	Reason    string `json:"reason"`
+	Code      int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+	Processed uint64 `json:"processed"`
+	Filtered  uint64 `json:"filtered"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// 根据错误类别确定退出码
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, platform.ErrPermission):
+		return exitPermission
+	case errors.Is(err, platform.ErrConfig):
+		return exitUsage
+	case errors.Is(err, platform.ErrSetup):
+		return exitSetup
+	default:
+		return exitRuntime
+	}
+}
+
+// 处理完已入队的记录并关闭输出端，在 stderr 输出一行 JSON 状态摘要后以对应退出码退出
+func exit(reason string, code int, err error) {
+	// 先处理完已入队的记录，再关闭输出端
+	pipeline.Drain()
+	output.CloseAll()
+
+	status := exitStatus{
+		Reason:    reason,
+		Code:      code,
+		Processed: common.Stats.Processed.Load(),
+		Filtered:  common.Stats.Filtered.Load(),
+		Dropped:   common.Stats.Dropped.Load(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	if data, jsonErr := json.Marshal(status); jsonErr == nil {
+		fmt.Fprintln(os.Stderr, string(data))
+	}
+	os.Exit(code)
+}
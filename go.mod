@@ -6,10 +6,16 @@ require (
 	github.com/0xrawsec/golang-etw v1.6.2
 	github.com/cilium/ebpf v0.16.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.20.0
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.33.0
 )
 
 require (
 	github.com/0xrawsec/golang-utils v1.3.1 // indirect
 	golang.org/x/exp v0.0.0-20230224173230-c95f2b4c22f2 // indirect
-	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
 )
@@ -1,32 +1,817 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"dnsflux/common"
+	"dnsflux/output"
+	"dnsflux/pipeline"
 	"dnsflux/platform"
 )
 
-func main() {
-	// 配置日志
+// 命令行参数
+var (
+	captureFilter = flag.String("filter", "", "采集过滤表达式，只处理匹配的记录，如 'proc == \"curl\" && (type == TXT || name ~ \"*.onion\")'，语法见 README")
+	consoleFilter = flag.String("console-filter", "", "控制台输出的过滤表达式")
+	logFilter     = flag.String("log-filter", "", "日志文件输出的过滤表达式")
+	webFilter     = flag.String("web-filter", "", "Web 页面展示的过滤表达式")
+	webhookURL    = flag.String("webhook", "", "将 DNS 记录以 JSON 形式 POST 到该 URL")
+	webhookFilter = flag.String("webhook-filter", "", "webhook 输出的过滤表达式，如 'nxdomain'")
+
+	gelfTarget = flag.String("gelf", "", "以 GELF 格式发送到 Graylog，如 udp://graylog:12201 或 tcp://graylog:12201")
+	gelfFilter = flag.String("gelf-filter", "", "GELF 输出的过滤表达式")
+
+	syslogTarget = flag.String("syslog", "", "以 RFC 5424 格式发送到 syslog，如 udp://siem:514、tcp://siem:601、unix:///dev/log，或 journald 写入 systemd 日志（Linux）")
+	syslogFilter = flag.String("syslog-filter", "", "syslog 输出的过滤表达式")
+
+	grpcTarget = flag.String("grpc", "", "通过 gRPC 客户端流发送到采集服务，如 collector:9090，服务定义见 proto/dnsflux.proto")
+	grpcTLS    = flag.Bool("grpc-tls", false, "gRPC 连接使用 TLS")
+	grpcFilter = flag.String("grpc-filter", "", "gRPC 输出的过滤表达式")
+
+	spoolDir       = flag.String("spool-dir", "", "将每条记录写成该目录中的一个 JSON 文件，供监视目录的采集程序处理")
+	spoolRetention = flag.Duration("spool-retention", 0, "删除目录中早于该时长的记录文件，如 24h，0 表示不删除")
+	spoolFilter    = flag.String("spool-filter", "", "目录输出的过滤表达式")
+
+	sqlitePath      = flag.String("sqlite", "", "将记录写入该 SQLite 数据库文件，首次运行时自动建表，便于事后查询")
+	sqliteRetention = flag.Int("sqlite-retention-days", 0, "删除数据库中早于 N 天的记录，0 表示不删除")
+	sqliteFilter    = flag.String("sqlite-filter", "", "SQLite 输出的过滤表达式")
+
+	onMatch            = flag.String("on-match", "", "对匹配 -on-match-filter 的记录执行的命令，参数中可使用 {{.QueryName}} 等字段，不经过 shell")
+	onMatchFilter      = flag.String("on-match-filter", "", "触发 -on-match 命令的过滤表达式，如 canary")
+	onMatchConcurrency = flag.Int("on-match-concurrency", 4, "同时运行的命令数上限，超出时跳过该记录")
+	onMatchTimeout     = flag.Duration("on-match-timeout", 10*time.Second, "单个命令的超时时间，超时后终止")
+
+	otlpEndpoint = flag.String("otlp-endpoint", "", "OTLP/HTTP collector 地址，如 http://localhost:4318，设置后每次查询导出为一个 span")
+	otlpService  = flag.String("otlp-service", "dnsflux", "导出 span 时使用的 service.name")
+	otlpFilter   = flag.String("otlp-filter", "", "OTLP 输出的过滤表达式")
+
+	summaryInterval = flag.Duration("summary-interval", 0, "定期输出查询汇总（查询最多的域名和进程、各查询类型的次数、过滤和丢弃数）的间隔，退出时再输出一次，如 1m，0 表示不输出")
+	summaryTree     = flag.Bool("summary-tree", false, "汇总按可注册域名（依据公共后缀列表的 ICANN 部分）分组，以树形展开子域名")
+	summaryTop      = flag.Int("summary-top", 10, "汇总中显示查询最多的前 N 个域名和进程")
+	reportCSV       = flag.String("report-csv", "", "退出时将按域名汇总的查询次数、类型和进程写入该 CSV 文件")
+
+	followChain        = flag.Bool("follow-resolver-chain", false, "将应答中的 CNAME 链展开为完整的解析路径")
+	onlyNewProcesses   = flag.Duration("only-new-processes-after", 0, "仅输出启动不超过该时长的进程查询本次运行中未出现过的域名，如 30s，0 表示不启用")
+	slowQuery          = flag.Duration("slow-query", 0, "查询到响应的耗时超过该值时输出警告，如 500ms，0 表示不检查")
+	latencyTimeout     = flag.Duration("latency-timeout", 5*time.Second, "查询等待与响应配对的最长时间，超时未收到响应的查询不再计算耗时（Linux、macOS，需 -capture-responses）")
+	connectWindow      = flag.Duration("resolved-connect-window", 0, "进程在解析后该时长内连接结果地址时输出关联事件，如 30s，0 表示不启用（Linux）")
+	connectNormalPorts = flag.String("connect-normal-ports", "80,443", "关联事件中视为常用的目标端口，其他端口标注为可疑并提升级别，以逗号分隔")
+	resolverBaseline   = flag.Bool("resolver-baseline", false, "只输出每个 (进程名, 解析器地址) 组合的第一条记录，用于了解主机的 DNS 拓扑")
+	onlyChanges        = flag.Bool("only-changes", false, "仅在域名的解析结果与上次不同时输出")
+	crossProcWindow    = flag.Duration("cross-process-window", time.Minute, "跨进程关联检测的时间窗口")
+	crossProcThreshold = flag.Int("cross-process-threshold", 0, "窗口内查询同一域名的不同进程数达到该值时标注，0 表示不检测")
+	qnameMinimization  = flag.Bool("detect-qname-minimization", false, "标注启用 QNAME 最小化的解析器发出的部分查询")
+	ptrStormWindow     = flag.Duration("ptr-storm-window", time.Minute, "反向解析风暴检测的时间窗口")
+	rateLimit          = flag.Int("rate-limit", 0, "每秒最多处理的记录数，超出部分丢弃或按 -rate-limit-sample 抽样，诱饵域名不受限制，0 表示不限制")
+	rateLimitSample    = flag.Int("rate-limit-sample", 0, "超过 -rate-limit 后每 N 条保留 1 条，0 表示全部丢弃")
+	ptrStormThreshold  = flag.Int("ptr-storm-threshold", 0, "单个进程在窗口内的 PTR 查询数达到该值时标注为反向解析风暴，0 表示不检测")
+	knownGood          = flag.String("known-good", "", "已知正常域名文件（每行一个域名）或预生成的布隆过滤器，命中的查询不输出")
+	knownGoodFPRate    = flag.Float64("known-good-fp-rate", 0.001, "由域名文件构建布隆过滤器时的误判率")
+	saveBloom          = flag.String("save-bloom", "", "将 -known-good 构建的布隆过滤器保存到该文件后退出")
+	largeMessage       = flag.Int("large-message", 0, "标注报文长度超过 N 字节的记录，可配合过滤关键字 large 使用，0 表示不标注")
+	reverseDNS         = flag.Bool("reverse-dns", false, "对应答中的 A/AAAA 地址做反向解析，结果写入应答记录的 ptr 字段，会产生额外的 DNS 查询")
+	reverseDNSTTL      = flag.Duration("reverse-dns-ttl", 10*time.Minute, "反向解析结果（含失败）的缓存时间")
+	dedupWindow        = flag.Duration("dedup-window", 0, "同一进程在该时长内重复的相同查询（域名和类型）只输出第一条，窗口结束时输出带重复次数的汇总记录，0 表示不去重")
+	detectDGA          = flag.Bool("detect-dga", false, "按最长标签的熵和长度标注疑似算法生成（DGA）或隧道编码的域名，可配合过滤关键字 suspicious 使用")
+	dgaEntropy         = flag.Float64("dga-entropy", 3.5, "DGA 检测的熵阈值（比特/字符）")
+	dgaMinLength       = flag.Int("dga-min-length", 12, "参与熵判断的最短标签长度")
+	dgaMaxLabel        = flag.Int("dga-max-label", 45, "标签长度达到该值时直接标注为可疑，0 表示不检查")
+	minNameLength      = flag.Int("min-name-length", 0, "仅输出长度超过 N 个字符的查询域名，0 表示不限制")
+
+	replayFile     = flag.String("replay", "", "从 NDJSON 文件回放记录而不是实时采集，用于测试输出端和展示")
+	replayRealtime = flag.Bool("replay-realtime", false, "回放时按记录时间戳的原始间隔输出，默认尽快输出")
+
+	colorMode         = flag.String("color", "auto", "控制台文本着色：auto 在终端中且未设置 NO_COLOR 时着色，always 总是着色，never 不着色")
+	consoleFormatName = flag.String("format", "text", "控制台输出格式：text 为可读文本，json 为每行一个 JSON 对象（NDJSON），键名为 snake_case，时间戳为 RFC3339；csv 先输出一行表头，再每条记录一行")
+	wideTable         = flag.Bool("wide", false, "控制台以单行表格输出，列宽按终端宽度和近期内容自动调整，空间不足时优先截断路径")
+
+	logFile       = flag.String("log-file", "", "日志文件路径，设置后替代按日期命名的 logs/dns_<日期>.log，并按 -log-max-size、-log-daily 轮转")
+	logFormat     = flag.String("log-format", "text", "日志文件格式：text、json（NDJSON）或 csv，csv 只在文件为空时写入表头")
+	logMaxSize    = flag.Int("log-max-size", 100, "日志文件超过该大小（MB）时轮转，0 表示不按大小轮转")
+	logDaily      = flag.Bool("log-daily", false, "日志文件每天轮转一次")
+	logMaxBackups = flag.Int("log-max-backups", 7, "保留的旧日志文件数，0 表示全部保留")
+
+	timeStyle = flag.String("time-style", "absolute", "控制台和日志文件附加的时间戳：absolute 仅绝对时间，start 相对启动时间，delta 与上一条记录的间隔")
+
+	followPID        = flag.Uint("follow-pid", 0, "只输出该进程及其之后启动的子孙进程发起的查询，0 表示不限制")
+	followProcess    = flag.String("follow-process", "", "只输出该名称的进程（不区分大小写）及其子孙进程发起的查询")
+	processCacheSize = flag.Int("process-cache-size", 1024, "按 PID 缓存进程信息的进程数上限，0 表示不缓存")
+	processCacheTTL  = flag.Duration("process-cache-ttl", 5*time.Second, "进程信息缓存的有效期，过期后重新读取以应对 PID 复用，按秒取整")
+
+	workers = flag.Int("workers", 0, "处理环节和输出使用的协程数，记录按 PID 分片，同一进程的记录保持顺序，0 表示在采集协程中同步处理")
+
+	metricsAddr = flag.String("metrics-addr", "", "在该地址提供 Prometheus 指标 /metrics，如 :9153，为空表示不启用")
+
+	streamAddr   = flag.String("stream-addr", "", "在该地址以 /events 实时推送记录（NDJSON，或 Server-Sent Events），如 127.0.0.1:9154，为空表示不启用")
+	streamToken  = flag.String("stream-token", "", "访问 /events 所需的令牌，以 Authorization: Bearer 或 ?token= 提供，为空表示不校验")
+	streamFilter = flag.String("stream-filter", "", "实时推送的过滤表达式")
+
+	statsInterval = flag.Duration("stats", 0, "定期输出处理计数和 eBPF 内核侧统计（提交/丢弃数、ring buffer 占用、程序运行次数和耗时）的间隔，如 10s，0 表示不输出")
+
+	verbose   = flag.Bool("v", false, "输出 debug 级别的诊断日志，包括合并后的生效配置和读取进程信息失败等细节")
+	quiet     = flag.Bool("q", false, "只输出 warn 和 error 级别的诊断日志")
+	debug     = flag.Bool("debug", false, "同 -v，保留以兼容旧的启动参数")
+	logOutput = flag.String("log-output", "stderr", "诊断日志的输出位置：stderr 或文件路径（追加写入），DNS 记录仍输出到 stdout 和各输出端")
+
+	noBanner = flag.Bool("no-banner", false, "不输出启动时的配置摘要")
+
+	etwLevel       = flag.Uint("etw-level", 0xff, "DNS-Client Provider 的最高事件级别，1 严重 2 错误 3 警告 4 信息 5 详细（Windows）")
+	etwAnyKeyword  = flag.Uint64("etw-keywords", 0, "事件关键字至少匹配其中一位才投递，如 0x8000000000000000，0 表示不过滤（Windows）")
+	etwAllKeywords = flag.Uint64("etw-keywords-all", 0, "事件关键字必须包含全部这些位才投递（Windows）")
+	etwSession     = flag.String("etw-session", "DNSMonitor", "ETW 会话名称，同时运行多个实例时需各不相同（Windows）")
+	etwProviders   listFlag
+
+	domainMatch     = flag.String("domain-match", "substring", "域名黑白名单的匹配方式：substring 包含匹配，exact 完整域名匹配")
+	decodeIDN       = flag.Bool("decode-idn", false, "将国际化域名的 punycode（xn--）解码为 Unicode 写入 queryNameUnicode 用于展示，匹配仍使用 punycode 形式")
+	excludeLoopback = flag.Bool("exclude-loopback", false, "忽略发往回环地址（本地解析器）的查询（Linux、macOS）")
+	captureResp     = flag.Bool("capture-responses", false, "同时采集收到的 DNS 响应，输出带应答记录的响应记录，可按 transactionId 与查询关联（Linux、macOS）")
+	detectDoQ       = flag.Bool("detect-doq", false, "将发往 UDP 853 端口的流量作为可能的 DNS over QUIC 上报，每个进程和地址每分钟一次（Linux）")
+	detectEncDNS    = flag.Bool("detect-encrypted-dns", false, "将发往 DoT 端口（默认 TCP 853）或已知 DoH 解析器 443 端口的连接作为可能的加密 DNS 上报，每个进程和地址每分钟一次（Linux）")
+	pinPath         = flag.String("pin-path", "", "在 bpffs 中固定 eBPF map 和 kprobe 的目录（如 /sys/fs/bpf/dnsflux），重启时复用已加载的探针（Linux）")
+	keepPinned      = flag.Bool("keep-pinned", false, "退出时保留固定的 eBPF 对象，探针在重启期间继续采集，需配合 -pin-path（Linux）")
+	listNetns       = flag.Bool("list-netns", false, "列出网络命名空间后退出（Linux）")
+	netNamespaces   listFlag
+	dnsPorts        listFlag
+	interfaces      listFlag
+	dotPorts        listFlag
+	dohPorts        listFlag
+	dohAddresses    listFlag
+
+	configFiles     listFlag
+	allowDomains    listFlag
+	canaryDomains   listFlag
+	processDenylist listFlag
+	processAllow    listFlag
+	pidAllow        listFlag
+	pidDeny         listFlag
+	hostsFiles      listFlag
+	canaryWebhook   = flag.String("canary-webhook", "", "命中诱饵域名时立即 POST 告警到该 URL")
+	alertDomainFile listFlag
+	dgaExclude      listFlag
+	alertMaxLength  = flag.Int("alert-max-length", 0, "查询域名长度超过 N 个字符时标注为告警，0 表示不检查")
+	alertEntropy    = flag.Float64("alert-entropy", 0, "子域名部分的香农熵（比特/字符）超过该值时标注为告警，如 4.0，0 表示不检查")
+	alertWebhook    = flag.String("alert-webhook", "", "将命中告警规则的记录 POST 到该 URL，失败时按指数退避重试")
+)
+
+func init() {
+	flag.Var(&configFiles, "config", "JSON 或 YAML（.yaml/.yml）配置文件，可重复指定，按顺序合并：列表追加，其他字段覆盖")
+	flag.Var(&netNamespaces, "netns", "仅监控指定的网络命名空间（名称或 inode），可重复或以逗号分隔（Linux）")
+	flag.Var(&canaryDomains, "canary-domain", "诱饵域名，查询该域名或其子域名时输出高危记录，不受任何过滤条件影响，可重复或以逗号分隔")
+	flag.Var(&processDenylist, "deny-process", "不输出这些进程发起的查询，替换平台默认列表，none 表示不过滤，可重复或以逗号分隔")
+	flag.Var(&processAllow, "allow-process", "只输出这些进程名发起的查询（不区分大小写），可重复或以逗号分隔")
+	flag.Var(&pidAllow, "pid", "只输出这些 PID 发起的查询，可重复或以逗号分隔")
+	flag.Var(&pidDeny, "deny-pid", "不输出这些 PID 发起的查询，可重复或以逗号分隔")
+	flag.Var(&allowDomains, "allow-domain", "域名白名单，命中的查询视为已批准而不输出，先于黑名单判断，可重复或以逗号分隔")
+	flag.Var(&alertDomainFile, "alert-domains", "威胁情报域名列表文件（每行一个域名），查询其中的域名或子域名时标注为告警，可重复或以逗号分隔")
+	flag.Var(&dgaExclude, "dga-exclude", "不参与 DGA 检测的域名（含子域名），追加到内置的 CDN 排除列表，可重复或以逗号分隔")
+	flag.Var(&etwProviders, "etw-provider", "启用的 ETW Provider（GUID 或注册名称，如 Microsoft-Windows-DNSServer），替换默认的 DNS-Client，可重复或以逗号分隔（Windows）")
+	flag.Var(&hostsFiles, "blacklist-hosts", "hosts 格式的拦截列表文件（如 Pi-hole 列表），其中的域名加入域名黑名单，可重复或以逗号分隔")
+	flag.Var(&dnsPorts, "dns-port", "除 53 以外视为明文 DNS 的目标端口（如本机解析器监听的 5353），内核只拷贝发往这些端口的流量，可重复或以逗号分隔（Linux、macOS）")
+	flag.Var(&dotPorts, "dot-port", "视为 DoT 的 TCP 目标端口，替换默认的 853，可重复或以逗号分隔（Linux）")
+	flag.Var(&dohPorts, "doh-port", "连接 DoH 解析器时视为 DoH 的目标端口，替换默认的 443，可重复或以逗号分隔（Linux）")
+	flag.Var(&dohAddresses, "doh-address", "DoH 解析器地址（IP 或 CIDR），追加到内置的公共解析器列表，可重复或以逗号分隔（Linux）")
+	flag.Var(&interfaces, "interface", "仅监控经由指定网络接口发出的查询，可重复或以逗号分隔（Linux、macOS）")
+}
+
+// 解析 PID 列表参数，无效时以参数错误退出
+func parsePIDs(name string, values listFlag) []uint32 {
+	pids := make([]uint32, 0, len(values))
+	for _, value := range values {
+		pid, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("-%s 中的 PID %q 无效", name, value))
+		}
+		pids = append(pids, uint32(pid))
+	}
+	return pids
+}
+
+// listFlag 可重复指定、以逗号分隔的字符串列表参数
+type listFlag []string
+
+// String 实现 flag.Value 接口
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set 实现 flag.Value 接口
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// 输出暂停状态的变化
+func logPauseState(paused bool) {
+	if paused {
+		common.Infof("已暂停，采集仍在运行，事件不再处理和输出")
+	} else {
+		common.Infof("已恢复")
+	}
+}
+
+// 定期输出处理计数，实时采集时附带 eBPF 内核侧统计
+func reportStats(interval time.Duration, kernel bool) {
+	for range time.Tick(interval) {
+		line := fmt.Sprintf("统计: processed=%d filtered=%d dropped=%d",
+			common.Stats.Processed.Load(), common.Stats.Filtered.Load(), common.Stats.Dropped.Load())
+		if kernel {
+			if ks, err := platform.ReadKernelStats(); err == nil {
+				line += " kernel: " + ks.String()
+			}
+		}
+		common.Infof("%s", line)
+	}
+}
+
+// 解析以逗号分隔的端口列表
+func parsePorts(list string) ([]uint16, error) {
+	var ports []uint16
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		port, err := strconv.ParseUint(field, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("端口 %q 无效", field)
+		}
+		ports = append(ports, uint16(port))
+	}
+	return ports, nil
+}
+
+// 判断命令行中是否显式指定了某个参数
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// 输出网络命名空间列表
+func printNetNamespaces() {
+	namespaces, err := platform.ListNetNamespaces()
+	if err != nil {
+		exit("error", exitUsage, fmt.Errorf("枚举网络命名空间失败: %v", err))
+	}
+	fmt.Printf("%-12s  %-6s  %s\n", "INODE", "PIDS", "NAME")
+	for _, ns := range namespaces {
+		fmt.Printf("%-12d  %-6d  %s\n", ns.Inode, ns.PIDs, ns.Name)
+	}
+}
+
+// 保存布隆过滤器
+func saveBloomFilter(bloom *pipeline.BloomFilter, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		exit("error", exitRuntime, err)
+	}
+	if _, err := bloom.WriteTo(f); err != nil {
+		f.Close()
+		exit("error", exitRuntime, err)
+	}
+	if err := f.Close(); err != nil {
+		exit("error", exitRuntime, err)
+	}
+}
+
+// 按名称注册输出端，过滤表达式无效时退出
+func registerSink(name string, sink output.Sink, expr string) {
+	filter, err := output.ParseFilter(expr)
+	if err != nil {
+		exit("error", exitUsage, fmt.Errorf("%s 过滤表达式无效: %v", name, err))
+	}
+	output.Register(name, sink, filter)
+}
+
+// 收到退出信号后等待采集后端清理的最长时间
+const shutdownTimeout = 5 * time.Second
+
+// 输出一行启动配置摘要
+func printBanner(cfg platform.Config, stages []string) {
+	stageList := "none"
+	if len(stages) > 0 {
+		stageList = strings.Join(stages, ",")
+	}
+	backend := platform.Describe(cfg)
+	if *replayFile != "" {
+		backend = fmt.Sprintf("backend=replay file=%s realtime=%t", *replayFile, *replayRealtime)
+	}
+	common.Infof("dnsflux %s/%s %s stages=%s sinks=%s",
+		runtime.GOOS, runtime.GOARCH, backend, stageList, strings.Join(output.Names(), ","))
+}
+
+// 按 -v、-q 设置诊断日志级别，按 -log-output 设置输出位置
+func setupLogging() error {
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
-	log.Printf("启动DNS监控(Platform: %s)...\n", runtime.GOOS)
+
+	switch {
+	case *quiet && (*verbose || *debug):
+		return errors.New("-q 不能与 -v 同时使用")
+	case *verbose || *debug:
+		common.SetLogLevel(common.LevelDebug)
+	case *quiet:
+		common.SetLogLevel(common.LevelWarn)
+	}
+
+	if *logOutput == "" || *logOutput == "stderr" {
+		return nil
+	}
+	f, err := os.OpenFile(*logOutput, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开诊断日志文件失败: %v", err)
+	}
+	log.SetOutput(f)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := setupLogging(); err != nil {
+		exit("error", exitUsage, err)
+	}
+
+	if *listNetns {
+		printNetNamespaces()
+		return
+	}
+
+	// 配置文件合并到默认配置之上，命令行参数再覆盖配置文件
+	cfg, err := platform.LoadConfigFiles(configFiles)
+	if err != nil {
+		exit("error", exitUsage, err)
+	}
+	if isFlagSet("etw-level") {
+		if *etwLevel > 0xff {
+			exit("error", exitUsage, fmt.Errorf("etw-level 超出范围: %d", *etwLevel))
+		}
+		cfg.Provider.Level = uint8(*etwLevel)
+	}
+	if isFlagSet("etw-keywords") {
+		cfg.Provider.MatchAnyKeyword = *etwAnyKeyword
+	}
+	if isFlagSet("etw-keywords-all") {
+		cfg.Provider.MatchAllKeyword = *etwAllKeywords
+	}
+	if len(etwProviders) > 0 {
+		cfg.Providers = etwProviders
+	}
+	if isFlagSet("etw-session") {
+		cfg.SessionName = *etwSession
+	}
+	if isFlagSet("process-cache-size") {
+		cfg.ProcessCache.Size = max(*processCacheSize, 0)
+	}
+	if isFlagSet("process-cache-ttl") {
+		cfg.ProcessCache.TTL = uint32(processCacheTTL.Seconds())
+	}
+	if isFlagSet("capture-responses") {
+		cfg.CaptureResponses = *captureResp
+	}
+	if isFlagSet("pin-path") {
+		cfg.PinPath = *pinPath
+	}
+	if isFlagSet("keep-pinned") {
+		cfg.KeepPinned = *keepPinned
+	}
+	if isFlagSet("detect-doq") {
+		cfg.DetectDoQ = *detectDoQ
+	}
+	if isFlagSet("detect-encrypted-dns") {
+		cfg.DetectEncryptedDNS = *detectEncDNS
+	}
+	if len(dotPorts) > 0 {
+		if cfg.DoTPorts, err = parsePorts(strings.Join(dotPorts, ",")); err != nil {
+			exit("error", exitUsage, fmt.Errorf("dot-port 无效: %v", err))
+		}
+	}
+	if len(dohPorts) > 0 {
+		if cfg.DoHPorts, err = parsePorts(strings.Join(dohPorts, ",")); err != nil {
+			exit("error", exitUsage, fmt.Errorf("doh-port 无效: %v", err))
+		}
+	}
+	cfg.DoHAddresses = append(cfg.DoHAddresses, dohAddresses...)
+	if cfg.DetectEncryptedDNS {
+		// 加密 DNS 检测基于连接事件
+		cfg.TrackConnections = true
+	}
+	if isFlagSet("exclude-loopback") {
+		cfg.IncludeLoopback = !*excludeLoopback
+	}
+	cfg.NetNamespaces = append(cfg.NetNamespaces, netNamespaces...)
+	cfg.Interfaces = append(cfg.Interfaces, interfaces...)
+	ports, err := parsePorts(strings.Join(dnsPorts, ","))
+	if err != nil {
+		exit("error", exitUsage, fmt.Errorf("dns-port 无效: %v", err))
+	}
+	cfg.DNSPorts = append(cfg.DNSPorts, ports...)
+	cfg.DomainBlacklistHosts = append(cfg.DomainBlacklistHosts, hostsFiles...)
+	cfg.DomainAllowlist = append(cfg.DomainAllowlist, allowDomains...)
+	if isFlagSet("domain-match") {
+		if *domainMatch != "substring" && *domainMatch != "exact" {
+			exit("error", exitUsage, fmt.Errorf("未知的域名匹配方式 %q，可选 substring、exact", *domainMatch))
+		}
+		cfg.DomainMatch = *domainMatch
+	}
+	if isFlagSet("decode-idn") {
+		cfg.DecodeIDN = *decodeIDN
+	}
+	if len(processDenylist) > 0 {
+		cfg.ProcessDenylist = nil
+		if !(len(processDenylist) == 1 && processDenylist[0] == "none") {
+			cfg.ProcessDenylist = processDenylist
+		}
+	}
+	cfg.ProcessAllowlist = append(cfg.ProcessAllowlist, processAllow...)
+	if isFlagSet("detect-dga") {
+		cfg.DGA.Enabled = *detectDGA
+	}
+	if isFlagSet("dga-entropy") {
+		cfg.DGA.Entropy = *dgaEntropy
+	}
+	if isFlagSet("dga-min-length") {
+		cfg.DGA.MinLength = *dgaMinLength
+	}
+	if isFlagSet("dga-max-label") {
+		cfg.DGA.MaxLabelLength = *dgaMaxLabel
+	}
+	cfg.DGA.Exclude = append(cfg.DGA.Exclude, dgaExclude...)
+	cfg.PIDAllowlist = append(cfg.PIDAllowlist, parsePIDs("pid", pidAllow)...)
+	cfg.PIDDenylist = append(cfg.PIDDenylist, parsePIDs("deny-pid", pidDeny)...)
+	if isFlagSet("follow-pid") {
+		if *followPID > math.MaxUint32 {
+			exit("error", exitUsage, fmt.Errorf("-follow-pid %d 无效", *followPID))
+		}
+		cfg.FollowPID = uint32(*followPID)
+	}
+	if isFlagSet("follow-process") {
+		cfg.FollowProcess = *followProcess
+	}
+
+	if common.DebugEnabled() {
+		if data, err := json.MarshalIndent(cfg, "", "  "); err == nil {
+			common.Debugf("生效配置:\n%s", data)
+		}
+	}
+
+	// hosts 列表通常很大，在输出生效配置之后再展开到域名黑名单
+	for _, path := range cfg.DomainBlacklistHosts {
+		domains, err := platform.LoadHostsFile(path)
+		if err != nil {
+			exit("error", exitUsage, err)
+		}
+		cfg.DomainBlacklist = append(cfg.DomainBlacklist, domains...)
+		common.Infof("从 %s 加载了 %d 个拦截域名", path, len(domains))
+	}
+
+	// 注册处理环节，域名规范化放在最前面，后续环节都基于小写的 punycode 形式
+	var stages []string
+	pipeline.Use(pipeline.NormalizeNames(cfg.DecodeIDN))
+	if cfg.DecodeIDN {
+		stages = append(stages, "decode-idn")
+	}
+	var canary *pipeline.CanaryDetector
+	if len(canaryDomains) > 0 {
+		// 紧跟在只改写不丢弃的域名规范化之后，保证命中的记录在后续环节中不被丢弃
+		canary = pipeline.NewCanaryDetector(canaryDomains)
+		pipeline.Use(canary)
+		stages = append(stages, fmt.Sprintf("canary=%d", len(canaryDomains)))
+	}
+	if *rateLimit > 0 {
+		// 在所有处理环节之前生效，诱饵域名的查询不受限制
+		var exempt func(string) bool
+		if canary != nil {
+			exempt = canary.Matches
+		}
+		pipeline.SetRateLimit(pipeline.NewRateLimiter(*rateLimit, *rateLimitSample, exempt))
+		stages = append(stages, fmt.Sprintf("rate-limit=%d/s", *rateLimit))
+		if *rateLimitSample > 0 {
+			stages[len(stages)-1] += fmt.Sprintf(",sample=1/%d", *rateLimitSample)
+		}
+	}
+	if len(alertDomainFile) > 0 || *alertMaxLength > 0 || *alertEntropy > 0 {
+		var alertDomains []string
+		for _, path := range alertDomainFile {
+			domains, err := pipeline.LoadDomainList(path)
+			if err != nil {
+				exit("error", exitUsage, fmt.Errorf("加载告警域名列表失败: %v", err))
+			}
+			alertDomains = append(alertDomains, domains...)
+		}
+		pipeline.Use(pipeline.NewAlertRules(alertDomains, *alertMaxLength, *alertEntropy))
+		stages = append(stages, fmt.Sprintf("alert=%d", len(alertDomains)))
+	}
+	if cfg.DGA.Enabled {
+		pipeline.Use(pipeline.NewDGADetector(cfg.DGA.Entropy, cfg.DGA.MinLength, cfg.DGA.MaxLabelLength, cfg.DGA.Exclude))
+		stages = append(stages, fmt.Sprintf("dga=%.2f", cfg.DGA.Entropy))
+	}
+	if *connectWindow > 0 {
+		// 放在可能丢弃记录的环节之前，被过滤的查询也能参与关联
+		ports, err := parsePorts(*connectNormalPorts)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("connect-normal-ports 无效: %v", err))
+		}
+		pipeline.Use(pipeline.NewConnectCorrelator(*connectWindow, ports))
+		cfg.TrackConnections = true
+		stages = append(stages, fmt.Sprintf("resolved-connect=%s", *connectWindow))
+	}
+	if cfg.CaptureResponses || *slowQuery > 0 {
+		// 放在可能丢弃记录的环节之前，被过滤的查询也能与响应配对
+		pipeline.Use(pipeline.NewLatencyTracker(cfg.CaptureResponses, *latencyTimeout, *slowQuery))
+		stage := "latency"
+		if *slowQuery > 0 {
+			stage += fmt.Sprintf(",slow=%s", *slowQuery)
+		}
+		stages = append(stages, stage)
+	}
+	if *captureFilter != "" {
+		expr, err := output.CompileExpr(*captureFilter)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("filter 表达式无效: %v", err))
+		}
+		pipeline.Use(pipeline.StageFunc(func(record *common.DNSRecord) bool {
+			return expr.Match(*record)
+		}))
+		stages = append(stages, "filter")
+	}
+	if *qnameMinimization {
+		// 放在过滤环节之前，以便看到完整的逐级查询序列
+		pipeline.Use(pipeline.NewMinimizationDetector())
+		stages = append(stages, "qname-minimization")
+	}
+	if *knownGood != "" {
+		bloom, err := pipeline.LoadBloomFilter(*knownGood, *knownGoodFPRate)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("加载已知正常域名失败: %v", err))
+		}
+		if *saveBloom != "" {
+			saveBloomFilter(bloom, *saveBloom)
+			return
+		}
+		pipeline.Use(pipeline.KnownGood(bloom))
+		stages = append(stages, "known-good")
+	}
+	if *minNameLength > 0 {
+		pipeline.Use(pipeline.MinNameLength(*minNameLength))
+		stages = append(stages, fmt.Sprintf("min-name-length=%d", *minNameLength))
+	}
+	if *ptrStormThreshold > 0 {
+		pipeline.Use(pipeline.NewPTRStormDetector(*ptrStormWindow, *ptrStormThreshold))
+		stages = append(stages, fmt.Sprintf("ptr-storm=%d/%s", *ptrStormThreshold, *ptrStormWindow))
+	}
+	if *largeMessage > 0 {
+		pipeline.Use(pipeline.LargeMessage(*largeMessage))
+		stages = append(stages, fmt.Sprintf("large-message=%d", *largeMessage))
+	}
+	if *followChain {
+		pipeline.Use(pipeline.ResolverChain())
+		stages = append(stages, "resolver-chain")
+	}
+	if *onlyNewProcesses > 0 {
+		pipeline.Use(pipeline.NewProcessNewDomain(*onlyNewProcesses))
+		stages = append(stages, fmt.Sprintf("new-process-new-domain=%s", *onlyNewProcesses))
+	}
+	if *resolverBaseline {
+		pipeline.Use(pipeline.ResolverBaseline())
+		stages = append(stages, "resolver-baseline")
+	}
+	if *onlyChanges {
+		pipeline.Use(pipeline.NewChangeDetector())
+		stages = append(stages, "only-changes")
+	}
+	if *crossProcThreshold > 0 {
+		pipeline.Use(pipeline.NewCrossProcessDetector(*crossProcWindow, *crossProcThreshold))
+		stages = append(stages, fmt.Sprintf("cross-process=%d/%s", *crossProcThreshold, *crossProcWindow))
+	}
+	if *reverseDNS {
+		// 放在可能丢弃记录的环节之后，只为会输出的记录查询
+		pipeline.Use(pipeline.NewReverseDNS(*reverseDNSTTL))
+		stages = append(stages, fmt.Sprintf("reverse-dns=%s", *reverseDNSTTL))
+	}
+	if *dedupWindow > 0 {
+		// 汇总记录直接分发到输出端，需放在最后
+		pipeline.Use(pipeline.NewDeduplicator(*dedupWindow))
+		stages = append(stages, fmt.Sprintf("dedup=%s", *dedupWindow))
+	}
+
+	// 注册输出端
+	style, err := output.ParseTimeStyle(*timeStyle)
+	if err != nil {
+		exit("error", exitUsage, err)
+	}
+	formatName := *consoleFormatName
+	if !isFlagSet("format") && cfg.Format != "" {
+		formatName = cfg.Format
+	}
+	var consoleFormat func(common.DNSRecord) string
+	consoleHeader := ""
+	switch formatName {
+	case "text":
+		consoleFormat = platform.FormatRecord
+		if *wideTable {
+			consoleFormat = output.NewAutoTable().Format
+		}
+		consoleFormat = output.WithTimeStyle(consoleFormat, style)
+		color, err := output.ColorEnabled(*colorMode, os.Stdout)
+		if err != nil {
+			exit("error", exitUsage, err)
+		}
+		if color {
+			consoleFormat = output.WithColor(consoleFormat)
+		}
+	case "json":
+		// 每行必须是完整的 JSON 对象，不附加相对时间
+		consoleFormat = output.FormatNDJSON
+	case "csv":
+		consoleFormat, consoleHeader = output.FormatCSV, output.CSVHeader
+	default:
+		exit("error", exitUsage, fmt.Errorf("未知的输出格式 %q，可选 text、json、csv", formatName))
+	}
+	registerSink("console", &output.ConsoleSink{Format: consoleFormat, Header: consoleHeader}, *consoleFilter)
+	var logFormatter func(common.DNSRecord) string
+	logHeader := ""
+	switch *logFormat {
+	case "text":
+		logFormatter = output.WithTimeStyle(platform.FormatRecord, style)
+	case "json":
+		logFormatter = output.FormatNDJSON
+	case "csv":
+		logFormatter, logHeader = output.FormatCSV, output.CSVHeader
+	default:
+		exit("error", exitUsage, fmt.Errorf("未知的日志文件格式 %q，可选 text、json、csv", *logFormat))
+	}
+	if *logFile != "" {
+		sink, err := output.NewRotatingFileSink(*logFile, logFormatter, int64(*logMaxSize)<<20, *logDaily, *logMaxBackups)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("打开日志文件 %s 失败: %v", *logFile, err))
+		}
+		if err := sink.SetHeader(logHeader); err != nil {
+			exit("error", exitUsage, fmt.Errorf("写入日志文件 %s 失败: %v", *logFile, err))
+		}
+		registerSink("log", sink, *logFilter)
+	} else {
+		registerSink("log", &output.FileSink{Format: logFormatter, Header: logHeader}, *logFilter)
+	}
+	registerSink("web", output.SinkFunc(func(record common.DNSRecord) error {
+		common.AddDNSRecord(record)
+		return nil
+	}), *webFilter)
+	if *webhookURL != "" {
+		registerSink("webhook", output.NewWebhookSink(*webhookURL), *webhookFilter)
+	}
+	if *canaryWebhook != "" {
+		// 专用的告警 webhook 只接收诱饵域名记录，不与其他记录共用发送队列
+		registerSink("canary-webhook", output.NewWebhookSink(*canaryWebhook), "canary")
+	}
+	if *alertWebhook != "" {
+		registerSink("alert-webhook", output.NewWebhookSink(*alertWebhook), "alert")
+	}
+	if *gelfTarget != "" {
+		gelf, err := output.NewGELFSink(*gelfTarget)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("GELF 地址无效: %v", err))
+		}
+		registerSink("gelf", gelf, *gelfFilter)
+	}
+	if *syslogTarget != "" {
+		syslog, err := output.NewSyslogSink(*syslogTarget)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("syslog 地址无效: %v", err))
+		}
+		registerSink("syslog", syslog, *syslogFilter)
+	}
+	if *grpcTarget != "" {
+		registerSink("grpc", output.NewGRPCSink(*grpcTarget, *grpcTLS), *grpcFilter)
+	}
+	if *spoolDir != "" {
+		spool, err := output.NewSpoolSink(*spoolDir, *spoolRetention)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("创建目录 %s 失败: %v", *spoolDir, err))
+		}
+		registerSink("spool", spool, *spoolFilter)
+	}
+	if *sqlitePath != "" {
+		db, err := output.NewSQLiteSink(*sqlitePath, *sqliteRetention)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("打开数据库 %s 失败: %v", *sqlitePath, err))
+		}
+		registerSink("sqlite", db, *sqliteFilter)
+	}
+	if *onMatch != "" {
+		command, err := output.NewCommandSink(*onMatch, *onMatchConcurrency, *onMatchTimeout)
+		if err != nil {
+			exit("error", exitUsage, fmt.Errorf("on-match 命令无效: %v", err))
+		}
+		registerSink("on-match", command, *onMatchFilter)
+	}
+	if *otlpEndpoint != "" {
+		registerSink("otlp", output.NewOTLPSink(*otlpEndpoint, *otlpService), *otlpFilter)
+	}
+	if *reportCSV != "" {
+		registerSink("report", output.NewReportSink(*reportCSV), "")
+	}
+	var metrics *output.MetricsSink
+	if *metricsAddr != "" {
+		metrics = output.NewMetricsSink()
+		registerSink("metrics", metrics, "")
+	}
+	var stream *output.StreamSink
+	if *streamAddr != "" {
+		stream = output.NewStreamSink(*streamToken)
+		registerSink("stream", stream, *streamFilter)
+	}
+	if *summaryInterval > 0 {
+		registerSink("summary", output.NewSummarySink(*summaryInterval, *summaryTree, *summaryTop), "")
+	}
+
+	if !*noBanner {
+		printBanner(cfg, stages)
+	}
+
+	pipeline.StartWorkers(*workers)
+	watchPauseToggle()
+
+	if *statsInterval > 0 {
+		kernel := *replayFile == "" && runtime.GOOS == "linux"
+		if kernel {
+			platform.EnableKernelStats()
+		}
+		go reportStats(*statsInterval, kernel)
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// 异步启动 DNS 监控
-	go platform.DnsFluxImpl()
+	// 异步启动 DNS 监控，ctx 取消时监控卸载 kprobes 或停止 ETW 会话后返回
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if metrics != nil {
+		if err := output.ServeMetrics(ctx, *metricsAddr, metrics); err != nil {
+			exit("error", exitUsage, fmt.Errorf("启动指标服务失败: %v", err))
+		}
+	}
+	if stream != nil {
+		if err := output.ServeStream(ctx, *streamAddr, stream); err != nil {
+			exit("error", exitUsage, fmt.Errorf("启动实时推送服务失败: %v", err))
+		}
+	}
+	monitorErr := make(chan error, 1)
+	go func() {
+		if *replayFile != "" {
+			monitorErr <- pipeline.Replay(*replayFile, *replayRealtime)
+			return
+		}
+		monitorErr <- platform.DnsFluxImpl(ctx, cfg)
+	}()
 
 	// 启动 Web 服务器（使用 goroutine 避免阻塞）
-	go common.StartWebServer()
-
-	// 等待系统退出信号
-	<-sigChan
+	webErr := make(chan error, 1)
+	go func() {
+		webErr <- common.StartWebServer()
+	}()
 
-	log.Println("程序已退出")
+	// 等待系统退出信号或监控结束
+	select {
+	case sig := <-sigChan:
+		common.Infof("收到信号 %v，正在清理", sig)
+		// 清理期间再次收到信号时按默认行为直接终止
+		signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+		cancel()
+		if *replayFile == "" {
+			select {
+			case <-monitorErr:
+			case <-time.After(shutdownTimeout):
+				common.Warnf("清理超过 %s 未完成，直接退出", shutdownTimeout)
+			}
+		}
+		exit("signal", exitOK, nil)
+	case err := <-monitorErr:
+		if err != nil {
+			common.Errorf("DNS 监控退出: %v", err)
+			exit("error", exitCode(err), err)
+		}
+		exit("stopped", exitOK, nil)
+	case err := <-webErr:
+		common.Errorf("%v", err)
+		exit("error", exitRuntime, err)
+	}
 }
@@ -0,0 +1,75 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// ANSI 颜色控制序列
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+)
+
+// ColorEnabled 按 -color 参数判断控制台是否输出颜色：always 总是输出，never 从不输出，
+// auto 在 f 为终端且未设置 NO_COLOR 环境变量时输出
+func ColorEnabled(mode string, f *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		enableANSI(f)
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if os.Getenv("NO_COLOR") != "" || consoleWidth(f) == 0 {
+			return false, nil
+		}
+		return enableANSI(f), nil
+	}
+	return false, fmt.Errorf("未知的颜色模式 %q，可选 auto、always、never", mode)
+}
+
+// WithColor 为文本输出着色，不改变字段布局：时间戳变暗，失败的查询为红色，
+// 命中诱饵域名、告警规则或 DGA 检测等被标注的记录为黄色
+func WithColor(format func(common.DNSRecord) string) func(common.DNSRecord) string {
+	return func(record common.DNSRecord) string {
+		text := format(record)
+		color := recordColor(record)
+		timestamp := record.Timestamp.Format("2006-01-02 15:04:05")
+
+		// 逐行着色，多行格式（Windows）的每一行都带完整的控制序列
+		lines := strings.Split(text, "\n")
+		dimmed := false
+		for i, line := range lines {
+			if line == "" {
+				continue
+			}
+			if j := strings.Index(line, timestamp); j >= 0 && !dimmed {
+				line = line[:j] + ansiDim + timestamp + ansiReset + color + line[j+len(timestamp):]
+				dimmed = true
+			}
+			if color != "" {
+				line = color + line + ansiReset
+			}
+			lines[i] = line
+		}
+		return strings.Join(lines, "\n")
+	}
+}
+
+// 记录的颜色，失败优先于标注
+func recordColor(record common.DNSRecord) string {
+	switch {
+	case strings.HasPrefix(record.Status, "ERROR"), record.Rcode != "" && record.Rcode != "NOERROR":
+		return ansiRed
+	case record.Canary, record.Alert, record.Suspicious, record.Severity != "",
+		record.Connection != nil && record.Connection.Suspicious:
+		return ansiYellow
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"dnsflux/common"
+)
+
+// CommandSink 对每条记录执行一个外部命令，命令行模板中的字段按记录替换，
+// 如 notify-send "DNS alert: {{.QueryName}}"。参数逐个渲染后直接执行，不经过 shell，
+// 记录中的内容不会被解释为命令
+type CommandSink struct {
+	args    []*template.Template
+	timeout time.Duration
+	slots   chan struct{} // 同时运行的命令数上限
+	wg      sync.WaitGroup
+}
+
+// NewCommandSink 解析命令行模板，concurrency 为同时运行的命令数上限，timeout 为单个命令的超时时间
+func NewCommandSink(commandLine string, concurrency int, timeout time.Duration) (*CommandSink, error) {
+	words, err := splitCommandLine(commandLine)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, errors.New("命令为空")
+	}
+
+	s := &CommandSink{
+		timeout: timeout,
+		slots:   make(chan struct{}, max(concurrency, 1)),
+	}
+	for _, word := range words {
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(word)
+		if err != nil {
+			return nil, fmt.Errorf("无效的模板 %q: %v", word, err)
+		}
+		// 用空记录试渲染一次，启动时即可发现拼错的字段名
+		if err := tmpl.Execute(io.Discard, common.DNSRecord{}); err != nil {
+			return nil, fmt.Errorf("无效的模板 %q: %v", word, err)
+		}
+		s.args = append(s.args, tmpl)
+	}
+	return s, nil
+}
+
+// 按 shell 的引号规则拆分命令行：空白分隔，单引号内原样保留，双引号和引号外支持反斜杠转义
+func splitCommandLine(line string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range line {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("命令行中的引号或转义不完整")
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// Write 实现 Sink 接口，异步执行命令；已达到并发上限时丢弃，避免阻塞事件处理
+func (s *CommandSink) Write(record common.DNSRecord) error {
+	argv := make([]string, len(s.args))
+	for i, tmpl := range s.args {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, record); err != nil {
+			return fmt.Errorf("渲染命令参数失败: %v", err)
+		}
+		argv[i] = b.String()
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return fmt.Errorf("已有 %d 个命令在运行，跳过 %s", cap(s.slots), record.QueryName)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			common.Warnf("命令 %s 超过 %s 未结束，已终止", argv[0], s.timeout)
+		} else if err != nil {
+			common.Warnf("命令 %s 执行失败: %v %s", argv[0], err, strings.TrimSpace(string(out)))
+		}
+	}()
+	return nil
+}
+
+// Close 实现 Sink 接口，等待正在运行的命令结束
+func (s *CommandSink) Close() error {
+	s.wg.Wait()
+	return nil
+}
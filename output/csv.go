@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"dnsflux/common"
+)
+
+// CSV 输出的列，顺序固定，新增列只能追加在末尾
+var csvColumns = []string{"timestamp", "pid", "process_name", "process_path", "protocol", "query_type", "query_name", "status", "query_result", "answers", "latency_ms", "query_name_unicode"}
+
+// CSVHeader CSV 输出的表头行，由 ConsoleSink、FileSink 和 RotatingFileSink 在输出开始或文件为空时写入一次
+var CSVHeader = csvLine(csvColumns)
+
+// FormatCSV 将记录格式化为一行 CSV，包含逗号、引号或换行的字段按 RFC 4180 加引号，
+// 时间戳为带时区偏移的 RFC3339 格式。可作为 ConsoleSink 或 FileSink 的 Format，表头见 CSVHeader
+func FormatCSV(record common.DNSRecord) string {
+	return csvLine([]string{
+		record.Timestamp.Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(record.ProcessID), 10),
+		record.ProcessName,
+		record.ProcessPath,
+		record.Protocol,
+		record.QueryType,
+		record.QueryName,
+		record.Status,
+		record.QueryResult,
+		csvAnswers(record.Answers),
+		csvLatency(record.LatencyMs),
+		record.QueryNameUnicode,
+	})
+}
+
+// 耗时保留 3 位小数（微秒），未知时为空
+func csvLatency(ms float64) string {
+	if ms == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(ms, 'f', 3, 64)
+}
+
+// 应答记录以分号分隔，每条为 "类型 数据"，如 CNAME b.example.net;A 93.184.216.34
+func csvAnswers(answers []common.Answer) string {
+	parts := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		parts = append(parts, answer.Type+" "+answer.Data)
+	}
+	return strings.Join(parts, ";")
+}
+
+func csvLine(fields []string) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(fields)
+	w.Flush()
+	return buf.String()
+}
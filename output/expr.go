@@ -0,0 +1,350 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// Expr 是编译后的过滤表达式，比逐项的过滤参数更灵活，例如：
+//
+//	proc == "curl" && (type == TXT || name ~ "*.onion")
+//	pid > 1000 && !(ip in 10.0.0.0/8) && severity != ""
+//	nxdomain && name =~ "^[a-z0-9]{20,}\."
+//
+// 比较运算符：== != < <= > >=，数值字段按数值比较，其他字段按字符串比较（== 和 != 不区分大小写）；
+// ~ 和 !~ 按 * 通配匹配，=~ 按正则匹配，in 判断 IP 字段是否属于 CIDR 网段。
+// 逻辑运算符：&& || ! 和括号。单独的过滤关键字（如 nxdomain、canary）可作为布尔条件。
+// 值可以是双引号字符串或不含空白和运算符的裸词
+type Expr struct {
+	src   string
+	match func(common.DNSRecord) bool
+}
+
+// 表达式中可比较的数值字段
+var exprNumberFields = map[string]func(common.DNSRecord) uint64{
+	"pid":   func(r common.DNSRecord) uint64 { return uint64(r.ProcessID) },
+	"tid":   func(r common.DNSRecord) uint64 { return uint64(r.ThreadID) },
+	"size":  func(r common.DNSRecord) uint64 { return uint64(r.MessageSize) },
+	"netns": func(r common.DNSRecord) uint64 { return r.NetNS },
+	"event": func(r common.DNSRecord) uint64 { return uint64(r.EventID) },
+}
+
+// 表达式中额外可用的字符串字段，其余与过滤表达式的字段相同
+var exprStringFields = map[string]func(common.DNSRecord) string{
+	"result":   func(r common.DNSRecord) string { return r.QueryResult },
+	"severity": func(r common.DNSRecord) string { return r.Severity },
+	"subnet":   func(r common.DNSRecord) string { return r.ClientSubnet },
+	"thread":   func(r common.DNSRecord) string { return r.ThreadName },
+	"resolver": func(r common.DNSRecord) string { return r.ResolverIP },
+}
+
+// 可按 CIDR 判断的 IP 字段
+var exprIPFields = map[string]bool{"ip": true, "resolver": true}
+
+// CompileExpr 编译过滤表达式，空表达式返回 nil（匹配全部）
+func CompileExpr(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	match, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("位置 %d: 多余的 %q", tok.pos, tok.text)
+	}
+	return &Expr{src: src, match: match}, nil
+}
+
+// Match 判断记录是否满足表达式，nil 表达式匹配全部记录
+func (e *Expr) Match(record common.DNSRecord) bool {
+	if e == nil {
+		return true
+	}
+	return e.match(record)
+}
+
+// String 返回原始表达式
+func (e *Expr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.src
+}
+
+// 词法单元类型
+const (
+	tokEOF = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokOp     // 比较运算符，包括 in
+	tokWord   // 字段名、关键字或裸词值
+	tokString // 双引号字符串
+)
+
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// 按长度从长到短排列，保证 != 优先于 !
+var exprSymbols = []struct {
+	text string
+	kind int
+}{
+	{"&&", tokAnd}, {"||", tokOr},
+	{"==", tokOp}, {"!=", tokOp}, {"<=", tokOp}, {">=", tokOp}, {"=~", tokOp}, {"!~", tokOp},
+	{"<", tokOp}, {">", tokOp}, {"~", tokOp},
+	{"!", tokNot}, {"(", tokLParen}, {")", tokRParen},
+}
+
+// 将表达式切分为词法单元
+func lexExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+next:
+	for i < len(src) {
+		c := src[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			i++
+			continue
+		}
+
+		if c == '"' {
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("位置 %d: 字符串缺少结束引号", i)
+			}
+			text, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("位置 %d: 无效的字符串: %v", i, err)
+			}
+			tokens = append(tokens, exprToken{tokString, text, i})
+			i = end + 1
+			continue
+		}
+
+		for _, sym := range exprSymbols {
+			if strings.HasPrefix(src[i:], sym.text) {
+				tokens = append(tokens, exprToken{sym.kind, sym.text, i})
+				i += len(sym.text)
+				continue next
+			}
+		}
+
+		start := i
+		for i < len(src) && !strings.ContainsRune(" \t\n\r\"()!&|=<>~", rune(src[i])) {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("位置 %d: 无法识别的字符 %q", i, src[i])
+		}
+		word := src[start:i]
+		kind := tokWord
+		if strings.EqualFold(word, "in") {
+			kind, word = tokOp, "in"
+		}
+		tokens = append(tokens, exprToken{kind, word, start})
+	}
+	return append(tokens, exprToken{tokEOF, "", len(src)}), nil
+}
+
+// 递归下降解析器，直接生成匹配函数
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// or := and ('||' and)*
+func (p *exprParser) parseOr() (func(common.DNSRecord) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r common.DNSRecord) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+// and := unary ('&&' unary)*
+func (p *exprParser) parseAnd() (func(common.DNSRecord) bool, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r common.DNSRecord) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+// unary := '!' unary | '(' or ')' | comparison | keyword
+func (p *exprParser) parseUnary() (func(common.DNSRecord) bool, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNot:
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r common.DNSRecord) bool { return !inner(r) }, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("位置 %d: 缺少右括号", closing.pos)
+		}
+		return inner, nil
+	case tokWord:
+		if p.peek().kind == tokOp {
+			op := p.next()
+			value := p.next()
+			if value.kind != tokWord && value.kind != tokString {
+				return nil, fmt.Errorf("位置 %d: %s 之后缺少比较值", value.pos, op.text)
+			}
+			return compileComparison(tok, op, value.text)
+		}
+		keyword, known := filterKeywords[strings.ToLower(tok.text)]
+		if !known {
+			return nil, fmt.Errorf("位置 %d: 未知的关键字 %s", tok.pos, tok.text)
+		}
+		return keyword, nil
+	case tokEOF:
+		return nil, fmt.Errorf("位置 %d: 表达式不完整", tok.pos)
+	default:
+		return nil, fmt.Errorf("位置 %d: 意外的 %q", tok.pos, tok.text)
+	}
+}
+
+// 编译单个比较条件
+func compileComparison(fieldTok, op exprToken, value string) (func(common.DNSRecord) bool, error) {
+	field := strings.ToLower(fieldTok.text)
+	if number, ok := exprNumberFields[field]; ok && op.text != "~" && op.text != "!~" && op.text != "=~" {
+		want, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: %s 需要数值，而不是 %q", op.pos, field, value)
+		}
+		test, err := compareFunc(op)
+		if err != nil {
+			return nil, err
+		}
+		return func(r common.DNSRecord) bool { return test(cmp.Compare(number(r), want)) }, nil
+	}
+
+	getter, ok := filterFields[field]
+	if !ok {
+		getter, ok = exprStringFields[field]
+	}
+	if !ok {
+		if number, isNumber := exprNumberFields[field]; isNumber {
+			getter = func(r common.DNSRecord) string { return strconv.FormatUint(number(r), 10) }
+		} else {
+			return nil, fmt.Errorf("位置 %d: 未知的字段 %s", fieldTok.pos, fieldTok.text)
+		}
+	}
+
+	switch op.text {
+	case "~", "!~":
+		pattern, err := compileWildcard(value)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的通配模式 %q: %v", op.pos, value, err)
+		}
+		negate := op.text == "!~"
+		return func(r common.DNSRecord) bool { return pattern.MatchString(getter(r)) != negate }, nil
+	case "=~":
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的正则表达式 %q: %v", op.pos, value, err)
+		}
+		return func(r common.DNSRecord) bool { return pattern.MatchString(getter(r)) }, nil
+	case "in":
+		if !exprIPFields[field] {
+			return nil, fmt.Errorf("位置 %d: 字段 %s 不是 IP 地址，不能使用 in", op.pos, field)
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的网段 %q", op.pos, value)
+		}
+		return func(r common.DNSRecord) bool {
+			ip := net.ParseIP(getter(r))
+			return ip != nil && network.Contains(ip)
+		}, nil
+	case "==":
+		return func(r common.DNSRecord) bool { return strings.EqualFold(getter(r), value) }, nil
+	case "!=":
+		return func(r common.DNSRecord) bool { return !strings.EqualFold(getter(r), value) }, nil
+	}
+
+	test, err := compareFunc(op)
+	if err != nil {
+		return nil, err
+	}
+	return func(r common.DNSRecord) bool { return test(strings.Compare(getter(r), value)) }, nil
+}
+
+// 将比较运算符转换为对比较结果（-1、0、1）的判断
+func compareFunc(op exprToken) (func(int) bool, error) {
+	switch op.text {
+	case "==":
+		return func(c int) bool { return c == 0 }, nil
+	case "!=":
+		return func(c int) bool { return c != 0 }, nil
+	case "<":
+		return func(c int) bool { return c < 0 }, nil
+	case "<=":
+		return func(c int) bool { return c <= 0 }, nil
+	case ">":
+		return func(c int) bool { return c > 0 }, nil
+	case ">=":
+		return func(c int) bool { return c >= 0 }, nil
+	}
+	return nil, fmt.Errorf("位置 %d: 运算符 %s 不适用于该字段", op.pos, op.text)
+}
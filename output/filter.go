@@ -0,0 +1,168 @@
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// Filter 是附加在单个输出端上的过滤表达式
+//
+// 表达式由空白分隔的若干条件组成，所有条件均满足才匹配，条件前加 ! 表示取反：
+//
+//	nxdomain            域名不存在
+//	error               查询失败
+//	minimized           QNAME 最小化的部分查询
+//	canary              命中诱饵域名
+//	alert               命中告警规则
+//	suspicious          疑似算法生成（DGA）或隧道编码的域名
+//	large               报文长度超过 -large-message 阈值
+//	connected           解析后连接了结果地址的关联事件
+//	suspicious-port     关联事件中连接的端口不在常用端口列表中
+//	baseline            (进程, 解析器) 组合首次出现
+//	encrypted           加密 DNS 流量（如 DoQ）
+//	response            收到的响应（Linux 启用 -capture-responses 时）
+//	name=*.example.com  按字段匹配，支持 * 通配，不区分大小写
+//
+// 可用字段：name、type、status、rcode、proc、path、pid、tid、ip、proto
+type Filter struct {
+	expr  string
+	terms []filterTerm
+}
+
+// 单个过滤条件
+type filterTerm struct {
+	negate bool
+	match  func(common.DNSRecord) bool
+}
+
+// 关键字条件
+var filterKeywords = map[string]func(common.DNSRecord) bool{
+	"nxdomain": func(r common.DNSRecord) bool {
+		if r.Rcode == "NXDOMAIN" {
+			return true
+		}
+		status := strings.ToLower(r.Status)
+		return strings.Contains(status, "does not exist") || strings.Contains(status, "nxdomain")
+	},
+	"error": func(r common.DNSRecord) bool {
+		return strings.HasPrefix(r.Status, "ERROR")
+	},
+	"minimized": func(r common.DNSRecord) bool {
+		return r.QNameMinimization
+	},
+	"canary": func(r common.DNSRecord) bool {
+		return r.Canary
+	},
+	"alert": func(r common.DNSRecord) bool {
+		return r.Alert
+	},
+	"suspicious": func(r common.DNSRecord) bool {
+		return r.Suspicious
+	},
+	"large": func(r common.DNSRecord) bool {
+		return r.LargeMessage
+	},
+	"connected": func(r common.DNSRecord) bool {
+		return r.Connection != nil
+	},
+	"suspicious-port": func(r common.DNSRecord) bool {
+		return r.Connection != nil && r.Connection.Suspicious
+	},
+	"baseline": func(r common.DNSRecord) bool {
+		return r.Baseline
+	},
+	"encrypted": func(r common.DNSRecord) bool {
+		return r.EncryptedDNS != ""
+	},
+	"response": func(r common.DNSRecord) bool {
+		return r.Response
+	},
+}
+
+// 可匹配的记录字段
+var filterFields = map[string]func(common.DNSRecord) string{
+	"name":   func(r common.DNSRecord) string { return r.QueryName },
+	"type":   func(r common.DNSRecord) string { return r.QueryType },
+	"status": func(r common.DNSRecord) string { return r.Status },
+	"rcode":  func(r common.DNSRecord) string { return r.Rcode },
+	"proc":   func(r common.DNSRecord) string { return r.ProcessName },
+	"path":   func(r common.DNSRecord) string { return r.ProcessPath },
+	"pid":    func(r common.DNSRecord) string { return strconv.FormatUint(uint64(r.ProcessID), 10) },
+	"tid":    func(r common.DNSRecord) string { return strconv.FormatUint(uint64(r.ThreadID), 10) },
+	"ip":     func(r common.DNSRecord) string { return r.ClientIP },
+	"proto":  func(r common.DNSRecord) string { return r.Protocol },
+}
+
+// ParseFilter 解析过滤表达式，空表达式返回 nil（匹配全部）
+func ParseFilter(expr string) (*Filter, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	f := &Filter{expr: expr}
+	for _, field := range fields {
+		term := filterTerm{}
+		if strings.HasPrefix(field, "!") {
+			term.negate = true
+			field = field[1:]
+		}
+
+		if key, value, ok := strings.Cut(field, "="); ok {
+			getter, known := filterFields[strings.ToLower(key)]
+			if !known {
+				return nil, fmt.Errorf("未知的过滤字段: %s", key)
+			}
+			pattern, err := compileWildcard(value)
+			if err != nil {
+				return nil, fmt.Errorf("无效的过滤值 %q: %v", value, err)
+			}
+			term.match = func(r common.DNSRecord) bool {
+				return pattern.MatchString(getter(r))
+			}
+		} else {
+			keyword, known := filterKeywords[strings.ToLower(field)]
+			if !known {
+				return nil, fmt.Errorf("未知的过滤关键字: %s", field)
+			}
+			term.match = keyword
+		}
+
+		f.terms = append(f.terms, term)
+	}
+	return f, nil
+}
+
+// 将 * 通配模式编译为不区分大小写的完整匹配正则
+func compileWildcard(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.Compile("(?i)^" + strings.Join(parts, ".*") + "$")
+}
+
+// Match 判断记录是否满足过滤条件，nil 过滤器匹配全部记录
+func (f *Filter) Match(record common.DNSRecord) bool {
+	if f == nil {
+		return true
+	}
+	for _, term := range f.terms {
+		if term.match(record) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// String 返回原始表达式
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
@@ -0,0 +1,199 @@
+package output
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// GELF 输出参数
+const (
+	gelfQueueSize   = 1024
+	gelfChunkSize   = 1420 // 单个 UDP 分片的最大负载，低于常见 MTU
+	gelfMaxChunks   = 128  // GELF 规定的最大分片数
+	gelfChunkHeader = 12   // 魔数(2) + 消息ID(8) + 序号(1) + 总数(1)
+	gelfDialTimeout = 5 * time.Second
+)
+
+// GELF 分片魔数
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// syslog 级别：6 为 informational，4 为 warning
+const (
+	gelfLevelInfo    = 6
+	gelfLevelWarning = 4
+)
+
+// GELFSink 将记录以 Graylog GELF 1.1 格式经 UDP 或 TCP 发送
+type GELFSink struct {
+	network string
+	addr    string
+	host    string
+	conn    net.Conn
+	queue   chan common.DNSRecord
+	wg      sync.WaitGroup
+}
+
+// NewGELFSink 创建 GELF 输出端，target 形如 udp://graylog:12201 或 tcp://graylog:12201
+func NewGELFSink(target string) (*GELFSink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("不支持的 GELF 传输协议 %q，应为 udp 或 tcp", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("GELF 地址缺少主机: %s", target)
+	}
+
+	host, _ := os.Hostname()
+	s := &GELFSink{
+		network: u.Scheme,
+		addr:    u.Host,
+		host:    host,
+		queue:   make(chan common.DNSRecord, gelfQueueSize),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// 后台发送队列中的记录，TCP 连接断开时在下一条记录前重连
+func (s *GELFSink) run() {
+	defer s.wg.Done()
+	for record := range s.queue {
+		if err := s.send(record); err != nil {
+			common.Errorf("GELF 发送失败: %v", err)
+			if s.conn != nil {
+				s.conn.Close()
+				s.conn = nil
+			}
+		}
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// 将记录转换为 GELF 消息，记录字段以 _ 前缀作为附加字段
+func (s *GELFSink) message(record common.DNSRecord) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	level := gelfLevelInfo
+	if strings.HasPrefix(record.Status, "ERROR") {
+		level = gelfLevelWarning
+	}
+
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          s.host,
+		"short_message": fmt.Sprintf("DNS %s %s", record.QueryType, record.QueryName),
+		"timestamp":     float64(record.Timestamp.UnixNano()) / 1e9,
+		"level":         level,
+	}
+	for key, value := range fields {
+		// timestamp 已作为标准字段，_id 为 GELF 保留字段
+		if key == "timestamp" || key == "id" {
+			continue
+		}
+		// 附加字段只能是字符串或数字
+		switch v := value.(type) {
+		case string, float64:
+			msg["_"+key] = v
+		case bool:
+			msg["_"+key] = fmt.Sprint(v)
+		default:
+			nested, _ := json.Marshal(v)
+			msg["_"+key] = string(nested)
+		}
+	}
+	return json.Marshal(msg)
+}
+
+// 发送单条记录
+func (s *GELFSink) send(record common.DNSRecord) error {
+	data, err := s.message(record)
+	if err != nil {
+		return err
+	}
+
+	if s.conn == nil {
+		if s.conn, err = net.DialTimeout(s.network, s.addr, gelfDialTimeout); err != nil {
+			return err
+		}
+	}
+
+	if s.network == "tcp" {
+		// TCP 传输以空字节分隔消息
+		_, err = s.conn.Write(append(data, 0))
+		return err
+	}
+	return s.writeChunked(data)
+}
+
+// UDP 传输，超过单个数据报大小的消息按 GELF 分片格式发送
+func (s *GELFSink) writeChunked(data []byte) error {
+	if len(data) <= gelfChunkSize {
+		_, err := s.conn.Write(data)
+		return err
+	}
+
+	payload := gelfChunkSize - gelfChunkHeader
+	count := (len(data) + payload - 1) / payload
+	if count > gelfMaxChunks {
+		return fmt.Errorf("消息过大（%d 字节），超过 GELF 分片上限", len(data))
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	var chunk bytes.Buffer
+	for i := 0; i < count; i++ {
+		end := min((i+1)*payload, len(data))
+		chunk.Reset()
+		chunk.Write(gelfChunkMagic)
+		chunk.Write(id)
+		chunk.WriteByte(byte(i))
+		chunk.WriteByte(byte(count))
+		chunk.Write(data[i*payload : end])
+		if _, err := s.conn.Write(chunk.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write 实现 Sink 接口，记录进入发送队列后立即返回
+func (s *GELFSink) Write(record common.DNSRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("发送队列已满，丢弃记录 %s", record.QueryName)
+	}
+}
+
+// Close 实现 Sink 接口，等待队列中的记录发送完毕
+func (s *GELFSink) Close() error {
+	close(s.queue)
+	s.wg.Wait()
+	return nil
+}
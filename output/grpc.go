@@ -0,0 +1,244 @@
+package output
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// gRPC 输出参数
+const (
+	grpcQueueSize  = 4096
+	grpcMinBackoff = time.Second
+	grpcMaxBackoff = 30 * time.Second
+	grpcAckTimeout = 5 * time.Second
+	grpcStreamName = "/dnsflux.v1.DNSEventCollector/Stream"
+)
+
+// GRPCSink 通过长连接的客户端流 RPC 逐条发送记录，服务定义见 proto/dnsflux.proto
+// 消息直接按 protobuf 线格式编码，不依赖生成代码
+type GRPCSink struct {
+	target string
+	creds  credentials.TransportCredentials
+	host   string
+	queue  chan common.DNSRecord
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewGRPCSink 创建 gRPC 输出端，target 为 host:port，useTLS 为 false 时使用明文连接
+func NewGRPCSink(target string, useTLS bool) *GRPCSink {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &GRPCSink{
+		target: target,
+		creds:  creds,
+		host:   host,
+		queue:  make(chan common.DNSRecord, grpcQueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// 维护连接和流，出错时退避重连，发送失败的记录在重连后重发
+func (s *GRPCSink) run() {
+	defer s.wg.Done()
+
+	conn, err := grpc.NewClient(s.target,
+		grpc.WithTransportCredentials(s.creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		common.Errorf("gRPC 连接 %s 失败: %v", s.target, err)
+		for range s.queue {
+			common.Stats.Dropped.Add(1)
+		}
+		return
+	}
+	defer conn.Close()
+
+	var (
+		stream  grpc.ClientStream
+		pending *common.DNSRecord
+		backoff = grpcMinBackoff
+	)
+
+	for {
+		if pending == nil {
+			record, ok := <-s.queue
+			if !ok {
+				break
+			}
+			pending = &record
+		}
+
+		if stream == nil {
+			if stream, err = conn.NewStream(s.ctx, &grpc.StreamDesc{ClientStreams: true}, grpcStreamName); err != nil {
+				common.Warnf("gRPC 建立流失败: %v，%s 后重试", err, backoff)
+				if !s.sleep(backoff) {
+					break
+				}
+				backoff = min(backoff*2, grpcMaxBackoff)
+				continue
+			}
+		}
+
+		msg := encodeDNSEvent(*pending, s.host)
+		if err := stream.SendMsg(&msg); err != nil {
+			common.Warnf("gRPC 发送失败: %v，%s 后重连", err, backoff)
+			stream = nil
+			if !s.sleep(backoff) {
+				break
+			}
+			backoff = min(backoff*2, grpcMaxBackoff)
+			continue
+		}
+		pending = nil
+		backoff = grpcMinBackoff
+	}
+
+	// 正常结束流并等待服务端确认
+	if stream != nil {
+		if err := stream.CloseSend(); err == nil {
+			done := make(chan struct{})
+			go func() {
+				var ack []byte
+				stream.RecvMsg(&ack)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(grpcAckTimeout):
+			}
+		}
+	}
+}
+
+// 退避等待，关闭时提前返回 false
+func (s *GRPCSink) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// 按 proto/dnsflux.proto 中的 DNSEvent 编码记录
+func encodeDNSEvent(r common.DNSRecord, host string) []byte {
+	var b []byte
+	appendString := func(num protowire.Number, v string) {
+		if v != "" {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		}
+	}
+	appendVarint := func(num protowire.Number, v uint64) {
+		if v != 0 {
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, v)
+		}
+	}
+
+	appendVarint(1, uint64(r.Timestamp.UnixNano()))
+	appendString(2, r.QueryName)
+	appendString(3, r.QueryType)
+	appendString(4, r.QueryResult)
+	appendVarint(5, uint64(r.ProcessID))
+	appendString(6, r.ProcessName)
+	appendString(7, r.ProcessPath)
+	appendString(8, r.ClientIP)
+	appendString(9, r.Status)
+	appendString(10, r.Rcode)
+	appendString(11, r.Protocol)
+	appendVarint(12, uint64(r.ThreadID))
+	appendVarint(13, uint64(r.EventID))
+	appendVarint(14, r.NetNS)
+	appendVarint(15, r.SocketCookie)
+	appendString(16, r.Severity)
+	for _, note := range r.Notes {
+		b = protowire.AppendTag(b, 17, protowire.BytesType)
+		b = protowire.AppendString(b, note)
+	}
+	for _, hop := range r.ResolutionPath {
+		b = protowire.AppendTag(b, 18, protowire.BytesType)
+		b = protowire.AppendString(b, hop)
+	}
+	appendString(19, host)
+	return b
+}
+
+// rawCodec 直接收发已编码的 protobuf 字节
+type rawCodec struct{}
+
+// Marshal 实现 encoding.Codec 接口
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("不支持的消息类型 %T", v)
+	}
+	return *b, nil
+}
+
+// Unmarshal 实现 encoding.Codec 接口
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("不支持的消息类型 %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name 实现 encoding.Codec 接口，按 proto 编码声明内容类型
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// Write 实现 Sink 接口，记录进入发送队列后立即返回
+func (s *GRPCSink) Write(record common.DNSRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("发送队列已满，丢弃记录 %s", record.QueryName)
+	}
+}
+
+// Close 实现 Sink 接口，尽量发送完队列中的记录，连接不可用时放弃
+func (s *GRPCSink) Close() error {
+	close(s.queue)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grpcAckTimeout):
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+	return nil
+}
@@ -4,13 +4,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
-var logFile *os.File
+var (
+	logFile   *os.File
+	logFileMu sync.Mutex
+	// 日志文件尚无内容，需要写入表头
+	logFileEmpty bool
+)
 
 // InitLogger 初始化日志记录器
 func InitLogger() error {
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+	return initLogger()
+}
+
+// 打开当天的日志文件，调用方需持有 logFileMu
+func initLogger() error {
 	// 创建logs目录
 	logsDir := "logs"
 	if err := os.MkdirAll(logsDir, 0755); err != nil {
@@ -27,29 +41,56 @@ func InitLogger() error {
 		return fmt.Errorf("打开日志文件失败: %v", err)
 	}
 
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+
 	// 如果之前有打开的日志文件，关闭它
 	if logFile != nil {
 		logFile.Close()
 	}
 
 	logFile = file
+	logFileEmpty = info.Size() == 0
 	return nil
 }
 
-// WriteLog 写入日志条目
+// WriteLog 写入日志条目，条目末尾没有换行时补上
 func WriteLog(logEntry string) error {
+	return writeLog("", logEntry)
+}
+
+// 写入日志条目，文件为空时先写入表头
+func writeLog(header, logEntry string) error {
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+
 	if logFile == nil {
-		if err := InitLogger(); err != nil {
+		if err := initLogger(); err != nil {
 			return fmt.Errorf("初始化日志记录器失败: %v", err)
 		}
 	}
 
-	_, err := fmt.Fprintln(logFile, logEntry)
+	if header != "" && logFileEmpty {
+		if _, err := logFile.WriteString(header); err != nil {
+			return err
+		}
+	}
+	if !strings.HasSuffix(logEntry, "\n") {
+		logEntry += "\n"
+	}
+	_, err := logFile.WriteString(logEntry)
+	logFileEmpty = false
 	return err
 }
 
 // Close 关闭日志文件
 func Close() {
+	logFileMu.Lock()
+	defer logFileMu.Unlock()
+
 	if logFile != nil {
 		logFile.Close()
 		logFile = nil
@@ -0,0 +1,116 @@
+package output
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 查询计数最多区分的 (进程名, 查询类型) 组合数，超出后新组合的进程名记为 other，避免标签基数无限增长
+const maxMetricSeries = 1000
+
+// 指标和流式服务关闭时等待进行中请求的时间
+const httpShutdownTimeout = 5 * time.Second
+
+// 查询计数的标签，不使用查询域名以控制基数
+type metricKey struct {
+	process string
+	qtype   string
+}
+
+// MetricsSink 统计输出的记录，以 Prometheus 文本格式提供指标
+type MetricsSink struct {
+	mu      sync.Mutex
+	queries map[metricKey]uint64
+}
+
+// NewMetricsSink 创建指标输出端
+func NewMetricsSink() *MetricsSink {
+	return &MetricsSink{queries: make(map[metricKey]uint64)}
+}
+
+// Write 实现 Sink 接口
+func (m *MetricsSink) Write(record common.DNSRecord) error {
+	key := metricKey{process: record.ProcessName, qtype: record.QueryType}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.queries[key]; !ok && len(m.queries) >= maxMetricSeries {
+		key.process = "other"
+	}
+	m.queries[key]++
+	return nil
+}
+
+// Close 实现 Sink 接口
+func (m *MetricsSink) Close() error {
+	return nil
+}
+
+// 转义标签值中的 \、" 和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP 以 Prometheus 文本格式输出指标
+func (m *MetricsSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	counts := maps.Clone(m.queries)
+	m.mu.Unlock()
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b metricKey) int {
+		return cmp.Or(strings.Compare(a.process, b.process), strings.Compare(a.qtype, b.qtype))
+	})
+
+	var b strings.Builder
+	counter := func(name, help string, value uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+
+	var total uint64
+	fmt.Fprintf(&b, "# HELP dnsflux_queries_total 按进程名和查询类型统计的输出记录数\n# TYPE dnsflux_queries_total counter\n")
+	for _, key := range keys {
+		total += counts[key]
+		fmt.Fprintf(&b, "dnsflux_queries_total{process=\"%s\",type=\"%s\"} %d\n",
+			labelEscaper.Replace(key.process), labelEscaper.Replace(key.qtype), counts[key])
+	}
+	counter("dnsflux_records_total", "输出的 DNS 记录总数", total)
+	counter("dnsflux_events_processed_total", "提交到处理流程的事件数", common.Stats.Processed.Load())
+	counter("dnsflux_events_filtered_total", "被域名、进程过滤条件或处理环节丢弃的事件数", common.Stats.Filtered.Load())
+	counter("dnsflux_events_dropped_total", "因错误或队列已满丢失的事件数", common.Stats.Dropped.Load())
+	counter("dnsflux_parse_errors_total", "无法解析的事件或 DNS 报文数", common.Stats.ParseErrors.Load())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// ServeMetrics 在 addr 上提供 /metrics，监听失败时返回错误，ctx 取消时关闭服务
+func ServeMetrics(ctx context.Context, addr string, handler http.Handler) error {
+	return serveHTTP(ctx, addr, "/metrics", handler)
+}
+
+// 在 addr 上以 pattern 提供 handler，监听失败时返回错误，ctx 取消时关闭服务
+func serveHTTP(ctx context.Context, addr, pattern string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(pattern, handler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go server.Serve(listener)
+	context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	})
+	return nil
+}
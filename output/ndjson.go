@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+	"unicode"
+
+	"dnsflux/common"
+)
+
+// FormatNDJSON 将记录格式化为一行 JSON，供 ELK、Loki 等按行采集。
+// 键名为记录 JSON 字段名的 snake_case 形式（如 query_name、process_path），
+// 时间戳为带时区偏移的 RFC3339 格式。可作为 ConsoleSink 或 FileSink 的 Format
+func FormatNDJSON(record common.DNSRecord) string {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return ""
+	}
+
+	converted := snakeKeys(fields).(map[string]any)
+	converted["timestamp"] = record.Timestamp.Format(time.RFC3339Nano)
+	if record.ProcessStartTime.IsZero() {
+		delete(converted, "process_start_time")
+	} else {
+		converted["process_start_time"] = record.ProcessStartTime.Format(time.RFC3339Nano)
+	}
+
+	line, err := json.Marshal(converted)
+	if err != nil {
+		return ""
+	}
+	return string(line) + "\n"
+}
+
+// 递归地将对象的键转换为 snake_case
+func snakeKeys(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[snakeCase(key)] = snakeKeys(item)
+		}
+		return out
+	case []any:
+		for i, item := range v {
+			v[i] = snakeKeys(item)
+		}
+		return v
+	}
+	return value
+}
+
+// 将 camelCase 转换为 snake_case，连续的大写字母视为一个词，如 clientIP -> client_ip
+func snakeCase(s string) string {
+	runes := []rune(s)
+	out := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && !unicode.IsUpper(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				out = append(out, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
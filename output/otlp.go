@@ -0,0 +1,210 @@
+package output
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// OTLP 导出参数
+const (
+	otlpQueueSize      = 1024            // 待导出 span 队列长度，满时丢弃
+	otlpBatchSize      = 128             // 单次导出的最大 span 数
+	otlpFlushInterval  = 2 * time.Second // 未满一批时的导出间隔
+	otlpSpanKindClient = 3               // SPAN_KIND_CLIENT
+)
+
+// OTLPSink 将每次 DNS 查询作为一个 span，以 OTLP/HTTP JSON 格式导出
+type OTLPSink struct {
+	url     string
+	service string
+	client  *http.Client
+	queue   chan common.DNSRecord
+	wg      sync.WaitGroup
+}
+
+// NewOTLPSink 创建 OTLP 输出端，endpoint 为 collector 地址，如 http://localhost:4318
+func NewOTLPSink(endpoint, service string) *OTLPSink {
+	s := &OTLPSink{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		queue:   make(chan common.DNSRecord, otlpQueueSize),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// 按批次或定时导出
+func (s *OTLPSink) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	var batch []common.DNSRecord
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.export(batch); err != nil {
+			common.Errorf("OTLP 导出失败: %v", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case record, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// OTLP JSON 结构（仅包含用到的字段）
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            struct {
+		Code int `json:"code,omitempty"`
+	} `json:"status"`
+}
+
+// 字符串属性
+func stringAttr(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+// 整数属性，OTLP JSON 中 int64 以字符串表示
+func intAttr(key string, value int64) otlpAttribute {
+	v := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpAnyValue{IntValue: &v}}
+}
+
+// 随机生成指定字节数的十六进制 ID
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 将记录转换为 span
+func recordToSpan(record common.DNSRecord) otlpSpan {
+	start := record.Timestamp.UnixNano()
+	span := otlpSpan{
+		TraceID:           randomID(16),
+		SpanID:            randomID(8),
+		Name:              "DNS " + record.QueryType,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(start, 10),
+		EndTimeUnixNano:   strconv.FormatInt(start, 10),
+		Attributes: []otlpAttribute{
+			stringAttr("dns.question.name", record.QueryName),
+			stringAttr("dns.question.type", record.QueryType),
+			intAttr("process.pid", int64(record.ProcessID)),
+			stringAttr("process.executable.name", record.ProcessName),
+			stringAttr("process.executable.path", record.ProcessPath),
+		},
+	}
+	if record.Status != "" {
+		span.Attributes = append(span.Attributes, stringAttr("dns.status", record.Status))
+	}
+	if record.QueryResult != "" && record.QueryResult != "-" {
+		span.Attributes = append(span.Attributes, stringAttr("dns.answers", record.QueryResult))
+	}
+	if strings.HasPrefix(record.Status, "ERROR") {
+		span.Status.Code = 2 // STATUS_CODE_ERROR
+	}
+	return span
+}
+
+// 导出一批 span
+func (s *OTLPSink) export(batch []common.DNSRecord) error {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, record := range batch {
+		spans = append(spans, recordToSpan(record))
+	}
+
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpAttribute{stringAttr("service.name", s.service)},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "dnsflux"},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Write 实现 Sink 接口，记录进入导出队列后立即返回
+func (s *OTLPSink) Write(record common.DNSRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("导出队列已满，丢弃记录 %s", record.QueryName)
+	}
+}
+
+// Close 实现 Sink 接口，导出剩余的 span
+func (s *OTLPSink) Close() error {
+	close(s.queue)
+	s.wg.Wait()
+	return nil
+}
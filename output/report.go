@@ -0,0 +1,127 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"dnsflux/common"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// 报表最多统计的域名数量，超出后新域名不再计入
+const maxReportDomains = 100000
+
+// ReportSink 在运行期间按域名累计查询次数，关闭时写出 CSV 报表
+type ReportSink struct {
+	path string
+
+	mu      sync.Mutex
+	domains map[string]*domainReport
+}
+
+// 单个域名的统计
+type domainReport struct {
+	total     int
+	types     map[string]int
+	processes map[string]bool
+}
+
+// NewReportSink 创建报表输出端，程序正常退出时写入 path
+func NewReportSink(path string) *ReportSink {
+	return &ReportSink{
+		path:    path,
+		domains: make(map[string]*domainReport),
+	}
+}
+
+// Write 实现 Sink 接口
+func (s *ReportSink) Write(record common.DNSRecord) error {
+	name := strings.TrimSuffix(strings.ToLower(record.QueryName), ".")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.domains[name]
+	if !ok {
+		if len(s.domains) >= maxReportDomains {
+			return nil
+		}
+		d = &domainReport{types: make(map[string]int), processes: make(map[string]bool)}
+		s.domains[name] = d
+	}
+	d.total++
+	d.types[record.QueryType]++
+	if record.ProcessName != "" {
+		d.processes[record.ProcessName] = true
+	}
+	return nil
+}
+
+// Close 实现 Sink 接口，写出报表
+func (s *ReportSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"domain", "registrable_domain", "total", "types", "processes"})
+
+	counts := make(map[string]int, len(s.domains))
+	for name, d := range s.domains {
+		counts[name] = d.total
+	}
+	for _, e := range topEntries(counts, 0) {
+		d := s.domains[e.key]
+		w.Write([]string{
+			e.key,
+			registrableDomain(e.key),
+			strconv.Itoa(d.total),
+			formatTypeCounts(d.types),
+			strings.Join(sortedKeys(d.processes), ";"),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 可注册域名（公共后缀加一级），无法判断时返回原域名
+func registrableDomain(name string) string {
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		return domain
+	}
+	return name
+}
+
+// 按次数降序格式化各查询类型，如 A:10;AAAA:3
+func formatTypeCounts(types map[string]int) string {
+	parts := make([]string, 0, len(types))
+	for _, e := range topEntries(types, 0) {
+		parts = append(parts, fmt.Sprintf("%s:%d", e.key, e.count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// 排序后的集合元素
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
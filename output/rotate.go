@@ -0,0 +1,210 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 轮转文件的时间后缀，按字典序即按时间排序
+const rotateSuffixLayout = "20060102-150405"
+
+// RotatingFileSink 将记录写入指定文件，按大小和/或日期轮转，只保留最近的若干个旧文件
+type RotatingFileSink struct {
+	path       string
+	format     func(common.DNSRecord) string
+	maxSize    int64 // 字节，0 表示不按大小轮转
+	daily      bool
+	maxBackups int // 0 表示保留全部旧文件
+	header     string
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time // 当前文件的打开日期，用于按天轮转
+}
+
+// NewRotatingFileSink 创建轮转文件输出端，maxSize 为 0 且 daily 为 false 时不轮转
+func NewRotatingFileSink(path string, format func(common.DNSRecord) string, maxSize int64, daily bool, maxBackups int) (*RotatingFileSink, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	s := &RotatingFileSink{
+		path:       path,
+		format:     format,
+		maxSize:    maxSize,
+		daily:      daily,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetHeader 设置表头（如 CSVHeader），当前文件为空时立即写入，之后轮转出的每个新文件开头各写入一次
+func (s *RotatingFileSink) SetHeader(header string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.header = header
+	return s.writeHeader()
+}
+
+// 文件为空时写入表头，调用方需持有 mu
+func (s *RotatingFileSink) writeHeader() error {
+	if s.header == "" || s.size > 0 || s.file == nil {
+		return nil
+	}
+	n, err := s.file.WriteString(s.header)
+	s.size += int64(n)
+	return err
+}
+
+// 以追加模式打开文件，调用方需持有 mu
+func (s *RotatingFileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	// 沿用已有文件时以其修改时间判断是否跨天
+	s.opened = time.Now()
+	if s.size > 0 {
+		s.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write 实现 Sink 接口
+func (s *RotatingFileSink) Write(record common.DNSRecord) error {
+	line := s.format(record)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("文件 %s 已关闭", s.path)
+	}
+	if s.shouldRotate(len(line)) {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("轮转文件 %s 失败: %v", s.path, err)
+		}
+	}
+	n, err := s.file.WriteString(line)
+	s.size += int64(n)
+	return err
+}
+
+// 写入 n 字节前是否需要轮转，空文件不轮转以免单条记录超过上限时反复轮转
+func (s *RotatingFileSink) shouldRotate(n int) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.maxSize > 0 && s.size+int64(n) > s.maxSize {
+		return true
+	}
+	if s.daily {
+		y1, m1, d1 := s.opened.Date()
+		y2, m2, d2 := time.Now().Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// 将当前文件重命名为带时间后缀的旧文件并打开新文件，调用方需持有 mu
+func (s *RotatingFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+
+	backup := s.path + "." + time.Now().Format(rotateSuffixLayout)
+	// 同一秒内多次轮转时追加序号
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.%s.%d", s.path, time.Now().Format(rotateSuffixLayout), i)
+	}
+	if err := os.Rename(s.path, backup); err != nil {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	s.removeOldBackups()
+	return nil
+}
+
+// 删除超出保留数量的旧文件
+func (s *RotatingFileSink) removeOldBackups() {
+	if s.maxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return
+	}
+	// 只处理带时间后缀的文件
+	var backups []string
+	for _, name := range matches {
+		suffix := strings.TrimPrefix(name, s.path+".")
+		if len(suffix) < len(rotateSuffixLayout) {
+			continue
+		}
+		if _, err := time.Parse(rotateSuffixLayout, suffix[:len(rotateSuffixLayout)]); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	if len(backups) <= s.maxBackups {
+		return
+	}
+	// 时间后缀相同时按序号排序，较短的序号在前，使 .10 排在 .9 之后
+	n := len(s.path) + 1 + len(rotateSuffixLayout)
+	sort.Slice(backups, func(i, j int) bool {
+		a, b := backups[i], backups[j]
+		if a[:n] == b[:n] && len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	for _, name := range backups[:len(backups)-s.maxBackups] {
+		os.Remove(name)
+	}
+}
+
+// Close 实现 Sink 接口，将数据落盘后关闭文件
+func (s *RotatingFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file = nil
+	return err
+}
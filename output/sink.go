@@ -0,0 +1,134 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+
+	"dnsflux/common"
+)
+
+// Sink 定义 DNS 记录的输出端
+type Sink interface {
+	Write(record common.DNSRecord) error
+	Close() error
+}
+
+// SinkFunc 将普通函数适配为无需关闭的输出端
+type SinkFunc func(record common.DNSRecord) error
+
+// Write 实现 Sink 接口
+func (f SinkFunc) Write(record common.DNSRecord) error {
+	return f(record)
+}
+
+// Close 实现 Sink 接口
+func (f SinkFunc) Close() error {
+	return nil
+}
+
+// 已注册的输出端及其过滤条件
+type route struct {
+	name   string
+	sink   Sink
+	filter *Filter
+}
+
+var (
+	routes   []route
+	routesMu sync.RWMutex
+)
+
+// Register 注册输出端，filter 为 nil 时接收全部记录
+func Register(name string, sink Sink, filter *Filter) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routes = append(routes, route{name: name, sink: sink, filter: filter})
+}
+
+// Emit 将记录分发给所有过滤条件匹配的输出端，命中诱饵域名的记录忽略过滤条件
+func Emit(record common.DNSRecord) {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	for _, r := range routes {
+		if !record.Canary && !r.filter.Match(record) {
+			continue
+		}
+		if err := r.sink.Write(record); err != nil {
+			common.Stats.Dropped.Add(1)
+			common.Errorf("输出到 %s 失败: %v", r.name, err)
+		}
+	}
+}
+
+// Names 返回已注册的输出端名称，带过滤条件的附加在方括号中
+func Names() []string {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	names := make([]string, 0, len(routes))
+	for _, r := range routes {
+		if expr := r.filter.String(); expr != "" {
+			names = append(names, fmt.Sprintf("%s[%s]", r.name, expr))
+		} else {
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// CloseAll 关闭并注销所有输出端
+func CloseAll() {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	for _, r := range routes {
+		if err := r.sink.Close(); err != nil {
+			common.Errorf("关闭输出 %s 失败: %v", r.name, err)
+		}
+	}
+	routes = nil
+}
+
+// ConsoleSink 将记录格式化后输出到控制台
+type ConsoleSink struct {
+	Format func(common.DNSRecord) string
+	// 在第一条记录之前输出一次的表头（如 CSVHeader），为空时不输出
+	Header string
+
+	headerOnce sync.Once
+}
+
+// Write 实现 Sink 接口
+func (s *ConsoleSink) Write(record common.DNSRecord) error {
+	s.headerOnce.Do(func() {
+		if s.Header != "" {
+			fmt.Print(s.Header)
+		}
+	})
+	_, err := fmt.Print(s.Format(record))
+	return err
+}
+
+// Close 实现 Sink 接口
+func (s *ConsoleSink) Close() error {
+	return nil
+}
+
+// FileSink 将记录格式化后写入按日期命名的日志文件
+type FileSink struct {
+	Format func(common.DNSRecord) string
+	// 日志文件为空时先写入的表头（如 CSVHeader），追加到已有内容的文件时不重复写入
+	Header string
+}
+
+// Write 实现 Sink 接口
+func (s *FileSink) Write(record common.DNSRecord) error {
+	return writeLog(s.Header, s.Format(record))
+}
+
+// Close 实现 Sink 接口
+func (s *FileSink) Close() error {
+	Close()
+	return nil
+}
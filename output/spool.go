@@ -0,0 +1,110 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 清理过期文件的间隔
+const spoolCleanupInterval = time.Minute
+
+// SpoolSink 将每条记录写成目录中的一个 JSON 文件，供监视目录的采集程序处理
+// 先写入 .tmp 文件再重命名，监视方不会读到写了一半的文件
+type SpoolSink struct {
+	dir       string
+	retention time.Duration
+	seq       atomic.Uint64
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewSpoolSink 创建目录输出端，retention 大于 0 时定期删除早于该时长的文件
+func NewSpoolSink(dir string, retention time.Duration) (*SpoolSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &SpoolSink{
+		dir:       dir,
+		retention: retention,
+		stop:      make(chan struct{}),
+	}
+	if retention > 0 {
+		s.wg.Add(1)
+		go s.cleanup()
+	}
+	return s, nil
+}
+
+// Write 实现 Sink 接口
+func (s *SpoolSink) Write(record common.DNSRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	// 文件名按时间排序，序号保证同一纳秒内不重名
+	name := fmt.Sprintf("dns-%d-%06d.json", time.Now().UnixNano(), s.seq.Add(1)%1000000)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// 定期删除过期文件
+func (s *SpoolSink) cleanup() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(spoolCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.removeExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// 删除修改时间早于保留期限的记录文件
+func (s *SpoolSink) removeExpired() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		common.Errorf("读取目录 %s 失败: %v", s.dir, err)
+		return
+	}
+
+	cutoff := time.Now().Add(-s.retention)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "dns-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, name))
+	}
+}
+
+// Close 实现 Sink 接口
+func (s *SpoolSink) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,194 @@
+package output
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite 输出参数
+const (
+	sqliteQueueSize       = 8192
+	sqliteBatchSize       = 500
+	sqliteFlushInterval   = time.Second
+	sqliteCleanupInterval = time.Hour
+	// 时间戳以 UTC 的该格式存储，可直接与 SQLite 的 datetime() 结果比较
+	sqliteTimeLayout = "2006-01-02 15:04:05.000000"
+)
+
+// 首次运行时创建的表和索引
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS dns_events (
+	id           INTEGER PRIMARY KEY,
+	timestamp    TEXT NOT NULL,
+	pid          INTEGER NOT NULL,
+	process_name TEXT NOT NULL,
+	process_path TEXT NOT NULL,
+	query_name   TEXT NOT NULL,
+	query_type   TEXT NOT NULL,
+	query_result TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	rcode        TEXT NOT NULL,
+	resolver_ip  TEXT NOT NULL,
+	severity     TEXT NOT NULL,
+	record       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS dns_events_timestamp ON dns_events (timestamp);
+CREATE INDEX IF NOT EXISTS dns_events_pid ON dns_events (pid);
+CREATE INDEX IF NOT EXISTS dns_events_process_name ON dns_events (process_name);
+CREATE INDEX IF NOT EXISTS dns_events_query_name ON dns_events (query_name);
+CREATE INDEX IF NOT EXISTS dns_events_query_type ON dns_events (query_type);
+`
+
+const sqliteInsert = `INSERT INTO dns_events
+	(timestamp, pid, process_name, process_path, query_name, query_type, query_result, status, rcode, resolver_ip, severity, record)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// SQLiteSink 将记录写入 SQLite 数据库，后台按批在单个事务中插入以应对突发查询，
+// 完整记录以 JSON 保存在 record 列
+type SQLiteSink struct {
+	db            *sql.DB
+	retentionDays int // 0 表示不删除
+	queue         chan common.DNSRecord
+	wg            sync.WaitGroup
+}
+
+// NewSQLiteSink 打开或创建数据库并建表，retentionDays 大于 0 时定期删除早于该天数的记录
+func NewSQLiteSink(path string, retentionDays int) (*SQLiteSink, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	// WAL 模式下查询数据库不会阻塞写入
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建表失败: %v", err)
+	}
+
+	s := &SQLiteSink{
+		db:            db,
+		retentionDays: retentionDays,
+		queue:         make(chan common.DNSRecord, sqliteQueueSize),
+	}
+	s.removeExpired()
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// 后台批量写入队列中的记录，批次写满或到达刷新间隔时提交
+func (s *SQLiteSink) run() {
+	defer s.wg.Done()
+	flush := time.NewTicker(sqliteFlushInterval)
+	defer flush.Stop()
+	cleanup := time.NewTicker(sqliteCleanupInterval)
+	defer cleanup.Stop()
+
+	batch := make([]common.DNSRecord, 0, sqliteBatchSize)
+	commit := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.insert(batch); err != nil {
+			common.Stats.Dropped.Add(uint64(len(batch)))
+			common.Errorf("写入 SQLite 失败，丢弃 %d 条记录: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case record, ok := <-s.queue:
+			if !ok {
+				commit()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= sqliteBatchSize {
+				commit()
+			}
+		case <-flush.C:
+			commit()
+		case <-cleanup.C:
+			s.removeExpired()
+		}
+	}
+}
+
+// 在单个事务中插入一批记录
+func (s *SQLiteSink) insert(batch []common.DNSRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(sqliteInsert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, record := range batch {
+		data, err := json.Marshal(record)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = stmt.Exec(record.Timestamp.UTC().Format(sqliteTimeLayout), record.ProcessID,
+			record.ProcessName, record.ProcessPath, record.QueryName, record.QueryType, record.QueryResult,
+			record.Status, record.Rcode, record.ResolverIP, record.Severity, string(data))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 删除早于保留天数的记录
+func (s *SQLiteSink) removeExpired() {
+	if s.retentionDays <= 0 {
+		return
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -s.retentionDays).Format(sqliteTimeLayout)
+	result, err := s.db.Exec("DELETE FROM dns_events WHERE timestamp < ?", cutoff)
+	if err != nil {
+		common.Warnf("清理 SQLite 过期记录失败: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		common.Infof("已删除 SQLite 中 %d 条超过 %d 天的记录", n, s.retentionDays)
+	}
+}
+
+// Write 实现 Sink 接口，记录进入写入队列后立即返回
+func (s *SQLiteSink) Write(record common.DNSRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("写入队列已满，丢弃记录 %s", record.QueryName)
+	}
+}
+
+// Close 实现 Sink 接口，写入队列中剩余的记录后关闭数据库
+func (s *SQLiteSink) Close() error {
+	close(s.queue)
+	s.wg.Wait()
+	return s.db.Close()
+}
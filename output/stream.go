@@ -0,0 +1,188 @@
+package output
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 每个流式客户端缓冲的记录数，写满后丢弃最旧的记录
+const streamClientBuffer = 256
+
+// 关闭时等待客户端写完已缓冲记录的时间
+const streamCloseTimeout = time.Second
+
+// StreamSink 通过 HTTP 向多个客户端实时推送记录：默认为 NDJSON（每行一条，同 -format json），
+// 请求头 Accept 为 text/event-stream 或带 ?format=sse 时为 Server-Sent Events。
+// 每个客户端有独立的缓冲，慢客户端只会丢失自己最旧的记录，不会阻塞采集
+type StreamSink struct {
+	token string
+
+	mu      sync.Mutex
+	clients map[*streamClient]struct{}
+	done    chan struct{}
+	closed  bool
+	active  sync.WaitGroup // 进行中的推送请求
+}
+
+// 单个流式客户端
+type streamClient struct {
+	records chan string
+	dropped uint64 // 因缓冲写满而丢弃的记录数，只在 Write 持有 mu 时修改
+}
+
+// NewStreamSink 创建流式输出端，token 不为空时客户端需以 Authorization: Bearer 或 ?token= 提供
+func NewStreamSink(token string) *StreamSink {
+	return &StreamSink{
+		token:   token,
+		clients: make(map[*streamClient]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Write 实现 Sink 接口，将记录放入每个客户端的缓冲，缓冲已满时丢弃其中最旧的一条
+func (s *StreamSink) Write(record common.DNSRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return nil
+	}
+	line := FormatNDJSON(record)
+	if line == "" {
+		return fmt.Errorf("无法序列化记录")
+	}
+	for c := range s.clients {
+		select {
+		case c.records <- line:
+		default:
+			select {
+			case <-c.records:
+				c.dropped++
+			default:
+			}
+			// 只有 Write 在持有 mu 时写入缓冲，此时必定有空位
+			c.records <- line
+		}
+	}
+	return nil
+}
+
+// Close 实现 Sink 接口，通知所有客户端写完已缓冲的记录后断开，最多等待 streamCloseTimeout
+func (s *StreamSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(streamCloseTimeout):
+	}
+	return nil
+}
+
+// 校验访问令牌
+func (s *StreamSink) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// ServeHTTP 持续推送记录，直到客户端断开或输出端关闭
+func (s *StreamSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+	sse := r.URL.Query().Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	client := &streamClient{records: make(chan string, streamClientBuffer)}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		http.Error(w, "服务正在关闭", http.StatusServiceUnavailable)
+		return
+	}
+	s.clients[client] = struct{}{}
+	s.active.Add(1)
+	s.mu.Unlock()
+	defer s.active.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		dropped := client.dropped
+		s.mu.Unlock()
+		if dropped > 0 {
+			common.Warnf("流式客户端 %s 读取过慢，共丢弃 %d 条记录", r.RemoteAddr, dropped)
+		}
+	}()
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	write := func(line string) error {
+		if sse {
+			line = "data: " + strings.TrimSuffix(line, "\n") + "\n\n"
+		}
+		_, err := fmt.Fprint(w, line)
+		return err
+	}
+	for {
+		select {
+		case line := <-client.records:
+			if write(line) != nil {
+				return
+			}
+			// 已缓冲的记录一并写出后再刷新
+			if len(client.records) == 0 {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			// 输出端关闭前写入的记录仍发送给客户端
+			for len(client.records) > 0 {
+				if write(<-client.records) != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// ServeStream 在 addr 上提供 /events，监听失败时返回错误，ctx 取消时断开客户端并关闭服务
+func ServeStream(ctx context.Context, addr string, stream *StreamSink) error {
+	context.AfterFunc(ctx, func() { stream.Close() })
+	return serveHTTP(ctx, addr, "/events", stream)
+}
@@ -0,0 +1,286 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// 汇总统计的域名、进程和查询类型数量上限，超出后新出现的只计入总数，避免内存无限增长
+const (
+	maxSummaryNames     = 10000
+	maxSummaryProcesses = 1000
+	maxSummaryTypes     = 64
+)
+
+// 汇总输出的默认规模
+const (
+	summaryTopN      = 10 // 默认显示的域名和进程数量
+	summaryTreeDepth = 3  // 树形模式下可注册域名之下最多展开的层数
+	summaryTreeWidth = 5  // 树形模式下每个节点最多显示的子节点数量
+)
+
+// SummarySink 累计查询域名、进程和查询类型，定期输出汇总，关闭时再输出一次
+type SummarySink struct {
+	// 是否按域名层级以树形展示
+	Tree bool
+	// 显示查询最多的前 TopN 个域名和进程
+	TopN int
+
+	mu        sync.Mutex
+	total     int
+	names     map[string]int
+	processes map[string]int
+	types     map[string]int
+	out       io.Writer
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewSummarySink 创建汇总输出端，每隔 interval 输出一次，topN <= 0 时使用默认的 10
+func NewSummarySink(interval time.Duration, tree bool, topN int) *SummarySink {
+	if topN <= 0 {
+		topN = summaryTopN
+	}
+	s := &SummarySink{
+		Tree:      tree,
+		TopN:      topN,
+		names:     make(map[string]int),
+		processes: make(map[string]int),
+		types:     make(map[string]int),
+		out:       os.Stdout,
+		stop:      make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run(interval)
+	return s
+}
+
+// 定期输出汇总
+func (s *SummarySink) run(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.print("DNS 查询汇总")
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Write 实现 Sink 接口
+func (s *SummarySink) Write(record common.DNSRecord) error {
+	name := strings.TrimSuffix(strings.ToLower(record.QueryName), ".")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total++
+	countBounded(s.names, name, maxSummaryNames)
+	countBounded(s.processes, summaryProcess(record), maxSummaryProcesses)
+	countBounded(s.types, cmp.Or(record.QueryType, "未知"), maxSummaryTypes)
+	return nil
+}
+
+// 累加计数，map 已满时不再加入新的键
+func countBounded(counts map[string]int, key string, limit int) {
+	if _, ok := counts[key]; ok || len(counts) < limit {
+		counts[key]++
+	}
+}
+
+// 汇总中的进程名，未知时以 PID 表示
+func summaryProcess(record common.DNSRecord) string {
+	if record.ProcessName != "" {
+		return record.ProcessName
+	}
+	return fmt.Sprintf("PID %d", record.ProcessID)
+}
+
+// Close 实现 Sink 接口，停止定期输出，有记录或过滤、丢弃计数时输出最终汇总
+func (s *SummarySink) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	seen := s.total > 0
+	s.mu.Unlock()
+	if seen || common.Stats.Filtered.Load() > 0 || common.Stats.Dropped.Load() > 0 {
+		s.print("DNS 查询汇总（退出）")
+	}
+	return nil
+}
+
+// Print 输出当前汇总
+func (s *SummarySink) Print() {
+	s.print("DNS 查询汇总")
+}
+
+func (s *SummarySink) print(title string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n==== %s %s，共 %d 次，%d 个域名，%d 个进程 ====\n",
+		title, time.Now().Format("2006-01-02 15:04:05"), s.total, len(s.names), len(s.processes))
+	fmt.Fprintf(&b, "域名:\n")
+	if s.Tree {
+		writeNameTree(&b, s.names, s.TopN)
+	} else {
+		for _, e := range topEntries(s.names, s.TopN) {
+			fmt.Fprintf(&b, "%6d  %s\n", e.count, e.key)
+		}
+	}
+	fmt.Fprintf(&b, "进程:\n")
+	for _, e := range topEntries(s.processes, s.TopN) {
+		fmt.Fprintf(&b, "%6d  %s\n", e.count, e.key)
+	}
+	types := make([]string, 0, len(s.types))
+	for _, e := range topEntries(s.types, 0) {
+		types = append(types, fmt.Sprintf("%s=%d", e.key, e.count))
+	}
+	fmt.Fprintf(&b, "类型: %s\n", strings.Join(types, " "))
+	fmt.Fprintf(&b, "已过滤 %d 条，已丢弃 %d 条\n", common.Stats.Filtered.Load(), common.Stats.Dropped.Load())
+	fmt.Fprint(s.out, b.String())
+}
+
+// 计数条目
+type countEntry struct {
+	key   string
+	count int
+}
+
+// 按计数降序取前 n 个条目，n <= 0 表示全部
+func topEntries(counts map[string]int, n int) []countEntry {
+	entries := make([]countEntry, 0, len(counts))
+	for k, c := range counts {
+		entries = append(entries, countEntry{k, c})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].key < entries[j].key
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// 域名树节点
+type nameNode struct {
+	count    int
+	children map[string]*nameNode
+}
+
+// 按公共后缀列表的 ICANN 部分取可注册域名（如 example.co.uk），作为域名树的第一层。
+// 不使用 github.io、googleapis.com 等私有后缀，这些服务的子域名归入同一节点，
+// 便于看出流量集中在哪个服务；无法判断时返回原域名
+func treeRootDomain(name string) string {
+	suffix, icann := publicsuffix.PublicSuffix(name)
+	for !icann {
+		i := strings.IndexByte(suffix, '.')
+		if i < 0 {
+			break
+		}
+		suffix, icann = publicsuffix.PublicSuffix(suffix[i+1:])
+	}
+	rest, ok := strings.CutSuffix(name, "."+suffix)
+	if !ok || rest == "" {
+		return name
+	}
+	return rest[strings.LastIndexByte(rest, '.')+1:] + "." + suffix
+}
+
+// 向树中加入一个域名，可注册域名作为第一层节点，其下按标签从右到左逐级展开
+func (n *nameNode) add(name string, count int) {
+	name = strings.TrimSuffix(name, ".")
+	base := treeRootDomain(name)
+
+	node := n.child(base)
+	node.count += count
+	rest, ok := strings.CutSuffix(name, "."+base)
+	if !ok {
+		return
+	}
+	labels := strings.Split(rest, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = node.child(labels[i])
+		node.count += count
+	}
+}
+
+// 获取或创建子节点
+func (n *nameNode) child(label string) *nameNode {
+	if n.children == nil {
+		n.children = make(map[string]*nameNode)
+	}
+	c, ok := n.children[label]
+	if !ok {
+		c = &nameNode{}
+		n.children[label] = c
+	}
+	return c
+}
+
+// 按层级输出域名树，第一层显示 topN 个主域名，之下限制深度和宽度
+func writeNameTree(b *strings.Builder, names map[string]int, topN int) {
+	root := &nameNode{}
+	for name, count := range names {
+		root.add(name, count)
+	}
+	writeTreeLevel(b, root, "", 0, topN)
+}
+
+// 输出一层子节点
+func writeTreeLevel(b *strings.Builder, node *nameNode, prefix string, depth, width int) {
+	counts := make(map[string]int, len(node.children))
+	for label, c := range node.children {
+		counts[label] = c.count
+	}
+	entries := topEntries(counts, 0)
+
+	shown := entries
+	if len(shown) > width {
+		shown = shown[:width]
+	}
+
+	for i, e := range shown {
+		last := i == len(shown)-1 && len(entries) == len(shown)
+		branch, indent := "├─ ", "│  "
+		if last {
+			branch, indent = "└─ ", "   "
+		}
+		if depth == 0 {
+			branch, indent = "", ""
+		}
+
+		fmt.Fprintf(b, "%s%s%s (%d)\n", prefix, branch, e.key, e.count)
+		if depth < summaryTreeDepth {
+			writeTreeLevel(b, node.children[e.key], prefix+indent, depth+1, summaryTreeWidth)
+		}
+	}
+
+	if rest := len(entries) - len(shown); rest > 0 {
+		branch := "└─ "
+		if depth == 0 {
+			branch = ""
+		}
+		fmt.Fprintf(b, "%s%s… 另有 %d 项\n", prefix, branch, rest)
+	}
+}
@@ -0,0 +1,53 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTreeRootDomain(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com":            "example.com",
+		"a.b.bbc.co.uk":              "bbc.co.uk",
+		"fcm.googleapis.com":         "googleapis.com",
+		"foo.storage.googleapis.com": "googleapis.com",
+		"alice.github.io":            "github.io",
+		"example.com":                "example.com",
+		"co.uk":                      "co.uk",
+		"localhost":                  "localhost",
+		"printer.local":              "printer.local",
+		"":                           "",
+	}
+	for name, want := range tests {
+		if got := treeRootDomain(name); got != want {
+			t.Errorf("treeRootDomain(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWriteNameTree(t *testing.T) {
+	names := map[string]int{
+		"www.bbc.co.uk":          3,
+		"news.bbc.co.uk":         2,
+		"a.b.example.com":        1,
+		"example.com.":           4,
+		"fcm.googleapis.com":     1,
+		"storage.googleapis.com": 6,
+	}
+	var b strings.Builder
+	writeNameTree(&b, names, 10)
+
+	want := `googleapis.com (7)
+├─ storage (6)
+└─ fcm (1)
+bbc.co.uk (5)
+├─ www (3)
+└─ news (2)
+example.com (5)
+└─ b (1)
+   └─ a (1)
+`
+	if b.String() != want {
+		t.Errorf("writeNameTree() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,258 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// syslog 输出参数
+const (
+	syslogQueueSize   = 1024
+	syslogDialTimeout = 5 * time.Second
+	syslogDefaultPort = "514"
+	syslogFacility    = 16 // local0
+	syslogAppName     = "dnsflux"
+	// 结构化数据的 SD-ID，32473 为 RFC 5612 保留给文档示例的企业编号
+	syslogSDID = "dns@32473"
+	// journald 原生协议的套接字
+	journaldSocket = "/run/systemd/journal/socket"
+)
+
+// syslog 级别
+const (
+	syslogCritical = 2
+	syslogWarning  = 4
+	syslogInfo     = 6
+)
+
+// SyslogSink 将记录以 RFC 5424 格式（带结构化数据）发送到 syslog，或以原生协议写入 journald
+type SyslogSink struct {
+	network  string // udp、tcp、unixgram 或 unix
+	addr     string
+	host     string
+	conn     net.Conn
+	queue    chan common.DNSRecord
+	wg       sync.WaitGroup
+	journald bool
+}
+
+// NewSyslogSink 创建 syslog 输出端，target 形如 udp://siem:514、tcp://siem:601、
+// unix:///dev/log（本机 syslog 守护进程）或 journald（Linux）
+func NewSyslogSink(target string) (*SyslogSink, error) {
+	host, _ := os.Hostname()
+	s := &SyslogSink{
+		host:  host,
+		queue: make(chan common.DNSRecord, syslogQueueSize),
+	}
+
+	if target == "journald" {
+		s.network, s.addr, s.journald = "unixgram", journaldSocket, true
+	} else {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "udp", "tcp":
+			if u.Host == "" {
+				return nil, fmt.Errorf("syslog 地址缺少主机: %s", target)
+			}
+			s.network, s.addr = u.Scheme, u.Host
+			if u.Port() == "" {
+				s.addr = net.JoinHostPort(u.Hostname(), syslogDefaultPort)
+			}
+		case "unix":
+			if u.Path == "" {
+				return nil, fmt.Errorf("syslog 地址缺少套接字路径: %s", target)
+			}
+			s.network, s.addr = "unixgram", u.Path
+		default:
+			return nil, fmt.Errorf("不支持的 syslog 传输协议 %q，应为 udp、tcp、unix 或 journald", u.Scheme)
+		}
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// 后台发送队列中的记录，连接出错时在下一条记录前重连
+func (s *SyslogSink) run() {
+	defer s.wg.Done()
+	for record := range s.queue {
+		if err := s.send(record); err != nil {
+			common.Errorf("syslog 发送失败: %v", err)
+			if s.conn != nil {
+				s.conn.Close()
+				s.conn = nil
+			}
+		}
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// 建立连接，本机 syslog 套接字不支持数据报时改用流式连接
+func (s *SyslogSink) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(s.network, s.addr, syslogDialTimeout)
+	if err != nil && s.network == "unixgram" && !s.journald {
+		if conn, err = net.DialTimeout("unix", s.addr, syslogDialTimeout); err == nil {
+			s.network = "unix"
+		}
+	}
+	return conn, err
+}
+
+// 发送单条记录
+func (s *SyslogSink) send(record common.DNSRecord) error {
+	var data []byte
+	if s.journald {
+		data = journaldMessage(record)
+	} else {
+		data = s.message(record)
+	}
+
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	// 流式传输按 RFC 6587 以长度前缀分帧
+	if s.network == "tcp" || s.network == "unix" {
+		data = append([]byte(strconv.Itoa(len(data))+" "), data...)
+	}
+	_, err := s.conn.Write(data)
+	return err
+}
+
+// 记录对应的 syslog 级别
+func syslogSeverity(record common.DNSRecord) int {
+	switch {
+	case record.Severity == "high":
+		return syslogCritical
+	case record.Severity == "medium", strings.HasPrefix(record.Status, "ERROR"):
+		return syslogWarning
+	default:
+		return syslogInfo
+	}
+}
+
+// 记录的结构化字段，进程 ID、进程路径和查询域名等作为独立字段以便检索，空值不输出
+func syslogFields(record common.DNSRecord) [][2]string {
+	fields := [][2]string{
+		{"pid", strconv.FormatUint(uint64(record.ProcessID), 10)},
+		{"processPath", record.ProcessPath},
+		{"processName", record.ProcessName},
+		{"queryName", record.QueryName},
+		{"queryType", record.QueryType},
+		{"queryResult", record.QueryResult},
+		{"rcode", record.Rcode},
+		{"status", record.Status},
+		{"resolverIp", record.ResolverIP},
+		{"protocol", record.Protocol},
+		{"severity", record.Severity},
+	}
+	if record.ThreadID != 0 {
+		fields = append(fields, [2]string{"tid", strconv.FormatUint(uint64(record.ThreadID), 10)})
+	}
+	if record.EventID != 0 {
+		fields = append(fields, [2]string{"eventId", strconv.Itoa(int(record.EventID))})
+	}
+	return fields
+}
+
+// 转义结构化数据参数值中的 "、\ 和 ]（RFC 5424 6.3.3）
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// 按 RFC 5424 格式化记录：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (s *SyslogSink) message(record common.DNSRecord) []byte {
+	var b bytes.Buffer
+	host := s.host
+	if host == "" {
+		host = "-"
+	}
+	msgID := "query"
+	if record.Response {
+		msgID = "response"
+	}
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s [%s",
+		syslogFacility*8+syslogSeverity(record),
+		record.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+		host, syslogAppName, os.Getpid(), msgID, syslogSDID)
+	for _, field := range syslogFields(record) {
+		if field[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ` %s="%s"`, field[0], sdValueEscaper.Replace(field[1]))
+	}
+	fmt.Fprintf(&b, "] DNS %s %s", record.QueryType, record.QueryName)
+	return b.Bytes()
+}
+
+// 按 journald 原生协议格式化记录，字段名转为大写并加 DNS_ 前缀；
+// 含换行的值使用长度前缀的二进制格式
+func journaldMessage(record common.DNSRecord) []byte {
+	var b bytes.Buffer
+	write := func(key, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", key, value)
+			return
+		}
+		b.WriteString(key + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+
+	write("MESSAGE", fmt.Sprintf("DNS %s %s", record.QueryType, record.QueryName))
+	write("PRIORITY", strconv.Itoa(syslogSeverity(record)))
+	write("SYSLOG_IDENTIFIER", syslogAppName)
+	for _, field := range syslogFields(record) {
+		if field[1] != "" {
+			write("DNS_"+journaldFieldName(field[0]), field[1])
+		}
+	}
+	return b.Bytes()
+}
+
+// 将 processPath 形式的字段名转为 PROCESS_PATH
+func journaldFieldName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String())
+}
+
+// Write 实现 Sink 接口，记录进入发送队列后立即返回
+func (s *SyslogSink) Write(record common.DNSRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("发送队列已满，丢弃记录 %s", record.QueryName)
+	}
+}
+
+// Close 实现 Sink 接口，等待队列中的记录发送完毕
+func (s *SyslogSink) Close() error {
+	close(s.queue)
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,169 @@
+package output
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"dnsflux/common"
+)
+
+// 自动宽度表格的参数
+const (
+	tableRelayout     = 2 * time.Second // 重新计算列宽的间隔，避免逐行抖动
+	tableDefaultWidth = 120             // 无法取得终端宽度时使用
+	tableGap          = "  "
+)
+
+// 表格列，按从左到右的顺序；shrink 越大越先被截断，0 表示不截断
+type tableColumn struct {
+	name   string
+	min    int  // 截断后的最小宽度
+	shrink int  // 截断优先级
+	left   bool // 从左侧截断，保留末尾（如路径中的文件名）
+	value  func(common.DNSRecord) string
+}
+
+var tableColumns = []tableColumn{
+	{name: "TIME", value: func(r common.DNSRecord) string { return r.Timestamp.Format("2006-01-02 15:04:05") }},
+	{name: "PID", value: func(r common.DNSRecord) string { return strconv.FormatUint(uint64(r.ProcessID), 10) }},
+	{name: "TID", value: func(r common.DNSRecord) string { return strconv.FormatUint(uint64(r.ThreadID), 10) }},
+	{name: "PROCESS", min: 8, shrink: 2, value: func(r common.DNSRecord) string { return r.ProcessName }},
+	{name: "PATH", min: 10, shrink: 3, left: true, value: func(r common.DNSRecord) string { return r.ProcessPath }},
+	{name: "PROTO", value: func(r common.DNSRecord) string { return r.Protocol }},
+	{name: "RESOLVER", value: func(r common.DNSRecord) string {
+		if r.ResolverIP == "" {
+			return ""
+		}
+		return net.JoinHostPort(r.ResolverIP, strconv.Itoa(int(r.ResolverPort)))
+	}},
+	{name: "TYPE", value: func(r common.DNSRecord) string { return r.QueryType }},
+	{name: "NAME", min: 16, shrink: 1, value: func(r common.DNSRecord) string { return r.QueryName }},
+}
+
+// AutoTable 按终端宽度和近期内容动态分配列宽的单行表格格式，
+// 空间不足时优先截断路径，再截断进程名，最后截断域名
+type AutoTable struct {
+	mu       sync.Mutex
+	widths   []int // 当前使用的列宽
+	observed []int // 本周期内各列出现过的最大宽度
+	laidOut  time.Time
+}
+
+// NewAutoTable 创建自动宽度表格格式
+func NewAutoTable() *AutoTable {
+	return &AutoTable{observed: make([]int, len(tableColumns))}
+}
+
+// Format 将记录格式化为一行，可作为 ConsoleSink 的 Format
+func (t *AutoTable) Format(record common.DNSRecord) string {
+	cells := make([]string, len(tableColumns))
+	for i, col := range tableColumns {
+		cells[i] = col.value(record)
+	}
+
+	t.mu.Lock()
+	for i, cell := range cells {
+		t.observed[i] = max(t.observed[i], utf8.RuneCountInString(cell))
+	}
+	first := t.widths == nil
+	if first || time.Since(t.laidOut) >= tableRelayout {
+		t.layout()
+	}
+	widths := t.widths
+	t.mu.Unlock()
+
+	var b strings.Builder
+	if first {
+		// 第一行之前输出表头
+		header := make([]string, len(tableColumns))
+		for i, col := range tableColumns {
+			header[i] = col.name
+		}
+		writeRow(&b, header, widths)
+		b.WriteByte('\n')
+	}
+	writeRow(&b, cells, widths)
+	if len(record.ResolutionPath) > 0 {
+		b.WriteString(tableGap + strings.Join(record.ResolutionPath, " -> "))
+	}
+	if len(record.Notes) > 0 {
+		b.WriteString(tableGap + "[" + strings.Join(record.Notes, "; ") + "]")
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// 按列宽写出一行，最后一列不补齐空格
+func writeRow(b *strings.Builder, cells []string, widths []int) {
+	for i, cell := range cells {
+		if i > 0 {
+			b.WriteString(tableGap)
+		}
+		cell = fitCell(cell, widths[i], tableColumns[i].left)
+		b.WriteString(cell)
+		if i < len(cells)-1 {
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+	}
+}
+
+// 按本周期观测到的内容宽度和当前终端宽度重新分配列宽，调用方需持有 mu
+func (t *AutoTable) layout() {
+	widths := make([]int, len(tableColumns))
+	total := len(tableGap) * (len(tableColumns) - 1)
+	for i, col := range tableColumns {
+		widths[i] = max(t.observed[i], len(col.name))
+		total += widths[i]
+	}
+
+	// 超出终端宽度时按优先级依次截断，每列不小于其最小宽度
+	available := terminalWidth()
+	for priority := 3; priority >= 1 && total > available; priority-- {
+		for i, col := range tableColumns {
+			if col.shrink != priority || widths[i] <= col.min {
+				continue
+			}
+			cut := min(total-available, widths[i]-col.min)
+			widths[i] -= cut
+			total -= cut
+		}
+	}
+
+	t.widths = widths
+	t.laidOut = time.Now()
+	for i := range t.observed {
+		t.observed[i] = 0
+	}
+}
+
+// 将单元格截断到指定宽度，截断处以省略号表示
+func fitCell(cell string, width int, left bool) string {
+	n := utf8.RuneCountInString(cell)
+	if n <= width {
+		return cell
+	}
+	runes := []rune(cell)
+	if width <= 1 {
+		return "…"
+	}
+	if left {
+		return "…" + string(runes[n-width+1:])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// 终端宽度，依次尝试终端查询和 COLUMNS 环境变量
+func terminalWidth() int {
+	if w := consoleWidth(os.Stdout); w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return tableDefaultWidth
+}
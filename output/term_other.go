@@ -0,0 +1,23 @@
+//go:build !windows
+
+package output
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// 查询终端宽度，不是终端时返回 0
+func consoleWidth(f *os.File) int {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
+
+// 终端默认支持 ANSI 控制序列
+func enableANSI(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,68 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// TimeStyle 文本输出中附加的时间戳形式
+type TimeStyle int
+
+const (
+	// TimeAbsolute 只输出绝对时间
+	TimeAbsolute TimeStyle = iota
+	// TimeSinceStart 附加相对监控启动的时间，如 +1.234s
+	TimeSinceStart
+	// TimeDelta 附加与上一条记录的间隔，便于观察突发和周期性（beaconing）查询
+	TimeDelta
+)
+
+// ParseTimeStyle 解析时间戳形式：absolute、start、delta
+func ParseTimeStyle(s string) (TimeStyle, error) {
+	switch s {
+	case "", "absolute":
+		return TimeAbsolute, nil
+	case "start":
+		return TimeSinceStart, nil
+	case "delta":
+		return TimeDelta, nil
+	}
+	return TimeAbsolute, fmt.Errorf("未知的时间戳形式 %q，可选 absolute、start、delta", s)
+}
+
+// WithTimeStyle 在格式化结果前附加相对时间，每个输出端各自计算与上一条记录的间隔
+func WithTimeStyle(format func(common.DNSRecord) string, style TimeStyle) func(common.DNSRecord) string {
+	if style == TimeAbsolute {
+		return format
+	}
+
+	var (
+		mu    sync.Mutex
+		start = time.Now()
+		last  time.Time
+	)
+	return func(record common.DNSRecord) string {
+		mu.Lock()
+		// 回放的记录早于启动时间，以第一条记录为起点
+		if last.IsZero() && record.Timestamp.Before(start) {
+			start = record.Timestamp
+		}
+		base := start
+		if style == TimeDelta && !last.IsZero() {
+			base = last
+		}
+		last = record.Timestamp
+		mu.Unlock()
+
+		prefix := fmt.Sprintf("+%.3fs  ", record.Timestamp.Sub(base).Seconds())
+
+		// 多行格式以空行开头，相对时间放在第一个非空行之前
+		text := format(record)
+		trimmed := strings.TrimLeft(text, "\n")
+		return text[:len(text)-len(trimmed)] + prefix + trimmed
+	}
+}
@@ -0,0 +1,110 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// webhook 队列长度，队列满时丢弃新记录，避免慢速 webhook 阻塞事件处理
+const webhookQueueSize = 256
+
+// 发送失败时的重试参数，间隔从 webhookRetryDelay 开始逐次翻倍
+const (
+	webhookMaxAttempts = 4
+	webhookRetryDelay  = time.Second
+)
+
+// WebhookSink 将记录以 JSON 形式 POST 到指定 URL，网络错误、429 和 5xx 响应按指数退避重试
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	queue  chan common.DNSRecord
+	wg     sync.WaitGroup
+	// 关闭时停止等待重试，尽快发送剩余记录
+	closing chan struct{}
+}
+
+// NewWebhookSink 创建 webhook 输出端并启动后台发送协程
+func NewWebhookSink(url string) *WebhookSink {
+	s := &WebhookSink{
+		url:     url,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		queue:   make(chan common.DNSRecord, webhookQueueSize),
+		closing: make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// 后台发送队列中的记录
+func (s *WebhookSink) run() {
+	defer s.wg.Done()
+	for record := range s.queue {
+		if err := s.send(record); err != nil {
+			common.Errorf("webhook 发送失败: %v", err)
+		}
+	}
+}
+
+// 发送单条记录，可重试的错误按指数退避重试
+func (s *WebhookSink) send(record common.DNSRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(data)
+		if err == nil || !retry || attempt == webhookMaxAttempts {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-s.closing:
+			return fmt.Errorf("%v，退出时放弃重试", err)
+		}
+	}
+}
+
+// 发送一次，返回错误是否值得重试
+func (s *WebhookSink) post(data []byte) (bool, error) {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// Write 实现 Sink 接口，记录进入发送队列后立即返回
+func (s *WebhookSink) Write(record common.DNSRecord) error {
+	select {
+	case s.queue <- record:
+		return nil
+	default:
+		return fmt.Errorf("发送队列已满，丢弃记录 %s", record.QueryName)
+	}
+}
+
+// Close 实现 Sink 接口，等待队列中的记录发送完毕
+func (s *WebhookSink) Close() error {
+	close(s.closing)
+	close(s.queue)
+	s.wg.Wait()
+	return nil
+}
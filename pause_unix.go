@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"dnsflux/pipeline"
+)
+
+// 收到 SIGUSR1 时切换暂停状态
+func watchPauseToggle() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR1)
+	go func() {
+		for range sigChan {
+			logPauseState(pipeline.TogglePause())
+		}
+	}()
+}
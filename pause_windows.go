@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"dnsflux/pipeline"
+)
+
+// Windows 没有 SIGUSR1，在控制台输入 p 并回车切换暂停状态
+func watchPauseToggle() {
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if strings.EqualFold(strings.TrimSpace(scanner.Text()), "p") {
+				logPauseState(pipeline.TogglePause())
+			}
+		}
+	}()
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// AlertRules 按威胁情报域名列表和域名长度、熵阈值标注告警记录，
+// 命中的记录照常输出，可用过滤关键字 alert 单独转发
+type AlertRules struct {
+	domains    map[string]struct{}
+	maxLength  int     // 0 表示不检查长度
+	maxEntropy float64 // 0 表示不检查熵
+}
+
+// NewAlertRules 创建告警规则环节，domains 按完全匹配或后缀匹配
+func NewAlertRules(domains []string, maxLength int, maxEntropy float64) *AlertRules {
+	set := make(map[string]struct{}, len(domains))
+	for _, d := range domains {
+		set[normalizePattern(d)] = struct{}{}
+	}
+	return &AlertRules{domains: set, maxLength: maxLength, maxEntropy: maxEntropy}
+}
+
+// LoadDomainList 读取每行一个域名的文本文件，# 开头为注释
+func LoadDomainList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains, scanner.Err()
+}
+
+// Process 实现 Stage 接口，只标注不丢弃
+func (a *AlertRules) Process(record *common.DNSRecord) bool {
+	name := normalizeName(record.QueryName)
+	if name == "" {
+		return true
+	}
+
+	var reasons []string
+	if domain, ok := a.listed(name); ok {
+		reasons = append(reasons, fmt.Sprintf("命中威胁情报域名 %s", domain))
+	}
+	if a.maxLength > 0 && len(name) > a.maxLength {
+		reasons = append(reasons, fmt.Sprintf("域名长度 %d 超过 %d", len(name), a.maxLength))
+	}
+	if a.maxEntropy > 0 {
+		if entropy := shannonEntropy(subdomainPart(name)); entropy > a.maxEntropy {
+			reasons = append(reasons, fmt.Sprintf("子域名熵 %.2f 超过 %.2f", entropy, a.maxEntropy))
+		}
+	}
+	if len(reasons) == 0 {
+		return true
+	}
+
+	record.Alert = true
+	// 诱饵域名的 high 不会被降级
+	if record.Severity == "" {
+		record.Severity = severityMedium
+	}
+	for _, reason := range reasons {
+		record.Notes = append(record.Notes, "告警: "+reason)
+	}
+	return true
+}
+
+// 依次查找域名及其各级父域名，返回命中的列表项
+func (a *AlertRules) listed(name string) (string, bool) {
+	if len(a.domains) == 0 {
+		return "", false
+	}
+	for {
+		if _, ok := a.domains[name]; ok {
+			return name, true
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return "", false
+		}
+		name = name[i+1:]
+	}
+}
+
+// 去掉最后两级标签（近似注册域名）后的子域名部分，不含点；
+// 不足三级时取第一个标签。隧道和 DGA 的编码数据通常位于这一部分
+func subdomainPart(name string) string {
+	labels := strings.Split(name, ".")
+	if len(labels) < 3 {
+		return labels[0]
+	}
+	return strings.Join(labels[:len(labels)-2], "")
+}
+
+// 按字符频率计算的香农熵（比特/字符）
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
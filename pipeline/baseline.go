@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"dnsflux/common"
+)
+
+// 最多记录的 (进程, 解析器) 组合数量，超出后不再输出新组合
+const maxBaselinePairs = 10000
+
+// 组合键：进程名（不区分大小写）和解析器地址
+type baselineKey struct {
+	process  string
+	resolver string
+}
+
+// ResolverBaseline 只放行每个 (进程名, 解析器地址) 组合在本次运行中的第一条记录，
+// 用于快速了解主机上哪些进程在使用哪些解析器
+func ResolverBaseline() Stage {
+	var (
+		mu   sync.Mutex
+		seen = make(map[baselineKey]bool)
+	)
+	return StageFunc(func(record *common.DNSRecord) bool {
+		key := baselineKey{strings.ToLower(record.ProcessName), record.ResolverIP}
+
+		mu.Lock()
+		first := !seen[key] && len(seen) < maxBaselinePairs
+		if first {
+			seen[key] = true
+		}
+		mu.Unlock()
+
+		if !first {
+			return false
+		}
+		resolver := record.ResolverIP
+		if resolver == "" {
+			resolver = "未知解析器"
+		}
+		record.Baseline = true
+		record.Notes = append(record.Notes, fmt.Sprintf("baseline: %s 首次使用 %s", record.ProcessName, resolver))
+		return true
+	})
+}
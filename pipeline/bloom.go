@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// 预生成布隆过滤器文件的魔数和版本
+var bloomMagic = [4]byte{'D', 'F', 'B', 'F'}
+
+const bloomVersion = 1
+
+// 哈希函数个数的上限，误判率 1e-12 时约为 40，超出说明文件已损坏
+const maxBloomHashes = 64
+
+// BloomFilter 已知正常域名的布隆过滤器，判定为“可能已知”的域名存在 fpRate 的误判概率，
+// 判定为“未知”的一定不在集合中
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // 位数
+	k    uint32 // 哈希函数个数
+}
+
+// NewBloomFilter 按预计元素数 n 和误判率 fpRate 创建布隆过滤器
+func NewBloomFilter(n int, fpRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// 双重哈希：第 i 个位置为 h1 + i*h2
+func bloomHashes(name string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31
+	return h1, h2 | 1
+}
+
+// Add 加入一个域名
+func (b *BloomFilter) Add(name string) {
+	h1, h2 := bloomHashes(name)
+	for i := uint64(0); i < uint64(b.k); i++ {
+		pos := (h1 + i*h2) % b.m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+// Test 判断域名是否可能在集合中
+func (b *BloomFilter) Test(name string) bool {
+	h1, h2 := bloomHashes(name)
+	for i := uint64(0); i < uint64(b.k); i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo 以二进制格式保存，供下次启动直接加载
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.Write(bloomMagic[:])
+	binary.Write(&buf, binary.LittleEndian, uint32(bloomVersion))
+	binary.Write(&buf, binary.LittleEndian, b.k)
+	binary.Write(&buf, binary.LittleEndian, b.m)
+	binary.Write(&buf, binary.LittleEndian, b.bits)
+	return buf.WriteTo(w)
+}
+
+// 读取 WriteTo 保存的布隆过滤器，位数必须与文件中位数组的长度一致，
+// 避免损坏或构造的文件导致按头部的位数分配过大的内存
+func readBloomFilter(data []byte) (*BloomFilter, error) {
+	var header struct {
+		Magic   [4]byte
+		Version uint32
+		K       uint32
+		M       uint64
+	}
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("布隆过滤器文件头不完整: %v", err)
+	}
+	if header.Magic != bloomMagic {
+		return nil, errors.New("不是布隆过滤器文件")
+	}
+	if header.Version != bloomVersion {
+		return nil, fmt.Errorf("不支持的布隆过滤器版本 %d", header.Version)
+	}
+	if header.K == 0 || header.M == 0 {
+		return nil, errors.New("布隆过滤器参数无效")
+	}
+	if header.K > maxBloomHashes {
+		return nil, fmt.Errorf("布隆过滤器哈希函数个数 %d 超过上限 %d", header.K, maxBloomHashes)
+	}
+	words := header.M / 64
+	if header.M%64 != 0 {
+		words++
+	}
+	if words != uint64(r.Len())/8 || r.Len()%8 != 0 {
+		return nil, fmt.Errorf("布隆过滤器位数 %d 与文件中 %d 字节的位数组不符", header.M, r.Len())
+	}
+
+	b := &BloomFilter{m: header.M, k: header.K, bits: make([]uint64, words)}
+	if err := binary.Read(r, binary.LittleEndian, b.bits); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// LoadBloomFilter 加载已知正常域名：预生成的布隆过滤器文件直接读取，
+// 否则视为每行一个域名的文本文件（# 开头为注释），按 fpRate 构建
+func LoadBloomFilter(path string, fpRate float64) (*BloomFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, bloomMagic[:]) {
+		return readBloomFilter(data)
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, normalizeName(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	b := NewBloomFilter(len(names), fpRate)
+	for _, name := range names {
+		b.Add(name)
+	}
+	return b, nil
+}
+
+// KnownGood 丢弃可能属于已知正常域名（含其子域名）的记录，其余记录继续完整处理
+func KnownGood(b *BloomFilter) Stage {
+	return StageFunc(func(record *common.DNSRecord) bool {
+		name := normalizeName(record.QueryName)
+		for {
+			if b.Test(name) {
+				return false
+			}
+			i := strings.IndexByte(name, '.')
+			if i < 0 {
+				return true
+			}
+			name = name[i+1:]
+		}
+	})
+}
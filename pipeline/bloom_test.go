@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadBloomFilter(t *testing.T) {
+	b := NewBloomFilter(100, 0.01)
+	b.Add("example.com")
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	loaded, err := readBloomFilter(valid)
+	if err != nil {
+		t.Fatalf("readBloomFilter() error = %v", err)
+	}
+	if !loaded.Test("example.com") || loaded.m != b.m || loaded.k != b.k {
+		t.Errorf("readBloomFilter() = m %d k %d, want m %d k %d", loaded.m, loaded.k, b.m, b.k)
+	}
+
+	// 修改头部的 k（偏移 8）或 m（偏移 12）
+	withHeader := func(k uint32, m uint64) []byte {
+		data := bytes.Clone(valid)
+		binary.LittleEndian.PutUint32(data[8:], k)
+		binary.LittleEndian.PutUint64(data[12:], m)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "short header", data: valid[:10]},
+		{name: "bad magic", data: append([]byte("XXXX"), valid[4:]...)},
+		{name: "zero k", data: withHeader(0, b.m)},
+		{name: "too many hashes", data: withHeader(maxBloomHashes+1, b.m)},
+		{name: "truncated bits", data: valid[:len(valid)-8]},
+		{name: "trailing data", data: append(bytes.Clone(valid), 0)},
+		{name: "m larger than file", data: withHeader(b.k, b.m+64)},
+		{name: "m overflows", data: withHeader(b.k, ^uint64(0))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readBloomFilter(tt.data); err == nil {
+				t.Error("readBloomFilter() want error")
+			}
+		})
+	}
+}
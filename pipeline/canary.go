@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"dnsflux/common"
+)
+
+// 命中诱饵域名的记录级别
+const severityHigh = "high"
+
+// CanaryDetector 检测对诱饵（canary/honeytoken）域名的查询
+// 命中的记录标记为高危，并且不会被后续处理环节丢弃
+type CanaryDetector struct {
+	domains []string
+}
+
+// NewCanaryDetector 创建诱饵域名检测环节，domains 按完全匹配或后缀匹配
+func NewCanaryDetector(domains []string) *CanaryDetector {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		normalized = append(normalized, normalizePattern(d))
+	}
+	return &CanaryDetector{domains: normalized}
+}
+
+// Process 实现 Stage 接口，只标注不丢弃
+func (d *CanaryDetector) Process(record *common.DNSRecord) bool {
+	domain := d.match(record.QueryName)
+	if domain == "" {
+		return true
+	}
+	record.Canary = true
+	record.Severity = severityHigh
+	record.Notes = append(record.Notes, fmt.Sprintf("命中诱饵域名 %s", domain))
+	common.Warnf("进程 %s(%d) 查询了诱饵域名 %s", record.ProcessName, record.ProcessID, record.QueryName)
+	return true
+}
+
+// Matches 判断域名是否命中诱饵域名，供速率限制在处理环节之前豁免这些查询
+func (d *CanaryDetector) Matches(name string) bool {
+	return d.match(name) != ""
+}
+
+// 返回命中的诱饵域名，未命中时为空
+func (d *CanaryDetector) match(name string) string {
+	name = normalizeName(name)
+	for _, domain := range d.domains {
+		if matchDomain(name, domain) {
+			return domain
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"strings"
+
+	"dnsflux/common"
+)
+
+// CNAME 链最多展开的层数
+const maxCNAMEChain = 16
+
+// ResolverChain 将应答中的 CNAME 链展开为有序的解析路径，
+// 如 www.example.com -> example.map.fastly.net -> 151.101.1.57
+func ResolverChain() Stage {
+	return StageFunc(func(record *common.DNSRecord) bool {
+		if len(record.Answers) == 0 {
+			return true
+		}
+		path, loop := resolutionPath(record.QueryName, record.Answers)
+		if len(path) > 1 {
+			record.ResolutionPath = path
+		}
+		if loop {
+			record.Notes = append(record.Notes, "应答中的 CNAME 链存在循环")
+		}
+		return true
+	})
+}
+
+// 从查询域名开始沿 CNAME 记录展开，最后附加目标域名的地址；
+// 遇到循环或超过最大层数时停止，并返回 loop=true
+func resolutionPath(qname string, answers []common.Answer) (path []string, loop bool) {
+	current := normalizeName(qname)
+	path = []string{current}
+	visited := map[string]bool{current: true}
+
+	for len(path) <= maxCNAMEChain {
+		target := ""
+		for _, a := range answers {
+			if a.Type == "CNAME" && normalizeName(a.Name) == current {
+				target = normalizeName(a.Data)
+				break
+			}
+		}
+		if target == "" {
+			break
+		}
+		if visited[target] {
+			return append(path, target), true
+		}
+		visited[target] = true
+		path = append(path, target)
+		current = target
+	}
+	if len(path) > maxCNAMEChain {
+		return path, true
+	}
+
+	var addrs []string
+	for _, a := range answers {
+		if (a.Type == "A" || a.Type == "AAAA") && normalizeName(a.Name) == current {
+			addrs = append(addrs, a.Data)
+		}
+	}
+	if len(addrs) > 0 {
+		path = append(path, strings.Join(addrs, ", "))
+	}
+	return path, false
+}
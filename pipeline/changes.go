@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"dnsflux/common"
+)
+
+// 解析结果变化检测最多跟踪的域名数量，超出后不再跟踪新域名
+const maxTrackedAnswers = 50000
+
+// ChangeDetector 只放行解析结果与上次不同的记录
+// 以域名和查询类型为键，避免 A/AAAA 交替查询被误判为变化；首次解析只记录基线不输出
+type ChangeDetector struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+// NewChangeDetector 创建解析结果变化检测环节
+func NewChangeDetector() *ChangeDetector {
+	return &ChangeDetector{last: make(map[string]string)}
+}
+
+// Process 实现 Stage 接口
+func (d *ChangeDetector) Process(record *common.DNSRecord) bool {
+	answers := normalizeAnswers(record.QueryResult)
+	if answers == "" {
+		return false
+	}
+
+	key := normalizeName(record.QueryName) + "|" + record.QueryType
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous, seen := d.last[key]
+	if !seen {
+		if len(d.last) < maxTrackedAnswers {
+			d.last[key] = answers
+		}
+		return false
+	}
+	if previous == answers {
+		return false
+	}
+
+	d.last[key] = answers
+	record.PreviousResult = previous
+	return true
+}
+
+// 将结果字符串规范化为排序去重后的答案集合，与顺序无关
+func normalizeAnswers(result string) string {
+	seen := make(map[string]bool)
+	var answers []string
+	for _, a := range strings.Split(result, ",") {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "" || a == "-" || seen[a] {
+			continue
+		}
+		seen[a] = true
+		answers = append(answers, a)
+	}
+	sort.Strings(answers)
+	return strings.Join(answers, ", ")
+}
@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+	"dnsflux/output"
+)
+
+// 解析结果缓存最多保存的 (进程, 地址) 数量
+const maxResolvedEntries = 50000
+
+// 连接到非常用端口的关联事件级别
+const severityMedium = "medium"
+
+// ConnectEvent 进程发起的一次出站连接，由平台采集后通过 Connect 提交
+type ConnectEvent struct {
+	Timestamp time.Time
+	ProcessID uint32
+	IP        net.IP
+	Port      uint16
+	Protocol  string
+}
+
+// ConnectObserver 由需要连接事件的处理环节实现
+type ConnectObserver interface {
+	Connect(event ConnectEvent)
+}
+
+// Connect 将连接事件交给实现了 ConnectObserver 的处理环节
+func Connect(event ConnectEvent) {
+	if paused.Load() {
+		return
+	}
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	for _, stage := range stages {
+		if observer, ok := stage.(ConnectObserver); ok {
+			observer.Connect(event)
+		}
+	}
+}
+
+// 缓存键：发起查询的进程和解析出的地址
+type resolvedKey struct {
+	pid uint32
+	ip  string
+}
+
+// ConnectCorrelator 缓存查询解析出的地址，进程在时间窗口内连接这些地址时
+// 输出一条“解析后连接”的关联事件，目标端口不在常用端口列表中时提升级别
+type ConnectCorrelator struct {
+	window      time.Duration
+	normalPorts map[uint16]bool
+
+	mu       sync.Mutex
+	resolved map[resolvedKey]common.DNSRecord
+}
+
+// NewConnectCorrelator 创建解析后连接关联环节，normalPorts 之外的端口视为可疑
+func NewConnectCorrelator(window time.Duration, normalPorts []uint16) *ConnectCorrelator {
+	c := &ConnectCorrelator{
+		window:      window,
+		normalPorts: make(map[uint16]bool, len(normalPorts)),
+		resolved:    make(map[resolvedKey]common.DNSRecord),
+	}
+	for _, port := range normalPorts {
+		c.normalPorts[port] = true
+	}
+	return c
+}
+
+// 取出记录中解析出的地址，优先使用结构化应答
+func resolvedIPs(record *common.DNSRecord) []string {
+	var ips []string
+	for _, a := range record.Answers {
+		if a.Type == "A" || a.Type == "AAAA" {
+			ips = append(ips, a.Data)
+		}
+	}
+	if len(ips) > 0 {
+		return ips
+	}
+	for _, field := range strings.FieldsFunc(record.QueryResult, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		if ip := net.ParseIP(field); ip != nil {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
+
+// Process 实现 Stage 接口，缓存解析结果，不丢弃记录
+func (c *ConnectCorrelator) Process(record *common.DNSRecord) bool {
+	ips := resolvedIPs(record)
+	if len(ips) == 0 {
+		return true
+	}
+
+	// 关联事件只保留查询本身的信息
+	cached := common.DNSRecord{
+		Timestamp:   record.Timestamp,
+		QueryName:   record.QueryName,
+		QueryType:   record.QueryType,
+		QueryResult: record.QueryResult,
+		ProcessID:   record.ProcessID,
+		ProcessName: record.ProcessName,
+		ProcessPath: record.ProcessPath,
+		ThreadID:    record.ThreadID,
+		NetNS:       record.NetNS,
+
+		ProcessStartTime: record.ProcessStartTime,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.resolved) >= maxResolvedEntries {
+		c.sweep(record.Timestamp)
+		if len(c.resolved) >= maxResolvedEntries {
+			return true
+		}
+	}
+	for _, ip := range ips {
+		c.resolved[resolvedKey{record.ProcessID, ip}] = cached
+	}
+	return true
+}
+
+// Connect 实现 ConnectObserver 接口，连接目标命中窗口内的解析结果时输出关联事件
+func (c *ConnectCorrelator) Connect(event ConnectEvent) {
+	key := resolvedKey{event.ProcessID, event.IP.String()}
+
+	c.mu.Lock()
+	record, ok := c.resolved[key]
+	if ok {
+		// 同一地址只关联第一次连接
+		delete(c.resolved, key)
+	}
+	c.mu.Unlock()
+
+	delay := event.Timestamp.Sub(record.Timestamp)
+	if !ok || delay < 0 || delay > c.window {
+		return
+	}
+
+	conn := &common.Connection{
+		IP:         key.ip,
+		Port:       event.Port,
+		Protocol:   event.Protocol,
+		DelayMs:    delay.Milliseconds(),
+		Suspicious: !c.normalPorts[event.Port],
+	}
+	record.Timestamp = event.Timestamp
+	record.Connection = conn
+	record.Notes = append(record.Notes, fmt.Sprintf("解析后 %s 连接 %s/%s",
+		delay.Round(time.Millisecond), net.JoinHostPort(conn.IP, fmt.Sprint(conn.Port)), conn.Protocol))
+	if conn.Suspicious {
+		record.Severity = severityMedium
+		common.Warnf("进程 %s(%d) 解析 %s 后 %s 连接了非常用端口 %d",
+			record.ProcessName, record.ProcessID, record.QueryName, delay.Round(time.Millisecond), conn.Port)
+	}
+
+	// 关联事件不再经过其他处理环节，直接分发到输出端
+	common.Stats.Processed.Add(1)
+	output.Emit(record)
+}
+
+// 清理超出窗口的解析结果
+func (c *ConnectCorrelator) sweep(now time.Time) {
+	for key, record := range c.resolved {
+		if now.Sub(record.Timestamp) > c.window {
+			delete(c.resolved, key)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 跨进程关联最多跟踪的域名数量，超出时清理过期条目
+const maxCrossProcessDomains = 50000
+
+// CrossProcessDetector 检测同一域名在时间窗口内被多个不同进程查询
+// 这可能意味着共享库、注入代码或协同活动
+type CrossProcessDetector struct {
+	window    time.Duration
+	threshold int
+
+	mu      sync.Mutex
+	domains map[string]*domainProcesses
+}
+
+// 单个域名在窗口内的查询进程
+type domainProcesses struct {
+	seen    map[uint32]processSeen
+	alerted bool
+}
+
+// 进程最后一次查询该域名的情况
+type processSeen struct {
+	name string
+	at   time.Time
+}
+
+// NewCrossProcessDetector 创建跨进程关联检测环节，窗口内不同进程数达到 threshold 时标注
+func NewCrossProcessDetector(window time.Duration, threshold int) *CrossProcessDetector {
+	return &CrossProcessDetector{
+		window:    window,
+		threshold: threshold,
+		domains:   make(map[string]*domainProcesses),
+	}
+}
+
+// Process 实现 Stage 接口，只标注不丢弃
+func (d *CrossProcessDetector) Process(record *common.DNSRecord) bool {
+	name := normalizeName(record.QueryName)
+	now := record.Timestamp
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.domains) >= maxCrossProcessDomains {
+		d.sweep(now)
+	}
+
+	dp, ok := d.domains[name]
+	if !ok {
+		if len(d.domains) >= maxCrossProcessDomains {
+			return true
+		}
+		dp = &domainProcesses{seen: make(map[uint32]processSeen)}
+		d.domains[name] = dp
+	}
+
+	dp.seen[record.ProcessID] = processSeen{name: record.ProcessName, at: now}
+	dp.expire(now, d.window)
+
+	if len(dp.seen) < d.threshold {
+		dp.alerted = false
+		return true
+	}
+	if dp.alerted {
+		return true
+	}
+	dp.alerted = true
+
+	names := make([]string, 0, len(dp.seen))
+	for pid, p := range dp.seen {
+		names = append(names, fmt.Sprintf("%s(%d)", p.name, pid))
+	}
+	sort.Strings(names)
+	record.Notes = append(record.Notes, fmt.Sprintf("%s 内被 %d 个不同进程查询: %s",
+		d.window, len(dp.seen), strings.Join(names, ", ")))
+	return true
+}
+
+// 移除窗口外的进程
+func (dp *domainProcesses) expire(now time.Time, window time.Duration) {
+	for pid, p := range dp.seen {
+		if now.Sub(p.at) > window {
+			delete(dp.seen, pid)
+		}
+	}
+}
+
+// 清理所有已无窗口内进程的域名
+func (d *CrossProcessDetector) sweep(now time.Time) {
+	for name, dp := range d.domains {
+		dp.expire(now, d.window)
+		if len(dp.seen) == 0 {
+			delete(d.domains, name)
+		}
+	}
+}
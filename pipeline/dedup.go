@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+	"dnsflux/output"
+)
+
+// 去重最多跟踪的 (进程, 域名, 类型) 组合数量，超出后新组合不去重
+const maxDedupEntries = 50000
+
+// Deduplicator 抑制同一进程在时间窗口内重复的相同查询，只放行窗口内的第一条；
+// 窗口结束时若有被抑制的记录，输出一条带抑制次数的汇总记录
+// 汇总记录直接分发到输出端，应作为最后一个处理环节
+type Deduplicator struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[dedupKey]*dedupEntry
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// 去重键
+type dedupKey struct {
+	pid   uint32
+	name  string
+	qtype string
+}
+
+// 窗口内第一条记录及之后被抑制的次数
+type dedupEntry struct {
+	first      common.DNSRecord
+	start      time.Time
+	suppressed int
+}
+
+// NewDeduplicator 创建去重环节，每隔半个窗口检查一次已结束的窗口
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	d := &Deduplicator{
+		window:  window,
+		entries: make(map[dedupKey]*dedupEntry),
+		stop:    make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// Process 实现 Stage 接口
+func (d *Deduplicator) Process(record *common.DNSRecord) bool {
+	if record.Canary {
+		return true
+	}
+	key := dedupKey{record.ProcessID, normalizeName(record.QueryName), record.QueryType}
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e, ok := d.entries[key]; ok {
+		if now.Sub(e.start) < d.window {
+			e.suppressed++
+			return false
+		}
+		d.flush(key, e)
+	}
+	if len(d.entries) < maxDedupEntries {
+		d.entries[key] = &dedupEntry{first: *record, start: now}
+	}
+	return true
+}
+
+// 定期输出已结束窗口的汇总记录
+func (d *Deduplicator) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(max(d.window/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			d.mu.Lock()
+			for key, e := range d.entries {
+				if now.Sub(e.start) >= d.window {
+					d.flush(key, e)
+				}
+			}
+			d.mu.Unlock()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// 移除窗口，有被抑制的记录时输出汇总记录，调用方需持有 mu
+func (d *Deduplicator) flush(key dedupKey, e *dedupEntry) {
+	delete(d.entries, key)
+	if e.suppressed == 0 {
+		return
+	}
+	rollup := e.first
+	rollup.Timestamp = time.Now().In(e.first.Timestamp.Location())
+	rollup.Suppressed = e.suppressed
+	rollup.Notes = append(append([]string(nil), e.first.Notes...),
+		fmt.Sprintf("%s 内重复 %d 次", d.window, e.suppressed))
+	output.Emit(rollup)
+}
+
+// Flush 停止定期检查并输出所有未结束窗口的汇总记录
+func (d *Deduplicator) Flush() {
+	close(d.stop)
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, e := range d.entries {
+		d.flush(key, e)
+	}
+}
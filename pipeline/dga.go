@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// 常见 CDN、云服务和反向解析域名，其主机名含随机编码的标签，不参与评分以减少误报
+var defaultDGAExclude = []string{
+	"in-addr.arpa", "ip6.arpa",
+	"akamai.net", "akamaiedge.net", "akamaihd.net", "edgekey.net", "edgesuite.net",
+	"cloudfront.net", "amazonaws.com", "azureedge.net", "azurefd.net", "cloudapp.net",
+	"trafficmanager.net", "fastly.net", "fastlylb.net", "cdn.cloudflare.net",
+	"googleusercontent.com", "googlevideo.com", "gvt1.com", "1e100.net", "llnwd.net",
+}
+
+// DGADetector 按最长标签的香农熵和长度为查询域名评分，标注疑似算法生成（DGA）
+// 或隧道编码的域名，只标注不丢弃
+type DGADetector struct {
+	entropy        float64 // 熵阈值（比特/字符）
+	minLength      int     // 参与熵判断的最短标签长度
+	maxLabelLength int     // 标签长度达到该值时直接标注，0 表示不检查
+	exclude        []string
+}
+
+// NewDGADetector 创建 DGA 检测环节，exclude 追加到内置的 CDN 排除列表，按完全匹配或后缀匹配
+func NewDGADetector(entropy float64, minLength, maxLabelLength int, exclude []string) *DGADetector {
+	patterns := make([]string, 0, len(defaultDGAExclude)+len(exclude))
+	for _, d := range slices.Concat(defaultDGAExclude, exclude) {
+		patterns = append(patterns, normalizePattern(d))
+	}
+	return &DGADetector{entropy: entropy, minLength: minLength, maxLabelLength: maxLabelLength, exclude: patterns}
+}
+
+// Process 实现 Stage 接口
+func (d *DGADetector) Process(record *common.DNSRecord) bool {
+	name := normalizeName(record.QueryName)
+	if name == "" {
+		return true
+	}
+	for _, domain := range d.exclude {
+		if matchDomain(name, domain) {
+			return true
+		}
+	}
+
+	label := longestLabel(name)
+	entropy := math.Round(shannonEntropy(label)*100) / 100
+	record.Entropy = entropy
+
+	var reason string
+	switch {
+	case d.maxLabelLength > 0 && len(label) >= d.maxLabelLength:
+		reason = fmt.Sprintf("标签长度 %d 达到 %d", len(label), d.maxLabelLength)
+	case len(label) >= d.minLength && entropy >= d.entropy:
+		reason = fmt.Sprintf("标签 %s 的熵 %.2f 达到 %.2f", label, entropy, d.entropy)
+	default:
+		return true
+	}
+
+	record.Suspicious = true
+	if record.Severity == "" {
+		record.Severity = severityMedium
+	}
+	record.Notes = append(record.Notes, "疑似算法生成的域名: "+reason)
+	return true
+}
+
+// 顶级域名之外最长的标签，只有一级时取该标签
+func longestLabel(name string) string {
+	labels := strings.Split(name, ".")
+	if len(labels) > 1 {
+		labels = labels[:len(labels)-1]
+	}
+	longest := ""
+	for _, label := range labels {
+		if len(label) > len(longest) {
+			longest = label
+		}
+	}
+	return longest
+}
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// 规范化查询域名：去除末尾的点并转为小写的 ASCII 形式，国际化域名编码为 punycode
+func normalizeName(name string) string {
+	return common.ASCIIDomain(strings.TrimSuffix(name, "."))
+}
+
+// NormalizeNames 将查询域名转为小写的 ASCII 形式，后续过滤、去重和关联都基于这一形式；
+// decodeIDN 时含 punycode 标签的域名解码为 Unicode 写入 QueryNameUnicode 用于展示
+func NormalizeNames(decodeIDN bool) Stage {
+	return StageFunc(func(record *common.DNSRecord) bool {
+		record.QueryName = common.ASCIIDomain(record.QueryName)
+		if decodeIDN {
+			record.QueryNameUnicode = common.UnicodeDomain(record.QueryName)
+		}
+		return true
+	})
+}
+
+// 规范化域名匹配模式，*.example.com 与 .example.com 等同于 example.com
+func normalizePattern(pattern string) string {
+	pattern = strings.TrimPrefix(pattern, "*")
+	return normalizeName(strings.TrimPrefix(pattern, "."))
+}
+
+// 判断规范化后的域名是否等于 domain 或为其子域名
+func matchDomain(name, domain string) bool {
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// MinNameLength 只放行规范化后长度超过 n 个字符的查询域名，用于快速发现隧道/外传使用的超长编码域名
+func MinNameLength(n int) Stage {
+	return StageFunc(func(record *common.DNSRecord) bool {
+		return len(normalizeName(record.QueryName)) > n
+	})
+}
+
+// LargeMessage 标注报文长度超过 n 字节的记录，便于发现可被用于放大攻击或携带数据的大响应
+func LargeMessage(n int) Stage {
+	return StageFunc(func(record *common.DNSRecord) bool {
+		if record.MessageSize > n {
+			record.LargeMessage = true
+			record.Notes = append(record.Notes, fmt.Sprintf("报文 %d 字节，超过 %d 字节", record.MessageSize, n))
+		}
+		return true
+	})
+}
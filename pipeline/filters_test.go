@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"testing"
+
+	"dnsflux/common"
+)
+
+func TestNormalizeNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		decodeIDN   bool
+		query       string
+		wantName    string
+		wantUnicode string
+	}{
+		{name: "mixed case", query: "WwW.ExAmPlE.cOm", wantName: "www.example.com"},
+		{name: "unicode", query: "Bücher.DE", wantName: "xn--bcher-kva.de"},
+		{name: "unicode decoded", decodeIDN: true, query: "bücher.de", wantName: "xn--bcher-kva.de", wantUnicode: "bücher.de"},
+		{name: "punycode decoded", decodeIDN: true, query: "XN--BCHER-KVA.de", wantName: "xn--bcher-kva.de", wantUnicode: "bücher.de"},
+		{name: "ascii not decoded", decodeIDN: true, query: "Example.com", wantName: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &common.DNSRecord{QueryName: tt.query}
+			if !NormalizeNames(tt.decodeIDN).Process(record) {
+				t.Fatal("NormalizeNames dropped the record")
+			}
+			if record.QueryName != tt.wantName || record.QueryNameUnicode != tt.wantUnicode {
+				t.Errorf("QueryName, QueryNameUnicode = %q, %q, want %q, %q",
+					record.QueryName, record.QueryNameUnicode, tt.wantName, tt.wantUnicode)
+			}
+		})
+	}
+}
+
+// 以 Unicode、punycode 或不同大小写书写的诱饵域名与规范化后的查询互相匹配
+func TestCanaryMatchesNormalizedNames(t *testing.T) {
+	canary := NewCanaryDetector([]string{"*.Bücher.de", "xn--r8jz45g.xn--zckzah"})
+	for query, want := range map[string]bool{
+		"WWW.XN--BCHER-KVA.DE": true,
+		"shop.bücher.de":       true,
+		"例え.テスト":               true,
+		"bucher.de":            false,
+	} {
+		record := &common.DNSRecord{QueryName: query}
+		NormalizeNames(false).Process(record)
+		canary.Process(record)
+		if record.Canary != want {
+			t.Errorf("%q: Canary = %v, want %v", query, record.Canary, want)
+		}
+	}
+}
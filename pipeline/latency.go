@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"cmp"
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 等待响应的查询数上限，超出时最早的查询先被丢弃
+const maxPendingQueries = 8192
+
+// LatencyTracker 按事务 ID 将响应与同一进程之前发出的查询配对，在响应记录上填充 latencyMs；
+// 查询在 timeout 内没有收到响应时从待配对表中移除。threshold 大于 0 时，
+// 耗时（包括后端已给出的，如 Windows 合并后的查询）超过该值的记录输出警告
+type LatencyTracker struct {
+	match     bool
+	timeout   time.Duration
+	threshold time.Duration
+
+	mu      sync.Mutex
+	pending map[latencyKey]*list.Element
+	order   *list.List // 按查询时间排列，最早的在前
+}
+
+// 配对键：同一进程、事务 ID、查询域名（不区分大小写）和查询类型
+type latencyKey struct {
+	pid   uint32
+	txid  uint16
+	name  string
+	qtype string
+}
+
+type pendingQuery struct {
+	key  latencyKey
+	sent time.Time
+}
+
+// NewLatencyTracker 创建耗时统计环节，match 为 false 时不做配对（后端没有响应报文），只检查阈值
+func NewLatencyTracker(match bool, timeout, threshold time.Duration) *LatencyTracker {
+	return &LatencyTracker{
+		match:     match,
+		timeout:   timeout,
+		threshold: threshold,
+		pending:   make(map[latencyKey]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// Process 实现 Stage 接口，只标注不丢弃
+func (t *LatencyTracker) Process(record *common.DNSRecord) bool {
+	if t.match && record.LatencyMs == 0 && record.QueryName != "" && record.EncryptedDNS == "" {
+		t.pair(record)
+	}
+	if t.threshold > 0 && record.LatencyMs >= float64(t.threshold)/float64(time.Millisecond) {
+		common.Warnf("进程 %s(%d) 查询 %s %s 耗时 %.1fms，解析器 %s", record.ProcessName, record.ProcessID,
+			record.QueryName, record.QueryType, record.LatencyMs, cmp.Or(record.ResolverIP, "未知"))
+	}
+	return true
+}
+
+// 记录查询，或为响应找到对应的查询并计算耗时
+func (t *LatencyTracker) pair(record *common.DNSRecord) {
+	key := latencyKey{record.ProcessID, record.TransactionID, strings.ToLower(record.QueryName), record.QueryType}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire(record.Timestamp)
+
+	if !record.Response {
+		if elem, ok := t.pending[key]; ok {
+			// 重传的查询按最后一次发送计时
+			t.order.Remove(elem)
+		}
+		t.pending[key] = t.order.PushBack(&pendingQuery{key: key, sent: record.Timestamp})
+		if t.order.Len() > maxPendingQueries {
+			t.remove(t.order.Front())
+		}
+		return
+	}
+
+	elem, ok := t.pending[key]
+	if !ok {
+		return
+	}
+	t.remove(elem)
+	if latency := record.Timestamp.Sub(elem.Value.(*pendingQuery).sent); latency >= 0 {
+		record.LatencyMs = float64(latency.Microseconds()) / 1000
+	}
+}
+
+// 移除超过 timeout 仍未收到响应的查询
+func (t *LatencyTracker) expire(now time.Time) {
+	for elem := t.order.Front(); elem != nil; elem = t.order.Front() {
+		if now.Sub(elem.Value.(*pendingQuery).sent) < t.timeout {
+			return
+		}
+		t.remove(elem)
+	}
+}
+
+func (t *LatencyTracker) remove(elem *list.Element) {
+	t.order.Remove(elem)
+	delete(t.pending, elem.Value.(*pendingQuery).key)
+}
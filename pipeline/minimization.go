@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// QNAME 最小化检测参数
+const (
+	minimizationWindow      = 5 * time.Second  // 同一解析器逐级查询的最大间隔
+	minimizationResolverTTL = 10 * time.Minute // 进程被识别为最小化解析器后的记忆时间
+	maxMinimizationEntries  = 50000            // 最多跟踪的（进程, 域名）数量
+)
+
+// MinimizationDetector 识别启用 QNAME 最小化（RFC 9156）的递归解析器发出的部分查询
+// 这类解析器会依次查询 com、example.com、www.example.com，逐级补全标签，
+// 单独看时容易被误认为畸形查询或隧道流量
+type MinimizationDetector struct {
+	mu        sync.Mutex
+	recent    map[string]time.Time // pid|域名 -> 最近一次查询时间
+	resolvers map[uint32]time.Time // 已识别的最小化解析器进程
+}
+
+// NewMinimizationDetector 创建 QNAME 最小化检测环节
+func NewMinimizationDetector() *MinimizationDetector {
+	return &MinimizationDetector{
+		recent:    make(map[string]time.Time),
+		resolvers: make(map[uint32]time.Time),
+	}
+}
+
+// Process 实现 Stage 接口，只标注不丢弃
+func (d *MinimizationDetector) Process(record *common.DNSRecord) bool {
+	name := normalizeName(record.QueryName)
+	now := record.Timestamp
+	pid := record.ProcessID
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.recent) >= maxMinimizationEntries {
+		d.sweep(now)
+	}
+	if len(d.recent) < maxMinimizationEntries {
+		d.recent[minimizationKey(pid, name)] = now
+	}
+
+	// 同一进程刚查询过去掉最左侧标签的上级域名，说明正在逐级补全
+	partial := false
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		if at, ok := d.recent[minimizationKey(pid, name[i+1:])]; ok && now.Sub(at) <= minimizationWindow {
+			partial = true
+			d.resolvers[pid] = now
+		}
+	}
+
+	// 已识别的解析器查询顶级或二级域名的 NS/A 记录，视为序列的第一步
+	if !partial {
+		if at, ok := d.resolvers[pid]; ok && now.Sub(at) <= minimizationResolverTTL {
+			partial = strings.Count(name, ".") <= 1 && (record.QueryType == "NS" || record.QueryType == "A")
+		}
+	}
+
+	if partial {
+		record.QNameMinimization = true
+		record.Notes = append(record.Notes, "QNAME 最小化查询序列的一部分")
+	}
+	return true
+}
+
+// 跟踪表的键
+func minimizationKey(pid uint32, name string) string {
+	return strconv.FormatUint(uint64(pid), 10) + "|" + name
+}
+
+// 清理过期的查询和解析器记录
+func (d *MinimizationDetector) sweep(now time.Time) {
+	for key, at := range d.recent {
+		if now.Sub(at) > minimizationWindow {
+			delete(d.recent, key)
+		}
+	}
+	for pid, at := range d.resolvers {
+		if now.Sub(at) > minimizationResolverTTL {
+			delete(d.resolvers, pid)
+		}
+	}
+}
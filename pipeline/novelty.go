@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 本次运行中已出现域名的最大记录数量，超出后不再记录新域名
+const maxSeenDomains = 100000
+
+// DomainSeenSet 记录本次运行中出现过的域名
+type DomainSeenSet struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+// NewDomainSeenSet 创建域名集合
+func NewDomainSeenSet() *DomainSeenSet {
+	return &DomainSeenSet{names: make(map[string]bool)}
+}
+
+// Add 记录域名，返回该域名此前是否未出现过
+func (s *DomainSeenSet) Add(name string) bool {
+	name = normalizeName(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.names[name] {
+		return false
+	}
+	if len(s.names) >= maxSeenDomains {
+		// 集合已满时无法判断，按已出现处理，避免误报
+		return false
+	}
+	s.names[name] = true
+	return true
+}
+
+// 进程是否在查询前 window 时间内启动
+func isNewProcess(record *common.DNSRecord, window time.Duration) bool {
+	if record.ProcessStartTime.IsZero() {
+		return false
+	}
+	return record.Timestamp.Sub(record.ProcessStartTime) <= window
+}
+
+// NewProcessNewDomain 只放行启动不超过 window 的进程首次查询本次运行中未出现过的域名，
+// 两个条件同时满足通常意味着刚刚发生了不寻常的事情
+func NewProcessNewDomain(window time.Duration) Stage {
+	seen := NewDomainSeenSet()
+	return StageFunc(func(record *common.DNSRecord) bool {
+		// 所有记录都计入域名集合，新进程查询已出现过的域名不视为新颖
+		newDomain := seen.Add(record.QueryName)
+		if !newDomain || !isNewProcess(record, window) {
+			return false
+		}
+		age := record.Timestamp.Sub(record.ProcessStartTime).Round(time.Millisecond)
+		record.Notes = append(record.Notes, fmt.Sprintf("启动 %s 的新进程首次查询该域名", age))
+		return true
+	})
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dnsflux/common"
+	"dnsflux/output"
+)
+
+// Stage 是记录分发到输出端之前的处理环节，可修改记录，返回 false 表示丢弃该记录
+type Stage interface {
+	Process(record *common.DNSRecord) bool
+}
+
+// StageFunc 将普通函数适配为处理环节
+type StageFunc func(record *common.DNSRecord) bool
+
+// Process 实现 Stage 接口
+func (f StageFunc) Process(record *common.DNSRecord) bool {
+	return f(record)
+}
+
+var (
+	stages   []Stage
+	stagesMu sync.RWMutex
+
+	// 暂停期间采集继续运行，但事件不再处理和输出
+	paused atomic.Bool
+)
+
+// TogglePause 切换暂停状态，返回切换后是否处于暂停
+func TogglePause() bool {
+	for {
+		old := paused.Load()
+		if paused.CompareAndSwap(old, !old) {
+			return !old
+		}
+	}
+}
+
+// Paused 返回当前是否暂停，采集循环据此跳过事件
+func Paused() bool {
+	return paused.Load()
+}
+
+// Flusher 由缓存了记录的处理环节实现，退出时输出缓存的记录
+type Flusher interface {
+	Flush()
+}
+
+// Use 追加处理环节，按追加顺序执行
+func Use(stage Stage) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	stages = append(stages, stage)
+}
+
+// Submit 依次执行所有处理环节，未被丢弃的记录分发给输出端
+// 命中诱饵域名的记录不会被丢弃；启用了速率限制时先按限制丢弃或抽样；
+// 启动了处理协程时按 PID 分片入队后返回
+func Submit(record common.DNSRecord) {
+	if paused.Load() {
+		return
+	}
+	if l := limiter.Load(); l != nil && !l.Allow(&record, time.Now()) {
+		return
+	}
+	if enqueue(record) {
+		return
+	}
+	process(record)
+}
+
+// 执行处理环节并分发
+func process(record common.DNSRecord) {
+	common.Stats.Processed.Add(1)
+
+	stagesMu.RLock()
+	for _, stage := range stages {
+		if !stage.Process(&record) && !record.Canary {
+			stagesMu.RUnlock()
+			common.Stats.Filtered.Add(1)
+			return
+		}
+	}
+	stagesMu.RUnlock()
+
+	output.Emit(record)
+}
@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 反向解析风暴检测最多跟踪的进程数量
+const maxPTRStormProcesses = 10000
+
+// PTRStormDetector 检测单个进程在时间窗口内发起大量 PTR 查询，
+// 这通常意味着网络扫描或资产枚举工具
+type PTRStormDetector struct {
+	window    time.Duration
+	threshold int
+
+	mu        sync.Mutex
+	processes map[uint32]*ptrHistory
+}
+
+// 进程最近 threshold 次 PTR 查询的时间
+type ptrHistory struct {
+	times    []time.Time
+	storming bool // 当前是否处于风暴中，用于只在开始时告警一次
+}
+
+// NewPTRStormDetector 创建反向解析风暴检测环节，窗口内 PTR 查询数达到 threshold 时标注
+func NewPTRStormDetector(window time.Duration, threshold int) *PTRStormDetector {
+	return &PTRStormDetector{
+		window:    window,
+		threshold: threshold,
+		processes: make(map[uint32]*ptrHistory),
+	}
+}
+
+// Process 实现 Stage 接口，只标注不丢弃
+func (d *PTRStormDetector) Process(record *common.DNSRecord) bool {
+	if record.QueryType != "PTR" {
+		return true
+	}
+	now := record.Timestamp
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, ok := d.processes[record.ProcessID]
+	if !ok {
+		if len(d.processes) >= maxPTRStormProcesses {
+			d.sweep(now)
+			if len(d.processes) >= maxPTRStormProcesses {
+				return true
+			}
+		}
+		h = &ptrHistory{}
+		d.processes[record.ProcessID] = h
+	}
+
+	// 只保留最近 threshold 次，最早一次仍在窗口内即达到阈值
+	h.times = append(h.times, now)
+	if len(h.times) > d.threshold {
+		h.times = h.times[len(h.times)-d.threshold:]
+	}
+	if len(h.times) < d.threshold || now.Sub(h.times[0]) > d.window {
+		h.storming = false
+		return true
+	}
+
+	if !h.storming {
+		h.storming = true
+		common.Warnf("检测到反向解析风暴: 进程 %s(%d) 在 %s 内发起 %d 次 PTR 查询",
+			record.ProcessName, record.ProcessID, d.window, d.threshold)
+	}
+	record.Notes = append(record.Notes, fmt.Sprintf("反向解析风暴: %s 内至少 %d 次 PTR 查询", d.window, d.threshold))
+	return true
+}
+
+// 清理最近一次查询已在窗口外的进程
+func (d *PTRStormDetector) sweep(now time.Time) {
+	for pid, h := range d.processes {
+		if now.Sub(h.times[len(h.times)-1]) > d.window {
+			delete(d.processes, pid)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 速率限制丢弃记录时输出日志的间隔
+const rateLimitReportInterval = 10 * time.Second
+
+// RateLimiter 全局速率限制，在记录进入处理环节之前生效：每秒最多放行 rate 条，
+// 超出部分每 sample 条保留 1 条（sample 为 0 时全部丢弃）。按固定的一秒窗口计数，
+// 只使用原子操作，窗口切换时的少量误差可以接受
+type RateLimiter struct {
+	rate   uint64
+	sample uint64
+	exempt func(name string) bool
+
+	window  atomic.Int64  // 当前窗口的起始时间（Unix 秒）
+	count   atomic.Uint64 // 当前窗口内到达的记录数
+	dropped atomic.Uint64 // 上次输出日志以来丢弃的记录数
+}
+
+// NewRateLimiter 创建速率限制，exempt 不为 nil 时对其返回 true 的查询域名（如诱饵域名）不做限制
+func NewRateLimiter(rate, sample int, exempt func(name string) bool) *RateLimiter {
+	return &RateLimiter{
+		rate:   uint64(max(rate, 0)),
+		sample: uint64(max(sample, 0)),
+		exempt: exempt,
+	}
+}
+
+// Allow 判断记录是否放行，丢弃时计入 Dropped
+func (l *RateLimiter) Allow(record *common.DNSRecord, now time.Time) bool {
+	sec := now.Unix()
+	if w := l.window.Load(); w != sec && l.window.CompareAndSwap(w, sec) {
+		l.count.Store(0)
+	}
+	n := l.count.Add(1)
+	if n <= l.rate {
+		return true
+	}
+	if l.sample > 0 && (n-l.rate)%l.sample == 0 {
+		return true
+	}
+	if l.exempt != nil && l.exempt(record.QueryName) {
+		return true
+	}
+	l.dropped.Add(1)
+	common.Stats.Dropped.Add(1)
+	return false
+}
+
+// 定期输出丢弃的记录数
+func (l *RateLimiter) report() {
+	for range time.Tick(rateLimitReportInterval) {
+		if n := l.dropped.Swap(0); n > 0 {
+			common.Warnf("超过速率限制 %d 条/秒，过去 %s 丢弃了 %d 条记录", l.rate, rateLimitReportInterval, n)
+		}
+	}
+}
+
+var limiter atomic.Pointer[RateLimiter]
+
+// SetRateLimit 启用全局速率限制，采集端提交的记录先经过限制再进入处理协程和处理环节
+func SetRateLimit(l *RateLimiter) {
+	limiter.Store(l)
+	go l.report()
+}
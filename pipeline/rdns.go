@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 反向解析参数
+const (
+	rdnsTimeout    = time.Second
+	maxRDNSEntries = 10000
+	// 每条记录最多反向解析的地址数，未命中缓存的查询在处理协程中同步执行
+	maxRDNSLookups = 4
+)
+
+// 反向解析结果缓存，解析失败也缓存为空名称，避免反复查询
+type rdnsEntry struct {
+	name    string
+	expires time.Time
+}
+
+// ReverseDNS 对应答中的 A/AAAA 地址做反向解析（PTR），结果写入应答记录的 ptr 字段，
+// 用于发现看似正常的域名解析到可疑基础设施的情况
+type ReverseDNS struct {
+	ttl      time.Duration
+	self     uint32
+	resolver *net.Resolver
+
+	mu    sync.Mutex
+	cache map[string]rdnsEntry
+}
+
+// NewReverseDNS 创建反向解析环节，结果缓存 ttl
+func NewReverseDNS(ttl time.Duration) *ReverseDNS {
+	return &ReverseDNS{
+		ttl:      ttl,
+		self:     uint32(os.Getpid()),
+		resolver: net.DefaultResolver,
+		cache:    make(map[string]rdnsEntry),
+	}
+}
+
+// Process 实现 Stage 接口，丢弃本进程发起的查询，以免反向解析产生的流量被再次采集形成反馈循环
+func (r *ReverseDNS) Process(record *common.DNSRecord) bool {
+	if record.ProcessID == r.self {
+		return false
+	}
+
+	lookups := 0
+	for i := range record.Answers {
+		answer := &record.Answers[i]
+		if answer.Type != "A" && answer.Type != "AAAA" || answer.Data == "" {
+			continue
+		}
+		name, cached := r.cached(answer.Data)
+		if !cached {
+			if lookups >= maxRDNSLookups {
+				continue
+			}
+			lookups++
+			name = r.lookup(answer.Data)
+		}
+		answer.PTR = name
+	}
+	return true
+}
+
+// 返回未过期的缓存结果
+func (r *ReverseDNS) cached(ip string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.cache[ip]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.name, true
+}
+
+// 查询 PTR 记录并缓存，取第一个名称并去掉末尾的点
+func (r *ReverseDNS) lookup(ip string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), rdnsTimeout)
+	defer cancel()
+
+	name := ""
+	if names, err := r.resolver.LookupAddr(ctx, ip); err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.cache) >= maxRDNSEntries {
+		r.sweep(now)
+		if len(r.cache) >= maxRDNSEntries {
+			return name
+		}
+	}
+	r.cache[ip] = rdnsEntry{name: name, expires: now.Add(r.ttl)}
+	return name
+}
+
+// 清除过期的缓存，调用方需持有 mu
+func (r *ReverseDNS) sweep(now time.Time) {
+	for ip, entry := range r.cache {
+		if now.After(entry.expires) {
+			delete(r.cache, ip)
+		}
+	}
+}
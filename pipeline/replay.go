@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"dnsflux/common"
+)
+
+// 单行记录的最大长度
+const maxReplayLine = 1 << 20
+
+// Replay 从 NDJSON 文件读取记录并重新提交到处理流程，
+// realtime 为 true 时按记录时间戳的间隔回放，否则尽快回放
+func Replay(path string, realtime bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return replay(f, realtime)
+}
+
+// 逐行回放
+func replay(r io.Reader, realtime bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxReplayLine)
+
+	var last time.Time
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record common.DNSRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return fmt.Errorf("第 %d 行解析失败: %v", line, err)
+		}
+
+		// 按原始间隔等待，时间倒退的记录立即回放
+		if realtime && !last.IsZero() && record.Timestamp.After(last) {
+			time.Sleep(record.Timestamp.Sub(last))
+		}
+		if record.Timestamp.After(last) {
+			last = record.Timestamp
+		}
+
+		Submit(record)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"sync"
+
+	"dnsflux/common"
+)
+
+// 每个处理协程的队列长度，队列满时 Submit 阻塞，由采集端的缓冲吸收突发
+const workerQueueSize = 1024
+
+// 按 PID 分片的处理协程，同一进程的记录始终进入同一队列，按提交顺序处理
+var (
+	workers   []chan common.DNSRecord
+	workersMu sync.RWMutex
+	workersWG sync.WaitGroup
+	drained   bool
+)
+
+// StartWorkers 启动 n 个处理协程执行处理环节和输出，n <= 1 时保持在采集协程中同步处理。
+// 记录按 PID 分片，同一 PID 的记录保持提交顺序，不同 PID 之间不保证顺序
+func StartWorkers(n int) {
+	if n <= 1 {
+		return
+	}
+	workersMu.Lock()
+	defer workersMu.Unlock()
+
+	workers = make([]chan common.DNSRecord, n)
+	for i := range workers {
+		queue := make(chan common.DNSRecord, workerQueueSize)
+		workers[i] = queue
+		workersWG.Add(1)
+		go func() {
+			defer workersWG.Done()
+			for record := range queue {
+				process(record)
+			}
+		}()
+	}
+}
+
+// 按 PID 分片入队，未启动处理协程时返回 false；Drain 之后的记录直接丢弃
+func enqueue(record common.DNSRecord) bool {
+	workersMu.RLock()
+	defer workersMu.RUnlock()
+	if drained {
+		return true
+	}
+	if workers == nil {
+		return false
+	}
+	workers[record.ProcessID%uint32(len(workers))] <- record
+	return true
+}
+
+// Drain 停止接收新记录，等待各队列中已提交的记录处理完毕并输出处理环节缓存的记录，
+// 退出前在关闭输出端之前调用
+func Drain() {
+	workersMu.Lock()
+	drained = true
+	for _, queue := range workers {
+		close(queue)
+	}
+	workers = nil
+	workersMu.Unlock()
+
+	workersWG.Wait()
+
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	for _, stage := range stages {
+		if f, ok := stage.(Flusher); ok {
+			f.Flush()
+		}
+	}
+}
@@ -7,97 +7,418 @@
 // 定义事件结构体，增加更多信息
 struct dns_event {
     __u64 timestamp;
-    __u32 pid;
-    __u32 tgid;
+    __u64 socket_cookie;  // 套接字 cookie，用于关联同一套接字上的查询和连接
+    __u64 cgroup_id;      // 当前任务所在 cgroup v2 的 ID，进程退出后仍可用于归属容器
+    __u32 pid;        // 进程 ID（内核中的 tgid）
+    __u32 tid;        // 线程 ID（内核中的 pid）
     __u32 uid;
     __u32 gid;
     __u32 ifindex;
     char comm[64];
     __u16 sport;
     __u16 dport;
-    __u32 saddr;
-    __u32 daddr;
+    __u8 saddr[16];   // 网络字节序，IPv4 地址只占前 4 字节
+    __u8 daddr[16];
+    __u16 family;     // AF_INET 或 AF_INET6
     __u16 protocol;
-    __u16 pkt_len;
+    __u16 pkt_len;    // 实际拷贝的长度
+    __u8 direction;   // DIR_SEND 为发出的查询，DIR_RECV 为收到的响应
+    __u8 _pad;
+    __u32 orig_len;   // 发送数据的原始长度，大于 pkt_len 说明被截断
     __u8 pkt_data[512];
 };
 
+// 事件结构只经由内联函数使用，编译器不会为其生成 BTF；声明一个未使用的指针强制生成，
+// 用户态加载时据此核对 Go 结构体布局
+const struct dns_event *unused_dns_event __attribute__((unused));
+
+#define DIR_SEND 0
+#define DIR_RECV 1
+
+#define AF_INET  2
+#define AF_INET6 10
+
+// 出站连接事件，用于关联查询结果与之后的连接
+struct connect_event {
+    __u64 timestamp;
+    __u32 pid;
+    __u32 tid;
+    __u32 daddr;
+    __u16 dport;
+    __u16 protocol;
+};
+
 // 定义 ring buffer
 struct {
     __uint(type, BPF_MAP_TYPE_RINGBUF);
     __uint(max_entries, 256 * 1024);
 } events SEC(".maps");
 
-// 处理 DNS 请求的通用函数
-static __always_inline int process_dns(struct pt_regs *ctx, struct sock *sk, __u16 protocol) {
-    if (!sk)
-        return 0;
+// 连接事件的 ring buffer，仅在用户态挂载 connect kprobes 时有数据
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 64 * 1024);
+} connects SEC(".maps");
 
-    // 检查是否是 DNS 端口（源端口或目标端口为53）
-    __u16 sport, dport;
-    BPF_CORE_READ_INTO(&sport, sk, __sk_common.skc_num);
-    BPF_CORE_READ_INTO(&dport, sk, __sk_common.skc_dport);
+// 内核侧统计，下标见 STAT_*，用户态按 CPU 汇总
+#define STAT_SUBMITTED     0  // 提交到 ring buffer 的事件数
+#define STAT_DROPPED       1  // ring buffer 已满、预留失败而丢弃的事件数
+#define STAT_RINGBUF_AVAIL 2  // 最近一次提交后 ring buffer 中待读取的字节数
+#define STAT_FILTERED      3  // 命中域名过滤表而在内核中丢弃的事件数
+#define STAT_MAX           4
 
-    if (bpf_ntohs(dport) != 53 && sport != 53)
-        return 0;
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, STAT_MAX);
+    __type(key, __u32);
+    __type(value, __u64);
+} kernel_stats SEC(".maps");
 
-    // 分配事件结构体
-    struct dns_event *event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
-    if (!event)
-        return 0;
+static __always_inline void stat_add(__u32 key) {
+    __u64 *value = bpf_map_lookup_elem(&kernel_stats, &key);
+    if (value)
+        *value += 1;
+}
+
+static __always_inline void stat_set(__u32 key, __u64 v) {
+    __u64 *value = bpf_map_lookup_elem(&kernel_stats, &key);
+    if (value)
+        *value = v;
+}
+
+// 不同内核版本 struct iov_iter 的布局，由 CO-RE 重定位到运行内核的实际成员：
+// 6.4 以前 iovec 数组指针名为 iov，之后改名为 __iov；6.0 起单段用户缓冲区使用 ITER_UBUF 类型，
+// 地址和长度直接存放在 ubuf 和 count 中
+struct iov_iter___old {
+    const struct iovec *iov;
+    unsigned long nr_segs;
+} __attribute__((preserve_access_index));
+
+struct iov_iter___new {
+    __u8 iter_type;
+    void *ubuf;
+    size_t count;
+    const struct iovec *__iov;
+} __attribute__((preserve_access_index));
+
+enum iter_type___new {
+    ITER_UBUF___new = 0,
+};
+
+// 读取 msghdr 中用户数据第一段的地址和长度；数据为 iovec 数组时通过 iov 返回数组，
+// 供读取后续分段，nr_segs 为分段数
+static __always_inline void msg_data(struct msghdr *msg, void **base, size_t *len,
+                                     const struct iovec **iov, unsigned long *nr_segs) {
+    void *iter = &msg->msg_iter;
+    *base = NULL;
+    *len = 0;
+    *iov = NULL;
+    *nr_segs = 0;
+
+    if (bpf_core_enum_value_exists(enum iter_type___new, ITER_UBUF___new)) {
+        struct iov_iter___new *ubuf_iter = iter;
+        __u8 type = BPF_CORE_READ(ubuf_iter, iter_type);
+        if (type == bpf_core_enum_value(enum iter_type___new, ITER_UBUF___new)) {
+            BPF_CORE_READ_INTO(base, ubuf_iter, ubuf);
+            BPF_CORE_READ_INTO(len, ubuf_iter, count);
+            *nr_segs = 1;
+            return;
+        }
+    }
+
+    if (bpf_core_field_exists(((struct iov_iter___new *)iter)->__iov))
+        BPF_CORE_READ_INTO(iov, (struct iov_iter___new *)iter, __iov);
+    else
+        BPF_CORE_READ_INTO(iov, (struct iov_iter___old *)iter, iov);
+    if (!*iov)
+        return;
+    BPF_CORE_READ_INTO(base, *iov, iov_base);
+    BPF_CORE_READ_INTO(len, *iov, iov_len);
+    BPF_CORE_READ_INTO(nr_segs, (struct iov_iter___old *)iter, nr_segs);
+}
+
+// recvmsg 入口保存的参数，返回时数据才写入用户缓冲区
+struct recv_args {
+    struct sock *sk;
+    struct msghdr *msg;
+    void *base;       // 用户缓冲区，返回时 msg_iter 已前移，需在入口读取
+    __u16 protocol;
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 10240);
+    __type(key, __u64);   // pid_tgid
+    __type(value, struct recv_args);
+} recv_args SEC(".maps");
 
-    // 获取基本信息
+// 过滤配置，下标 0 非 0 时启用接口过滤，下标 1 非 0 时上报发往 UDP 853 端口（DoQ）的流量，
+// 下标 2 非 0 时启用内核域名过滤，下标 3 非 0 时按 dns_ports 判断 DNS 端口
+#define CONFIG_IFINDEX_FILTER 0
+#define CONFIG_DETECT_DOQ     1
+#define CONFIG_DOMAIN_FILTER  2
+#define CONFIG_DNS_PORTS      3
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 4);
+    __type(key, __u32);
+    __type(value, __u32);
+} filter_config SEC(".maps");
+
+// 需要在内核中丢弃的域名，键为小写、以点分隔、不带末尾点的完整域名，不足部分补 0；
+// 更长的域名和通配、正则等条件由用户态过滤
+#define DOMAIN_KEY_LEN 128
+
+struct domain_key {
+    char name[DOMAIN_KEY_LEN];
+};
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 65536);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct domain_key);
+    __type(value, __u8);
+} domain_filter SEC(".maps");
+
+// 视为明文 DNS 的目标端口（主机字节序），由用户态写入 53 和配置的其他端口
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 16);
+    __type(key, __u16);
+    __type(value, __u8);
+} dns_ports SEC(".maps");
+
+// 允许的网络接口，由用户态根据 --interface 写入
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 64);
+    __type(key, __u32);
+    __type(value, __u8);
+} ifindex_filter SEC(".maps");
+
+// 检查套接字绑定的接口是否允许，未绑定接口（0）交由用户态按路由判断
+static __always_inline bool ifindex_allowed(__u32 ifindex) {
+    __u32 key = CONFIG_IFINDEX_FILTER;
+    __u32 *enabled = bpf_map_lookup_elem(&filter_config, &key);
+    if (!enabled || !*enabled || ifindex == 0)
+        return true;
+    return bpf_map_lookup_elem(&ifindex_filter, &ifindex) != NULL;
+}
+
+// 检查目标端口（主机字节序）是否为 DNS 端口，未配置其他端口时只有 53
+static __always_inline bool is_dns_port(__u16 port) {
+    __u32 key = CONFIG_DNS_PORTS;
+    __u32 *enabled = bpf_map_lookup_elem(&filter_config, &key);
+    if (!enabled || !*enabled)
+        return port == 53;
+    return bpf_map_lookup_elem(&dns_ports, &port) != NULL;
+}
+
+// 检查是否启用了 DoQ 检测
+static __always_inline bool doq_enabled(void) {
+    __u32 key = CONFIG_DETECT_DOQ;
+    __u32 *enabled = bpf_map_lookup_elem(&filter_config, &key);
+    return enabled && *enabled;
+}
+
+// 检查报文的查询域名是否在 domain_filter 中，只处理不带长度前缀的 UDP 报文。
+// 将问题部分的标签序列转换为点分形式：标签长度字节（第一个除外）替换为点，遇到 0 结束
+static __always_inline bool domain_filtered(struct dns_event *event) {
+    __u32 key0 = CONFIG_DOMAIN_FILTER;
+    __u32 *enabled = bpf_map_lookup_elem(&filter_config, &key0);
+    if (!enabled || !*enabled || event->protocol != 17 || event->pkt_len <= 13)
+        return false;
+
+    struct domain_key key = {};
+    __u32 remaining = event->pkt_data[12];
+    // 根域名或压缩指针交由用户态处理
+    if (remaining == 0 || remaining >= 64)
+        return false;
+
+    for (__u32 i = 0; i < DOMAIN_KEY_LEN; i++) {
+        __u32 off = 13 + i;
+        if (off >= event->pkt_len)
+            return false;
+        __u8 c = event->pkt_data[off & (sizeof(event->pkt_data) - 1)];
+        if (remaining == 0) {
+            if (c == 0)
+                return bpf_map_lookup_elem(&domain_filter, &key) != NULL;
+            if (c >= 64)
+                return false;
+            key.name[i] = '.';
+            remaining = c;
+            continue;
+        }
+        if (c >= 'A' && c <= 'Z')
+            c += 'a' - 'A';
+        key.name[i] = c;
+        remaining--;
+    }
+    // 超出键长度的域名由用户态过滤
+    return false;
+}
+
+// 丢弃命中域名过滤表的事件，返回 true 表示已丢弃
+static __always_inline bool discard_filtered(struct dns_event *event) {
+    if (!domain_filtered(event))
+        return false;
+    bpf_ringbuf_discard(event, 0);
+    stat_add(STAT_FILTERED);
+    return true;
+}
+
+// 填充进程、套接字等基本信息，端口和报文内容由调用方填充
+static __always_inline void fill_event(struct dns_event *event, struct sock *sk, __u16 protocol, __u32 ifindex) {
     __u64 pid_tgid = bpf_get_current_pid_tgid();
     __u64 uid_gid = bpf_get_current_uid_gid();
 
     event->timestamp = bpf_ktime_get_ns();
+
+    // kprobe 程序不能调用 bpf_get_socket_cookie，直接读取内核缓存的 cookie；
+    // cookie 在首次被请求时才生成，为 0 时说明尚无其他组件为该套接字生成过
+    event->socket_cookie = BPF_CORE_READ(sk, __sk_common.skc_cookie.counter);
+    event->cgroup_id = bpf_get_current_cgroup_id();
+    // 高 32 位为 tgid，即用户态看到的进程 ID；低 32 位为线程 ID
     event->pid = pid_tgid >> 32;
-    event->tgid = pid_tgid & 0xFFFFFFFF;
+    event->tid = pid_tgid & 0xFFFFFFFF;
     event->uid = uid_gid & 0xFFFFFFFF;
     event->gid = uid_gid >> 32;
 
     // 获取进程名
     bpf_get_current_comm(&event->comm, sizeof(event->comm));
 
-    // 获取网络信息
-    event->sport = sport;
-    event->dport = dport;
-    BPF_CORE_READ_INTO(&event->saddr, sk, __sk_common.skc_rcv_saddr);
-    BPF_CORE_READ_INTO(&event->daddr, sk, __sk_common.skc_daddr);
-    BPF_CORE_READ_INTO(&event->ifindex, sk, __sk_common.skc_bound_dev_if);
+    // 获取网络信息，IPv6 套接字发往 IPv4 地址时为 ::ffff:a.b.c.d 形式的映射地址
+    BPF_CORE_READ_INTO(&event->family, sk, __sk_common.skc_family);
+    if (event->family == AF_INET6) {
+        BPF_CORE_READ_INTO(&event->saddr, sk, __sk_common.skc_v6_rcv_saddr.in6_u.u6_addr8);
+        BPF_CORE_READ_INTO(&event->daddr, sk, __sk_common.skc_v6_daddr.in6_u.u6_addr8);
+    } else {
+        BPF_CORE_READ_INTO((__u32 *)event->saddr, sk, __sk_common.skc_rcv_saddr);
+        BPF_CORE_READ_INTO((__u32 *)event->daddr, sk, __sk_common.skc_daddr);
+    }
+    event->ifindex = ifindex;
     event->protocol = protocol;
+    event->pkt_len = 0;
+    event->orig_len = 0;
+}
+
+// 提交事件并更新统计
+static __always_inline void submit_event(struct dns_event *event) {
+    bpf_ringbuf_submit(event, 0);
+    stat_add(STAT_SUBMITTED);
+    stat_set(STAT_RINGBUF_AVAIL, bpf_ringbuf_query(&events, BPF_RB_AVAIL_DATA));
+}
+
+// 处理 DNS 请求的通用函数
+static __always_inline int process_dns(struct pt_regs *ctx, struct sock *sk, __u16 protocol) {
+    if (!sk)
+        return 0;
 
-    // 获取数据包内容
+    // 检查是否是 DNS 端口（目标端口为 DNS 端口，或源端口为 53），其他流量不拷贝到 ring buffer
+    __u16 sport, dport;
+    BPF_CORE_READ_INTO(&sport, sk, __sk_common.skc_num);
+    BPF_CORE_READ_INTO(&dport, sk, __sk_common.skc_dport);
+
+    // 未连接的 UDP 套接字（sendto）没有对端，目标地址和端口在内核拷贝的 msg_name 中
     struct msghdr *msg = (struct msghdr *)PT_REGS_PARM2(ctx);
-    if (msg) {
-        struct iovec *iov;
-        BPF_CORE_READ_INTO(&iov, msg, msg_iter.iov);
-        if (iov) {
-            void *base;
-            size_t len;
-            BPF_CORE_READ_INTO(&base, iov, iov_base);
-            BPF_CORE_READ_INTO(&len, iov, iov_len);
-
-            if (base && len <= sizeof(event->pkt_data)) {
-                bpf_probe_read_user(event->pkt_data, len, base);
-                event->pkt_len = len;
+    struct sockaddr_in6 to = {};
+    if (dport == 0 && protocol == 17 && msg) {
+        void *name;
+        BPF_CORE_READ_INTO(&name, msg, msg_name);
+        // sockaddr_in 与 sockaddr_in6 的族和端口位置相同
+        if (name && !bpf_probe_read_kernel(&to, sizeof(to), name) &&
+            (to.sin6_family == AF_INET || to.sin6_family == AF_INET6))
+            dport = to.sin6_port;
+        else
+            to.sin6_family = 0;
+    }
+
+    // DNS over QUIC 发往 UDP 853 端口，报文已加密，只上报连接信息不拷贝内容
+    bool doq = protocol == 17 && bpf_ntohs(dport) == 853 && doq_enabled();
+    if (!is_dns_port(bpf_ntohs(dport)) && sport != 53 && !doq)
+        return 0;
+
+    // 在内核中提前过滤不关心的网络接口，减少 ring buffer 占用
+    __u32 ifindex = 0;
+    BPF_CORE_READ_INTO(&ifindex, sk, __sk_common.skc_bound_dev_if);
+    if (!ifindex_allowed(ifindex))
+        return 0;
+
+    // 分配事件结构体
+    struct dns_event *event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        stat_add(STAT_DROPPED);
+        return 0;
+    }
+
+    fill_event(event, sk, protocol, ifindex);
+    event->sport = sport;
+    event->dport = dport;
+    event->direction = DIR_SEND;
+    if (to.sin6_family == AF_INET6) {
+        event->family = AF_INET6;
+        __builtin_memcpy(event->daddr, &to.sin6_addr, 16);
+    } else if (to.sin6_family == AF_INET) {
+        event->family = AF_INET;
+        __builtin_memcpy(event->daddr, &((struct sockaddr_in *)&to)->sin_addr, 4);
+    }
+
+    // 获取数据包内容，超出缓冲区的部分截断
+    if (msg && !doq) {
+        void *base;
+        size_t len;
+        const struct iovec *iov;
+        unsigned long nr_segs;
+        msg_data(msg, &base, &len, &iov, &nr_segs);
+        if (base) {
+            // TCP 上的 DNS 报文带 2 字节长度前缀，glibc 等以 writev 将前缀和报文分两段发送，
+            // 此时读取第二段；前缀与报文在同一段时由用户态跳过
+            if (protocol == 6 && len == 2 && iov && nr_segs > 1) {
+                BPF_CORE_READ_INTO(&base, iov + 1, iov_base);
+                BPF_CORE_READ_INTO(&len, iov + 1, iov_len);
+            }
+
+            event->orig_len = len;
+
+            // 以 64 位计算拷贝长度，32 位时编译器会把截断前的值传给辅助函数，校验器无法确认上界
+            __u64 copy = len;
+            if (copy > sizeof(event->pkt_data))
+                copy = sizeof(event->pkt_data);
+            if (base && copy > 0) {
+                bpf_probe_read_user(event->pkt_data, copy, base);
+                event->pkt_len = copy;
             }
         }
     }
 
-    // 转换地址为网络字节序
-    event->saddr = bpf_htonl(event->saddr);
-    event->daddr = bpf_htonl(event->daddr);
+    if (!doq && discard_filtered(event))
+        return 0;
+
+    // 转换端口字节序，地址保持网络字节序
     event->sport = bpf_htons(event->sport);
     event->dport = bpf_htons(event->dport);
 
-    bpf_ringbuf_submit(event, 0);
+    submit_event(event);
     return 0;
 }
 
 // 跟踪UDP数据包
 SEC("kprobe/udp_sendmsg")
 int trace_udp_sendmsg(struct pt_regs *ctx) {
+    struct sock *sk = (struct sock *)PT_REGS_PARM1(ctx);
+    // IPv6 套接字发往映射地址时由 udpv6_sendmsg 转入，已在那里上报
+    __u16 family = 0;
+    BPF_CORE_READ_INTO(&family, sk, __sk_common.skc_family);
+    if (family == AF_INET6)
+        return 0;
+    return process_dns(ctx, sk, 17); // UDP
+}
+
+// 跟踪 IPv6 UDP 数据包
+SEC("kprobe/udpv6_sendmsg")
+int trace_udpv6_sendmsg(struct pt_regs *ctx) {
     return process_dns(ctx, (struct sock *)PT_REGS_PARM1(ctx), 17); // UDP
 }
 
@@ -107,4 +428,165 @@ int trace_tcp_sendmsg(struct pt_regs *ctx) {
     return process_dns(ctx, (struct sock *)PT_REGS_PARM1(ctx), 6);  // TCP
 }
 
+// recvmsg 入口：记录参数和用户缓冲区地址，等返回时读取收到的数据
+static __always_inline int enter_recv(struct pt_regs *ctx, __u16 protocol) {
+    struct sock *sk = (struct sock *)PT_REGS_PARM1(ctx);
+    struct msghdr *msg = (struct msghdr *)PT_REGS_PARM2(ctx);
+    if (!sk || !msg)
+        return 0;
+
+    struct recv_args args = {.sk = sk, .msg = msg, .protocol = protocol};
+    size_t len;
+    const struct iovec *iov;
+    unsigned long nr_segs;
+    msg_data(msg, &args.base, &len, &iov, &nr_segs);
+    if (!args.base)
+        return 0;
+
+    __u64 pid_tgid = bpf_get_current_pid_tgid();
+    bpf_map_update_elem(&recv_args, &pid_tgid, &args, BPF_ANY);
+    return 0;
+}
+
+// recvmsg 返回：来自 DNS 端口的数据作为响应上报，返回值为收到的字节数
+static __always_inline int exit_recv(struct pt_regs *ctx) {
+    __u64 pid_tgid = bpf_get_current_pid_tgid();
+    struct recv_args *args = bpf_map_lookup_elem(&recv_args, &pid_tgid);
+    if (!args)
+        return 0;
+    // 先取出指针再读取，在 CO-RE 读取宏中访问 args 成员会生成对本地结构体的重定位
+    struct sock *sk = args->sk;
+    struct msghdr *msg = args->msg;
+    void *base = args->base;
+    __u16 protocol = args->protocol;
+    bpf_map_delete_elem(&recv_args, &pid_tgid);
+
+    long ret = PT_REGS_RC(ctx);
+    if (ret <= 0)
+        return 0;
+
+    // 已连接的套接字从套接字读取对端地址，未连接的 UDP 套接字从 msg_name 读取来源地址
+    __u16 sport, dport;
+    BPF_CORE_READ_INTO(&sport, sk, __sk_common.skc_num);
+    BPF_CORE_READ_INTO(&dport, sk, __sk_common.skc_dport);
+    struct sockaddr_in6 from = {};
+    if (dport == 0) {
+        void *name;
+        BPF_CORE_READ_INTO(&name, msg, msg_name);
+        // sockaddr_in 与 sockaddr_in6 的族和端口位置相同
+        if (!name || bpf_probe_read_kernel(&from, sizeof(from), name))
+            return 0;
+        if (from.sin6_family != AF_INET && from.sin6_family != AF_INET6)
+            return 0;
+        dport = from.sin6_port;
+    }
+    // 本机作为 DNS 服务端收到的查询不在此上报
+    if (!is_dns_port(bpf_ntohs(dport)) || sport == 53)
+        return 0;
+
+    __u32 ifindex = 0;
+    BPF_CORE_READ_INTO(&ifindex, sk, __sk_common.skc_bound_dev_if);
+    if (!ifindex_allowed(ifindex))
+        return 0;
+
+    struct dns_event *event = bpf_ringbuf_reserve(&events, sizeof(*event), 0);
+    if (!event) {
+        stat_add(STAT_DROPPED);
+        return 0;
+    }
+
+    fill_event(event, sk, protocol, ifindex);
+    event->direction = DIR_RECV;
+    if (from.sin6_family == AF_INET6) {
+        event->family = AF_INET6;
+        __builtin_memcpy(event->daddr, &from.sin6_addr, 16);
+    } else if (from.sin6_family == AF_INET) {
+        event->family = AF_INET;
+        __builtin_memcpy(event->daddr, &((struct sockaddr_in *)&from)->sin_addr, 4);
+    }
+    // 与发送路径保持相同的字节序转换
+    event->dport = bpf_htons(dport);
+    event->sport = bpf_htons(sport);
+
+    event->orig_len = ret;
+    __u64 copy = ret;
+    if (copy > sizeof(event->pkt_data))
+        copy = sizeof(event->pkt_data);
+    if (copy > 0 && !bpf_probe_read_user(event->pkt_data, copy, base))
+        event->pkt_len = copy;
+
+    if (discard_filtered(event))
+        return 0;
+
+    submit_event(event);
+    return 0;
+}
+
+SEC("kprobe/udp_recvmsg")
+int trace_udp_recvmsg(struct pt_regs *ctx) {
+    return enter_recv(ctx, 17);
+}
+
+SEC("kretprobe/udp_recvmsg")
+int trace_udp_recvmsg_ret(struct pt_regs *ctx) {
+    return exit_recv(ctx);
+}
+
+SEC("kprobe/udpv6_recvmsg")
+int trace_udpv6_recvmsg(struct pt_regs *ctx) {
+    return enter_recv(ctx, 17);
+}
+
+SEC("kretprobe/udpv6_recvmsg")
+int trace_udpv6_recvmsg_ret(struct pt_regs *ctx) {
+    return exit_recv(ctx);
+}
+
+SEC("kprobe/tcp_recvmsg")
+int trace_tcp_recvmsg(struct pt_regs *ctx) {
+    return enter_recv(ctx, 6);
+}
+
+SEC("kretprobe/tcp_recvmsg")
+int trace_tcp_recvmsg_ret(struct pt_regs *ctx) {
+    return exit_recv(ctx);
+}
+
+// 处理 IPv4 connect，uaddr 已由内核拷贝到内核空间
+static __always_inline int process_connect(struct sockaddr *uaddr, __u16 protocol) {
+    struct sockaddr_in addr = {};
+    if (!uaddr || bpf_probe_read_kernel(&addr, sizeof(addr), uaddr))
+        return 0;
+    if (addr.sin_family != AF_INET)
+        return 0;
+
+    struct connect_event *event = bpf_ringbuf_reserve(&connects, sizeof(*event), 0);
+    if (!event)
+        return 0;
+
+    __u64 pid_tgid = bpf_get_current_pid_tgid();
+    event->timestamp = bpf_ktime_get_ns();
+    event->pid = pid_tgid >> 32;
+    event->tid = pid_tgid & 0xFFFFFFFF;
+    // 转换为主机字节序，用户态直接按数值使用
+    event->daddr = bpf_ntohl(addr.sin_addr.s_addr);
+    event->dport = bpf_ntohs(addr.sin_port);
+    event->protocol = protocol;
+
+    bpf_ringbuf_submit(event, 0);
+    return 0;
+}
+
+// 跟踪TCP连接
+SEC("kprobe/tcp_v4_connect")
+int trace_tcp_v4_connect(struct pt_regs *ctx) {
+    return process_connect((struct sockaddr *)PT_REGS_PARM2(ctx), 6);
+}
+
+// 跟踪已连接的UDP套接字
+SEC("kprobe/ip4_datagram_connect")
+int trace_ip4_datagram_connect(struct pt_regs *ctx) {
+    return process_connect((struct sockaddr *)PT_REGS_PARM2(ctx), 17);
+}
+
 char LICENSE[] SEC("license") = "GPL";
\ No newline at end of file
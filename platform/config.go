@@ -0,0 +1,207 @@
+package platform
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Config 监控配置，部分字段仅对特定平台生效
+type Config struct {
+	// 事件ID白名单，为空则不过滤（Windows）
+	EventIDWhitelist []uint16 `json:"eventIdWhitelist"`
+	// 域名黑名单，为空则不过滤
+	DomainBlacklist []string `json:"domainBlacklist"`
+	// hosts 格式的拦截列表文件，其中的域名追加到域名黑名单
+	DomainBlacklistHosts []string `json:"domainBlacklistHosts"`
+	// 域名白名单，命中的查询视为已批准而不输出，先于黑名单判断，为空则不过滤
+	DomainAllowlist []string `json:"domainAllowlist"`
+	// 黑白名单的匹配方式：substring 为包含匹配（默认），exact 为完整域名匹配
+	DomainMatch string `json:"domainMatch"`
+	// 将国际化域名的 punycode 形式解码为 Unicode 写入 queryNameUnicode 用于展示，匹配仍使用 punycode 形式
+	DecodeIDN bool `json:"decodeIdn"`
+	// 进程名黑名单，这些进程发起的查询不输出，不区分大小写
+	ProcessDenylist []string `json:"processDenylist"`
+	// 进程名白名单，只输出这些进程发起的查询，为空则不限制，不区分大小写
+	ProcessAllowlist []string `json:"processAllowlist"`
+	// 只输出这些 PID 发起的查询，为空则不限制
+	PIDAllowlist []uint32 `json:"pidAllowlist"`
+	// 不输出这些 PID 发起的查询
+	PIDDenylist []uint32 `json:"pidDenylist"`
+	// 只输出该进程及其子孙进程发起的查询，0 表示不限制
+	FollowPID uint32 `json:"followPid"`
+	// 只输出该名称的进程及其子孙进程发起的查询，不区分大小写，为空表示不限制
+	FollowProcess string `json:"followProcess"`
+	// 要启用的 ETW Provider（GUID 或注册名称），为空时为 Microsoft-Windows-DNS-Client（Windows）
+	Providers []string `json:"providers"`
+	// Provider 的启用级别和关键字，在 ETW 层面减少投递的事件（Windows）
+	Provider ProviderConfig `json:"provider"`
+	// ETW 会话名称，同时运行多个实例时需各不相同（Windows）
+	SessionName string `json:"sessionName"`
+	// ETW 会话缓冲配置，调小刷新间隔可降低事件投递延迟（Windows）
+	SessionBuffers SessionBufferConfig `json:"sessionBuffers"`
+	// 是否包含发往回环地址的查询，本机运行缓存解析器时可关闭以减少噪音（Linux）
+	IncludeLoopback bool `json:"includeLoopback"`
+	// 仅监控这些网络命名空间（名称或 inode），为空则监控全部（Linux）
+	NetNamespaces []string `json:"netNamespaces"`
+	// 仅监控经由这些网络接口发出的查询，为空则监控全部（Linux）
+	Interfaces []string `json:"interfaces"`
+	// 除 53 以外视为明文 DNS 的目标端口（如本机解析器监听的 5353），只有发往这些端口的流量才会在内核中拷贝（Linux）
+	DNSPorts []uint16 `json:"dnsPorts"`
+	// 将发往 UDP 853 端口的流量作为可能的 DNS over QUIC 上报（Linux）
+	DetectDoQ bool `json:"detectDoQ"`
+	// 将发往 DoT 端口或已知 DoH 解析器的连接作为可能的加密 DNS 上报，需要采集连接事件（Linux）
+	DetectEncryptedDNS bool `json:"detectEncryptedDns"`
+	// 视为 DoT 的 TCP 目标端口，为空时为 853
+	DoTPorts []uint16 `json:"dotPorts"`
+	// DoH 解析器地址（IP 或 CIDR），追加到内置的公共解析器列表
+	DoHAddresses []string `json:"dohAddresses"`
+	// 连接 DoH 解析器时视为 DoH 的目标端口，为空时为 443
+	DoHPorts []uint16 `json:"dohPorts"`
+	// bpffs 中固定 eBPF map 和 kprobe link 的目录，重启时复用已加载的探针，为空时不固定（Linux）
+	PinPath string `json:"pinPath"`
+	// 退出时保留固定的对象，探针在进程重启期间继续采集；否则退出时清理（Linux）
+	KeepPinned bool `json:"keepPinned"`
+	// 采集出站连接事件，用于关联查询结果与之后的连接（Linux）
+	TrackConnections bool `json:"trackConnections"`
+	// 同时采集收到的 DNS 响应，输出应答记录（Linux）
+	CaptureResponses bool `json:"captureResponses"`
+	// 进程信息缓存，减少每个事件读取 /proc 或调用 OpenProcess 的开销
+	ProcessCache ProcessCacheConfig `json:"processCache"`
+	// 疑似算法生成（DGA）域名的检测参数，两个平台共用
+	DGA DGAConfig `json:"dga"`
+	// 控制台输出格式：text、json 或 csv，为空时为 text
+	Format string `json:"format"`
+	// 输出时区：IANA 名称或固定偏移（如 +08:00），为空时取 DNSMONITOR_TZ 环境变量，再为空使用系统本地时区
+	Timezone string `json:"timezone"`
+}
+
+// DGA 检测配置
+type DGAConfig struct {
+	// 是否启用检测
+	Enabled bool `json:"enabled"`
+	// 熵阈值（比特/字符），最长标签的熵达到该值时标注
+	Entropy float64 `json:"entropy"`
+	// 参与熵判断的最短标签长度，短标签的熵上限较低，容易误报
+	MinLength int `json:"minLength"`
+	// 标签长度达到该值时直接标注，0 表示不检查
+	MaxLabelLength int `json:"maxLabelLength"`
+	// 不参与检测的域名，追加到内置的 CDN 排除列表，按完全匹配或后缀匹配
+	Exclude []string `json:"exclude"`
+}
+
+// 进程信息缓存配置
+type ProcessCacheConfig struct {
+	// 最多缓存的进程数，0 表示不缓存
+	Size int `json:"size"`
+	// 条目有效期（秒），过期后重新读取，避免 PID 复用后返回旧进程的信息
+	TTL uint32 `json:"ttl"`
+}
+
+// ETW 会话缓冲配置
+type SessionBufferConfig struct {
+	// 单个缓冲区大小（KB），ETW 事件最大可达 64KB，过小会丢事件
+	BufferSize uint32 `json:"bufferSize"`
+	// 最小/最大缓冲区数量，0 表示由系统决定
+	MinimumBuffers uint32 `json:"minimumBuffers"`
+	MaximumBuffers uint32 `json:"maximumBuffers"`
+	// 缓冲区刷新间隔（秒），0 表示由系统决定（缓冲区写满才投递）
+	FlushTimer uint32 `json:"flushTimer"`
+}
+
+// ETW Provider 启用参数
+type ProviderConfig struct {
+	// 最高事件级别：1 严重、2 错误、3 警告、4 信息、5 详细，0xff 表示全部
+	Level uint8 `json:"level"`
+	// 事件关键字至少匹配其中一位才投递，0 表示不按关键字过滤
+	MatchAnyKeyword uint64 `json:"matchAnyKeyword"`
+	// 事件关键字必须包含全部这些位才投递，0 表示不要求
+	MatchAllKeyword uint64 `json:"matchAllKeyword"`
+}
+
+// 默认的 ETW 会话名称
+const defaultSessionName = "DNSMonitor"
+
+// 配置事件白名单ID和域名黑名单
+var config = DefaultConfig()
+
+// DefaultConfig 返回内置默认配置
+func DefaultConfig() Config {
+	return Config{
+		// DNS查询事件ID：3006【开始查询】，3008【已完成的查询】，3009【发起索引查询】，3010【发起DNS服务查询】，3011【DNS服务器响应】，3018【缓存查询响应】，3020【索引查询响应】
+		EventIDWhitelist: []uint16{3008, 3011},
+		DomainBlacklist:  []string{"localhost"},
+		ProcessDenylist:  defaultProcessDenylist,
+		Provider:         ProviderConfig{Level: 0xff},
+		SessionName:      defaultSessionName,
+		// 64KB 缓冲区，每秒刷新一次，兼顾实时性与开销
+		SessionBuffers: SessionBufferConfig{
+			BufferSize: 64,
+			FlushTimer: 1,
+		},
+		IncludeLoopback: true,
+		ProcessCache: ProcessCacheConfig{
+			Size: defaultProcessCacheSize,
+			TTL:  defaultProcessCacheTTL,
+		},
+		DGA: DGAConfig{
+			Entropy:        3.5,
+			MinLength:      12,
+			MaxLabelLength: 45,
+		},
+		Timezone: defaultTimezone(),
+	}
+}
+
+// Describe 以 key=value 形式概括监控后端和生效的过滤条件，用于启动信息
+func Describe(cfg Config) string {
+	match := cfg.DomainMatch
+	if match == "" {
+		match = domainMatchSubstring
+	}
+	desc := fmt.Sprintf("%s allowlist=%d blacklist=%d match=%s process-allowlist=%d process-denylist=%d pid-allowlist=%d pid-denylist=%d tz=%s",
+		describeBackend(cfg), len(cfg.DomainAllowlist), len(cfg.DomainBlacklist), match,
+		len(cfg.ProcessAllowlist), len(cfg.ProcessDenylist), len(cfg.PIDAllowlist), len(cfg.PIDDenylist), timezoneName(cfg.Timezone))
+	if cfg.FollowPID != 0 {
+		desc += fmt.Sprintf(" follow-pid=%d", cfg.FollowPID)
+	}
+	if cfg.FollowProcess != "" {
+		desc += " follow-process=" + cfg.FollowProcess
+	}
+	return desc
+}
+
+// 按 PID 和进程名的黑白名单以及跟随的进程树判断是否过滤该进程的查询，进程名不区分大小写
+func isProcessFiltered(pid uint32, name string, cfg Config) bool {
+	if slices.Contains(cfg.PIDDenylist, pid) {
+		return true
+	}
+	if len(cfg.PIDAllowlist) > 0 && !slices.Contains(cfg.PIDAllowlist, pid) {
+		return true
+	}
+	if len(cfg.ProcessAllowlist) > 0 && !processNameListed(name, cfg.ProcessAllowlist) {
+		return true
+	}
+	if processNameListed(name, cfg.ProcessDenylist) {
+		return true
+	}
+	return followTree != nil && !followTree.contains(pid, name)
+}
+
+// 判断进程名是否在列表中
+func processNameListed(name string, list []string) bool {
+	for _, listed := range list {
+		if strings.EqualFold(name, listed) {
+			return true
+		}
+	}
+	return false
+}
+
+// 列表为空时显示为 all
+func listOrAll(items []string) string {
+	if len(items) == 0 {
+		return "all"
+	}
+	return strings.Join(items, ",")
+}
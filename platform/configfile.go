@@ -0,0 +1,155 @@
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// ETW Provider 的 GUID 和注册名称格式
+var (
+	providerGUIDPattern = regexp.MustCompile(`^\{?[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}?$`)
+	providerNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+)
+
+// 除 53 以外最多可配置的 DNS 端口数
+const maxDNSPorts = 15
+
+// DNS-Client Provider 中与查询相关的事件 ID
+var knownEventIDs = []uint16{3006, 3008, 3009, 3010, 3011, 3016, 3018, 3019, 3020}
+
+// LoadConfig 加载单个 JSON 或 YAML 配置文件（按扩展名 .yaml/.yml 区分）并校验，
+// path 为空时返回内置默认配置
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return LoadConfigFiles(nil)
+	}
+	return LoadConfigFiles([]string{path})
+}
+
+// LoadConfigFiles 在默认配置之上按顺序合并 JSON 或 YAML 配置文件并校验：
+// 列表字段追加到已有列表，其他字段由后面的文件覆盖，文件中未出现的字段保持不变
+func LoadConfigFiles(paths []string) (Config, error) {
+	cfg := DefaultConfig()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("%w: 读取配置文件失败: %v", ErrConfig, err)
+		}
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+			if data, err = yamlToJSON(data); err != nil {
+				return cfg, fmt.Errorf("%w: 配置文件 %s 无效: %v", ErrConfig, path, err)
+			}
+		}
+		if err := mergeJSON(reflect.ValueOf(&cfg).Elem(), data); err != nil {
+			return cfg, fmt.Errorf("%w: 配置文件 %s 无效: %v", ErrConfig, path, err)
+		}
+	}
+	if err := validateConfig(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// 校验合并后的配置：输出格式、时区或 ETW Provider 无效时返回错误，未知的事件 ID 输出警告后忽略
+func validateConfig(cfg *Config) error {
+	switch cfg.Format {
+	case "", "text", "json", "csv":
+	default:
+		return fmt.Errorf("%w: 未知的输出格式 %q，可选 text、json、csv", ErrConfig, cfg.Format)
+	}
+	if _, err := loadTimezone(cfg.Timezone); err != nil {
+		return err
+	}
+	switch cfg.DomainMatch {
+	case "", domainMatchSubstring, domainMatchExact:
+	default:
+		return fmt.Errorf("%w: 未知的域名匹配方式 %q，可选 substring、exact", ErrConfig, cfg.DomainMatch)
+	}
+	for _, provider := range cfg.Providers {
+		if !providerGUIDPattern.MatchString(provider) && !providerNamePattern.MatchString(provider) {
+			return fmt.Errorf("%w: ETW Provider %q 无效，应为 {xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx} 形式的 GUID 或注册名称", ErrConfig, provider)
+		}
+	}
+	if cfg.SessionName = strings.TrimSpace(cfg.SessionName); cfg.SessionName == "" {
+		cfg.SessionName = defaultSessionName
+	}
+	// 内核中的端口表最多 16 项，其中一项为 53
+	if len(cfg.DNSPorts) > maxDNSPorts {
+		return fmt.Errorf("%w: dnsPorts 最多 %d 个", ErrConfig, maxDNSPorts)
+	}
+	if slices.Contains(cfg.DNSPorts, 0) {
+		return fmt.Errorf("%w: dnsPorts 中的端口 0 无效", ErrConfig)
+	}
+	if cfg.DGA.Entropy < 0 || cfg.DGA.MinLength < 0 || cfg.DGA.MaxLabelLength < 0 {
+		return fmt.Errorf("%w: dga 的阈值不能为负数", ErrConfig)
+	}
+	if err := compileDomainFilters(*cfg); err != nil {
+		return err
+	}
+
+	ids := cfg.EventIDWhitelist[:0]
+	for _, id := range cfg.EventIDWhitelist {
+		if !slices.Contains(knownEventIDs, id) {
+			common.Warnf("忽略未知的事件 ID %d，可用的事件 ID: %v", id, knownEventIDs)
+			continue
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	cfg.EventIDWhitelist = ids
+	return nil
+}
+
+// 将 JSON 对象合并到结构体，键按 json 标签匹配（不区分大小写）
+func mergeJSON(dst reflect.Value, data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for key, raw := range fields {
+		field, ok := fieldByTag(dst, key)
+		if !ok {
+			return fmt.Errorf("未知的配置项 %q", key)
+		}
+
+		switch field.Kind() {
+		case reflect.Slice:
+			items := reflect.New(field.Type())
+			if err := json.Unmarshal(raw, items.Interface()); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			field.Set(reflect.AppendSlice(field, items.Elem()))
+		case reflect.Struct:
+			if err := mergeJSON(field, raw); err != nil {
+				return fmt.Errorf("%s.%v", key, err)
+			}
+		default:
+			if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 按 json 标签查找结构体字段
+func fieldByTag(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if strings.EqualFold(name, key) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
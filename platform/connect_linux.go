@@ -0,0 +1,57 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"dnsflux/pipeline"
+
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// 与 C 结构体 connect_event 完全匹配
+type connectEvent struct {
+	Timestamp uint64
+	PID       uint32
+	TID       uint32
+	Daddr     uint32
+	Dport     uint16
+	Protocol  uint16
+}
+
+// 读取连接事件并提交给关联环节，启用加密 DNS 检测时上报可能的 DoT/DoH 连接，读取器关闭时返回
+func (c *bpfCollector) readConnects() {
+	var event connectEvent
+	for {
+		record, err := c.connReader.Read()
+		if err != nil {
+			if errors.Is(err, ringbuf.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
+			continue
+		}
+
+		proto := "UNK"
+		if p, ok := protocolMap[event.Protocol]; ok {
+			proto = p
+		}
+		ip := eventAddr(event.Daddr)
+		if encryptedDNS != nil && !pipeline.Paused() {
+			if kind := encryptedDNS.classify(ip, event.Dport, proto); kind != "" {
+				handleEncryptedConnect(&event, ip, proto, kind)
+			}
+		}
+		pipeline.Connect(pipeline.ConnectEvent{
+			Timestamp: time.Now(),
+			ProcessID: event.PID,
+			IP:        ip,
+			Port:      event.Dport,
+			Protocol:  proto,
+		})
+	}
+}
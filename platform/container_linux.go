@@ -0,0 +1,82 @@
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 按 cgroup ID 缓存的容器数量上限，超出时清空重建
+const maxCgroupContainers = 4096
+
+var (
+	// Docker、containerd、CRI-O 等运行时的 cgroup 路径中包含 64 位十六进制的容器 ID，
+	// 如 /docker/<id>、/system.slice/docker-<id>.scope、.../cri-containerd-<id>.scope
+	containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+	// Kubernetes 的 Pod cgroup，systemd 驱动下 UID 中的 - 写作 _，如 kubepods-besteffort-pod<uid>.slice
+	podUIDPattern = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// 容器信息，主机进程为零值
+type containerInfo struct {
+	ID     string
+	PodUID string
+}
+
+// 事件中的 cgroup ID 到容器的映射，进程已退出、无法读取 /proc 时据此归属容器
+var (
+	cgroupContainers   = make(map[uint64]containerInfo)
+	cgroupContainersMu sync.Mutex
+)
+
+// 读取 /proc/<pid>/cgroup 中的容器 ID 和 Pod UID
+func readContainerInfo(pid uint32) containerInfo {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return containerInfo{}
+	}
+	return parseCgroupContainer(string(data))
+}
+
+// 解析 cgroup 文件，每行形如 hierarchy-ID:controllers:path，取第一个带容器 ID 的路径
+func parseCgroupContainer(data string) containerInfo {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		ids := containerIDPattern.FindAllString(path, -1)
+		if len(ids) == 0 {
+			continue
+		}
+		// 嵌套的 cgroup 中最后一个 ID 为最内层的容器
+		info := containerInfo{ID: ids[len(ids)-1]}
+		if m := podUIDPattern.FindStringSubmatch(path); m != nil {
+			info.PodUID = strings.ReplaceAll(m[1], "_", "-")
+		}
+		return info
+	}
+	return containerInfo{}
+}
+
+// 确定事件所属的容器：优先使用 /proc 读到的信息并按 cgroup ID 记录，读不到时按 cgroup ID 查找
+func eventContainer(info ProcessInfo, cgroupID uint64) containerInfo {
+	container := containerInfo{ID: info.ContainerID, PodUID: info.PodUID}
+	if cgroupID == 0 {
+		return container
+	}
+
+	cgroupContainersMu.Lock()
+	defer cgroupContainersMu.Unlock()
+	if container.ID != "" {
+		if len(cgroupContainers) >= maxCgroupContainers {
+			cgroupContainers = make(map[uint64]containerInfo)
+		}
+		cgroupContainers[cgroupID] = container
+		return container
+	}
+	return cgroupContainers[cgroupID]
+}
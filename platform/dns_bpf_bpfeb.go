@@ -53,15 +53,31 @@ type dns_bpfSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type dns_bpfProgramSpecs struct {
-	TraceTcpSendmsg *ebpf.ProgramSpec `ebpf:"trace_tcp_sendmsg"`
-	TraceUdpSendmsg *ebpf.ProgramSpec `ebpf:"trace_udp_sendmsg"`
+	TraceIp4DatagramConnect *ebpf.ProgramSpec `ebpf:"trace_ip4_datagram_connect"`
+	TraceTcpRecvmsg         *ebpf.ProgramSpec `ebpf:"trace_tcp_recvmsg"`
+	TraceTcpRecvmsgRet      *ebpf.ProgramSpec `ebpf:"trace_tcp_recvmsg_ret"`
+	TraceTcpSendmsg         *ebpf.ProgramSpec `ebpf:"trace_tcp_sendmsg"`
+	TraceTcpV4Connect       *ebpf.ProgramSpec `ebpf:"trace_tcp_v4_connect"`
+	TraceUdpRecvmsg         *ebpf.ProgramSpec `ebpf:"trace_udp_recvmsg"`
+	TraceUdpRecvmsgRet      *ebpf.ProgramSpec `ebpf:"trace_udp_recvmsg_ret"`
+	TraceUdpSendmsg         *ebpf.ProgramSpec `ebpf:"trace_udp_sendmsg"`
+	TraceUdpv6Recvmsg       *ebpf.ProgramSpec `ebpf:"trace_udpv6_recvmsg"`
+	TraceUdpv6RecvmsgRet    *ebpf.ProgramSpec `ebpf:"trace_udpv6_recvmsg_ret"`
+	TraceUdpv6Sendmsg       *ebpf.ProgramSpec `ebpf:"trace_udpv6_sendmsg"`
 }
 
 // dns_bpfMapSpecs contains maps before they are loaded into the kernel.
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type dns_bpfMapSpecs struct {
-	Events *ebpf.MapSpec `ebpf:"events"`
+	Connects      *ebpf.MapSpec `ebpf:"connects"`
+	DnsPorts      *ebpf.MapSpec `ebpf:"dns_ports"`
+	DomainFilter  *ebpf.MapSpec `ebpf:"domain_filter"`
+	Events        *ebpf.MapSpec `ebpf:"events"`
+	FilterConfig  *ebpf.MapSpec `ebpf:"filter_config"`
+	IfindexFilter *ebpf.MapSpec `ebpf:"ifindex_filter"`
+	KernelStats   *ebpf.MapSpec `ebpf:"kernel_stats"`
+	RecvArgs      *ebpf.MapSpec `ebpf:"recv_args"`
 }
 
 // dns_bpfObjects contains all objects after they have been loaded into the kernel.
@@ -83,12 +99,26 @@ func (o *dns_bpfObjects) Close() error {
 //
 // It can be passed to loadDns_bpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type dns_bpfMaps struct {
-	Events *ebpf.Map `ebpf:"events"`
+	Connects      *ebpf.Map `ebpf:"connects"`
+	DnsPorts      *ebpf.Map `ebpf:"dns_ports"`
+	DomainFilter  *ebpf.Map `ebpf:"domain_filter"`
+	Events        *ebpf.Map `ebpf:"events"`
+	FilterConfig  *ebpf.Map `ebpf:"filter_config"`
+	IfindexFilter *ebpf.Map `ebpf:"ifindex_filter"`
+	KernelStats   *ebpf.Map `ebpf:"kernel_stats"`
+	RecvArgs      *ebpf.Map `ebpf:"recv_args"`
 }
 
 func (m *dns_bpfMaps) Close() error {
 	return _Dns_bpfClose(
+		m.Connects,
+		m.DnsPorts,
+		m.DomainFilter,
 		m.Events,
+		m.FilterConfig,
+		m.IfindexFilter,
+		m.KernelStats,
+		m.RecvArgs,
 	)
 }
 
@@ -96,14 +126,32 @@ func (m *dns_bpfMaps) Close() error {
 //
 // It can be passed to loadDns_bpfObjects or ebpf.CollectionSpec.LoadAndAssign.
 type dns_bpfPrograms struct {
-	TraceTcpSendmsg *ebpf.Program `ebpf:"trace_tcp_sendmsg"`
-	TraceUdpSendmsg *ebpf.Program `ebpf:"trace_udp_sendmsg"`
+	TraceIp4DatagramConnect *ebpf.Program `ebpf:"trace_ip4_datagram_connect"`
+	TraceTcpRecvmsg         *ebpf.Program `ebpf:"trace_tcp_recvmsg"`
+	TraceTcpRecvmsgRet      *ebpf.Program `ebpf:"trace_tcp_recvmsg_ret"`
+	TraceTcpSendmsg         *ebpf.Program `ebpf:"trace_tcp_sendmsg"`
+	TraceTcpV4Connect       *ebpf.Program `ebpf:"trace_tcp_v4_connect"`
+	TraceUdpRecvmsg         *ebpf.Program `ebpf:"trace_udp_recvmsg"`
+	TraceUdpRecvmsgRet      *ebpf.Program `ebpf:"trace_udp_recvmsg_ret"`
+	TraceUdpSendmsg         *ebpf.Program `ebpf:"trace_udp_sendmsg"`
+	TraceUdpv6Recvmsg       *ebpf.Program `ebpf:"trace_udpv6_recvmsg"`
+	TraceUdpv6RecvmsgRet    *ebpf.Program `ebpf:"trace_udpv6_recvmsg_ret"`
+	TraceUdpv6Sendmsg       *ebpf.Program `ebpf:"trace_udpv6_sendmsg"`
 }
 
 func (p *dns_bpfPrograms) Close() error {
 	return _Dns_bpfClose(
+		p.TraceIp4DatagramConnect,
+		p.TraceTcpRecvmsg,
+		p.TraceTcpRecvmsgRet,
 		p.TraceTcpSendmsg,
+		p.TraceTcpV4Connect,
+		p.TraceUdpRecvmsg,
+		p.TraceUdpRecvmsgRet,
 		p.TraceUdpSendmsg,
+		p.TraceUdpv6Recvmsg,
+		p.TraceUdpv6RecvmsgRet,
+		p.TraceUdpv6Sendmsg,
 	)
 }
 
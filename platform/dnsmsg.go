@@ -0,0 +1,741 @@
+package platform
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// DNS 报文解析错误
+var (
+	errShortRecord = errors.New("记录数据长度不足")
+	errPointerLoop = errors.New("域名压缩指针过多")
+)
+
+// 应答解码支持的记录类型
+const (
+	typeA     = 1
+	typeNS    = 2
+	typeCNAME = 5
+	typeSOA   = 6
+	typePTR   = 12
+	typeMX    = 15
+	typeTXT   = 16
+	typeAAAA  = 28
+	typeOPT   = 41
+	typeSVCB  = 64
+	typeHTTPS = 65
+)
+
+// DNS 响应码（RFC 1035、RFC 2136）
+var rcodeNames = map[uint16]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+// 响应码名称，未知响应码显示为 RCODE<n>
+func rcodeName(rcode uint16) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// 从响应报文头部的标志字段读取响应码（低 4 位），非响应报文返回空字符串
+func responseRcode(msg []byte) string {
+	if len(msg) < 4 {
+		return ""
+	}
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	if flags&0x8000 == 0 {
+		return ""
+	}
+	return rcodeName(flags & 0x000F)
+}
+
+// 单个域名最多跟随的压缩指针数量，防止构造的报文形成环
+const maxNamePointers = 16
+
+// 域名的最大线路格式长度（RFC 1035），限制指针拼接出的超长域名
+const maxNameLength = 255
+
+// SVCB/HTTPS 记录的 SvcParamKey（RFC 9460）
+const (
+	svcParamMandatory     = 0
+	svcParamALPN          = 1
+	svcParamNoDefaultALPN = 2
+	svcParamPort          = 3
+	svcParamIPv4Hint      = 4
+	svcParamECH           = 5
+	svcParamIPv6Hint      = 6
+)
+
+// SvcParamKey 的展示名称，未知的键显示为 key<n>（RFC 9460 2.1）
+var svcParamNames = map[uint16]string{
+	svcParamMandatory:     "mandatory",
+	svcParamALPN:          "alpn",
+	svcParamNoDefaultALPN: "no-default-alpn",
+	svcParamPort:          "port",
+	svcParamIPv4Hint:      "ipv4hint",
+	svcParamECH:           "ech",
+	svcParamIPv6Hint:      "ipv6hint",
+}
+
+func svcParamName(key uint16) string {
+	if name, ok := svcParamNames[key]; ok {
+		return name
+	}
+	return fmt.Sprintf("key%d", key)
+}
+
+// 格式化为接近展示格式的简短描述，如 "1 . alpn=h3,h2 ipv4hint=192.0.2.1 ech"
+func formatSVCB(s *common.SVCBData) string {
+	parts := []string{fmt.Sprintf("%d", s.Priority), s.Target}
+	if len(s.Mandatory) > 0 {
+		parts = append(parts, "mandatory="+strings.Join(s.Mandatory, ","))
+	}
+	if len(s.ALPN) > 0 {
+		parts = append(parts, "alpn="+strings.Join(s.ALPN, ","))
+	}
+	if s.NoDefaultALPN {
+		parts = append(parts, "no-default-alpn")
+	}
+	if s.Port != 0 {
+		parts = append(parts, fmt.Sprintf("port=%d", s.Port))
+	}
+	if len(s.IPv4Hint) > 0 {
+		parts = append(parts, "ipv4hint="+strings.Join(s.IPv4Hint, ","))
+	}
+	if s.ECH {
+		parts = append(parts, "ech")
+	}
+	if len(s.IPv6Hint) > 0 {
+		parts = append(parts, "ipv6hint="+strings.Join(s.IPv6Hint, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// 地址提示为若干个定长地址
+func parseAddressHint(value []byte, size int) ([]string, error) {
+	if len(value) == 0 || len(value)%size != 0 {
+		return nil, errShortRecord
+	}
+	addrs := make([]string, 0, len(value)/size)
+	for i := 0; i < len(value); i += size {
+		addrs = append(addrs, net.IP(value[i:i+size]).String())
+	}
+	return addrs, nil
+}
+
+// 解析 SVCB(64)/HTTPS(65) 记录的 RDATA
+func parseSVCB(rdata []byte) (*common.SVCBData, error) {
+	if len(rdata) < 3 {
+		return nil, errShortRecord
+	}
+
+	info := &common.SVCBData{Priority: binary.BigEndian.Uint16(rdata)}
+
+	// TargetName 不允许使用压缩指针
+	target, offset, err := readUncompressedName(rdata, 2)
+	if err != nil {
+		return nil, err
+	}
+	info.Target = target
+
+	// 逐个读取 SvcParam：key(2) + length(2) + value
+	for offset < len(rdata) {
+		if offset+4 > len(rdata) {
+			return nil, errShortRecord
+		}
+		key := binary.BigEndian.Uint16(rdata[offset:])
+		length := int(binary.BigEndian.Uint16(rdata[offset+2:]))
+		offset += 4
+		if offset+length > len(rdata) {
+			return nil, errShortRecord
+		}
+		value := rdata[offset : offset+length]
+		offset += length
+
+		switch key {
+		case svcParamMandatory:
+			if len(value) == 0 || len(value)%2 != 0 {
+				return nil, errShortRecord
+			}
+			for i := 0; i < len(value); i += 2 {
+				info.Mandatory = append(info.Mandatory, svcParamName(binary.BigEndian.Uint16(value[i:])))
+			}
+		case svcParamALPN:
+			// ALPN 为若干个长度前缀的协议标识
+			for i := 0; i < len(value); {
+				n := int(value[i])
+				if i+1+n > len(value) {
+					return nil, errShortRecord
+				}
+				info.ALPN = append(info.ALPN, string(value[i+1:i+1+n]))
+				i += 1 + n
+			}
+		case svcParamNoDefaultALPN:
+			if len(value) != 0 {
+				return nil, errShortRecord
+			}
+			info.NoDefaultALPN = true
+		case svcParamPort:
+			if len(value) != 2 {
+				return nil, errShortRecord
+			}
+			info.Port = binary.BigEndian.Uint16(value)
+		case svcParamIPv4Hint:
+			if info.IPv4Hint, err = parseAddressHint(value, net.IPv4len); err != nil {
+				return nil, err
+			}
+		case svcParamECH:
+			info.ECH = len(value) > 0
+		case svcParamIPv6Hint:
+			if info.IPv6Hint, err = parseAddressHint(value, net.IPv6len); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// 读取未压缩的域名，返回域名和其后的偏移
+func readUncompressedName(data []byte, offset int) (string, int, error) {
+	var labels []string
+	for {
+		if offset >= len(data) {
+			return "", 0, errShortRecord
+		}
+		length := int(data[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length > 63 || offset+length > len(data) {
+			return "", 0, fmt.Errorf("无效的标签长度 %d", length)
+		}
+		labels = append(labels, string(data[offset:offset+length]))
+		offset += length
+	}
+
+	if len(labels) == 0 {
+		return ".", offset, nil
+	}
+	return strings.Join(labels, "."), offset, nil
+}
+
+// 读取可能含压缩指针的域名，返回域名和其在原位置之后的偏移
+func readName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+	nameLength := 1 // 结尾的零长度标签
+	for pointers := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errShortRecord
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			if len(labels) == 0 {
+				return ".", end, nil
+			}
+			return strings.Join(labels, "."), end, nil
+		case length&0xC0 == 0xC0:
+			if offset+2 > len(msg) {
+				return "", 0, errShortRecord
+			}
+			if pointers++; pointers > maxNamePointers {
+				return "", 0, errPointerLoop
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+		case length > 63:
+			return "", 0, fmt.Errorf("无效的标签长度 %d", length)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, errShortRecord
+			}
+			if nameLength += 1 + length; nameLength > maxNameLength {
+				return "", 0, fmt.Errorf("域名超过 %d 字节", maxNameLength)
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// 解析 MX 记录：preference(2) + exchange
+func parseMX(msg []byte, offset, length int) (*common.MXData, error) {
+	if length < 3 || offset+length > len(msg) {
+		return nil, errShortRecord
+	}
+	exchange, _, err := readName(msg, offset+2)
+	if err != nil {
+		return nil, err
+	}
+	return &common.MXData{
+		Preference: binary.BigEndian.Uint16(msg[offset:]),
+		Exchange:   exchange,
+	}, nil
+}
+
+// 解析 SOA 记录：mname + rname + 5 个 32 位计数
+func parseSOA(msg []byte, offset, length int) (*common.SOAData, error) {
+	end := offset + length
+	if end > len(msg) {
+		return nil, errShortRecord
+	}
+	mname, next, err := readName(msg, offset)
+	if err != nil {
+		return nil, err
+	}
+	rname, next, err := readName(msg, next)
+	if err != nil {
+		return nil, err
+	}
+	if next+20 > end {
+		return nil, errShortRecord
+	}
+	return &common.SOAData{
+		MName:   mname,
+		RName:   rname,
+		Serial:  binary.BigEndian.Uint32(msg[next:]),
+		Refresh: binary.BigEndian.Uint32(msg[next+4:]),
+		Retry:   binary.BigEndian.Uint32(msg[next+8:]),
+		Expire:  binary.BigEndian.Uint32(msg[next+12:]),
+		Minimum: binary.BigEndian.Uint32(msg[next+16:]),
+	}, nil
+}
+
+// 解析 TXT 记录：一个或多个长度前缀的字符串
+func parseTXT(rdata []byte) ([]string, error) {
+	var strs []string
+	for offset := 0; offset < len(rdata); {
+		n := int(rdata[offset])
+		offset++
+		if offset+n > len(rdata) {
+			return nil, errShortRecord
+		}
+		strs = append(strs, string(rdata[offset:offset+n]))
+		offset += n
+	}
+	return strs, nil
+}
+
+// 常见 TXT 记录的前缀及用途
+var txtPrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"v=spf1", "spf"},
+	{"v=DKIM1", "dkim"},
+	{"v=DMARC1", "dmarc"},
+	{"v=STSv1", "mta-sts"},
+	{"v=TLSRPTv1", "tls-rpt"},
+	{"v=BIMI1", "bimi"},
+	{"google-site-verification=", "verification"},
+	{"MS=", "verification"},
+	{"facebook-domain-verification=", "verification"},
+	{"apple-domain-verification=", "verification"},
+	{"atlassian-domain-verification=", "verification"},
+}
+
+// 按前缀识别 TXT 记录的用途，多个字符串按 RFC 7208 拼接后识别，未识别时返回空字符串
+func txtKind(text string) string {
+	for _, p := range txtPrefixes {
+		if len(text) >= len(p.prefix) && strings.EqualFold(text[:len(p.prefix)], p.prefix) {
+			return p.kind
+		}
+	}
+	return ""
+}
+
+// 按类型解码 RDATA
+func decodeRData(answer *common.Answer, rrtype uint16, msg []byte, offset, length int) error {
+	rdata := msg[offset : offset+length]
+	switch rrtype {
+	case typeA, typeAAAA:
+		if (rrtype == typeA && length != net.IPv4len) || (rrtype == typeAAAA && length != net.IPv6len) {
+			return errShortRecord
+		}
+		answer.Data = net.IP(rdata).String()
+	case typeCNAME, typeNS, typePTR:
+		name, _, err := readName(msg, offset)
+		if err != nil {
+			return err
+		}
+		answer.Data = name
+	case typeMX:
+		mx, err := parseMX(msg, offset, length)
+		if err != nil {
+			return err
+		}
+		answer.MX = mx
+		answer.Data = fmt.Sprintf("%d %s", mx.Preference, mx.Exchange)
+	case typeSOA:
+		soa, err := parseSOA(msg, offset, length)
+		if err != nil {
+			return err
+		}
+		answer.SOA = soa
+		answer.Data = fmt.Sprintf("%s %s %d", soa.MName, soa.RName, soa.Serial)
+	case typeTXT:
+		txt, err := parseTXT(rdata)
+		if err != nil {
+			return err
+		}
+		answer.TXT = txt
+		answer.Data = strings.Join(txt, "")
+		answer.TXTKind = txtKind(answer.Data)
+	case typeSVCB, typeHTTPS:
+		svcb, err := parseSVCB(rdata)
+		if err != nil {
+			return err
+		}
+		answer.SVCB = svcb
+		answer.Data = formatSVCB(svcb)
+	}
+	return nil
+}
+
+// 解析响应报文的应答部分，遇到无法解析的记录时返回已解析的部分
+func parseAnswers(msg []byte) ([]common.Answer, error) {
+	if len(msg) < 12 {
+		return nil, errShortRecord
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	offset, err := skipQuestions(msg, 12, qdcount)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]common.Answer, 0, ancount)
+	for i := 0; i < ancount; i++ {
+		name, next, err := readName(msg, offset)
+		if err != nil {
+			return answers, err
+		}
+		// type(2) + class(2) + ttl(4) + rdlength(2)
+		if next+10 > len(msg) {
+			return answers, errShortRecord
+		}
+		rrtype := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		offset = next + 10
+		if offset+length > len(msg) {
+			return answers, errShortRecord
+		}
+
+		answer := common.Answer{
+			Name: name,
+			Type: fmt.Sprintf("TYPE%d", rrtype),
+			TTL:  binary.BigEndian.Uint32(msg[next+4:]),
+		}
+		if t, ok := qtypeNames[rrtype]; ok {
+			answer.Type = t
+		}
+		if err := decodeRData(&answer, rrtype, msg, offset, length); err != nil {
+			return answers, err
+		}
+		answers = append(answers, answer)
+		offset += length
+	}
+	return answers, nil
+}
+
+// 跳过问题部分，返回其后的偏移
+func skipQuestions(msg []byte, offset, count int) (int, error) {
+	for i := 0; i < count; i++ {
+		_, next, err := readName(msg, offset)
+		if err != nil {
+			return 0, err
+		}
+		// type(2) + class(2)
+		if next+4 > len(msg) {
+			return 0, errShortRecord
+		}
+		offset = next + 4
+	}
+	return offset, nil
+}
+
+// 跳过若干条资源记录，返回其后的偏移
+func skipRecords(msg []byte, offset, count int) (int, error) {
+	for i := 0; i < count; i++ {
+		_, next, err := readName(msg, offset)
+		if err != nil {
+			return 0, err
+		}
+		if next+10 > len(msg) {
+			return 0, errShortRecord
+		}
+		offset = next + 10 + int(binary.BigEndian.Uint16(msg[next+8:]))
+		if offset > len(msg) {
+			return 0, errShortRecord
+		}
+	}
+	return offset, nil
+}
+
+// EDNS 选项代码
+const ednsOptionClientSubnet = 8
+
+// ECS 地址族
+const (
+	ecsFamilyIPv4 = 1
+	ecsFamilyIPv6 = 2
+)
+
+// 查找附加部分的 OPT 记录，返回其 RDATA，没有 OPT 记录时返回 nil
+func findOPT(msg []byte) ([]byte, error) {
+	if len(msg) < 12 {
+		return nil, errShortRecord
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+	nscount := int(binary.BigEndian.Uint16(msg[8:]))
+	arcount := int(binary.BigEndian.Uint16(msg[10:]))
+
+	offset, err := skipQuestions(msg, 12, qdcount)
+	if err != nil {
+		return nil, err
+	}
+	if offset, err = skipRecords(msg, offset, ancount+nscount); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < arcount; i++ {
+		_, next, err := readName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errShortRecord
+		}
+		rrtype := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		if start+length > len(msg) {
+			return nil, errShortRecord
+		}
+		if rrtype == typeOPT {
+			return msg[start : start+length], nil
+		}
+		offset = start + length
+	}
+	return nil, nil
+}
+
+// 解析 EDNS Client Subnet 选项（RFC 7871），返回如 203.0.113.0/24 的客户端子网，
+// 没有该选项时返回空字符串
+func parseECS(msg []byte) (string, error) {
+	opt, err := findOPT(msg)
+	if err != nil || opt == nil {
+		return "", err
+	}
+
+	// 选项：code(2) + length(2) + data
+	for offset := 0; offset+4 <= len(opt); {
+		code := binary.BigEndian.Uint16(opt[offset:])
+		length := int(binary.BigEndian.Uint16(opt[offset+2:]))
+		offset += 4
+		if offset+length > len(opt) {
+			return "", errShortRecord
+		}
+		data := opt[offset : offset+length]
+		offset += length
+
+		if code != ednsOptionClientSubnet {
+			continue
+		}
+
+		// family(2) + source prefix(1) + scope prefix(1) + address
+		if len(data) < 4 {
+			return "", errShortRecord
+		}
+		family := binary.BigEndian.Uint16(data)
+		prefix := int(data[2])
+		addr := data[4:]
+
+		var size int
+		switch family {
+		case ecsFamilyIPv4:
+			size = net.IPv4len
+		case ecsFamilyIPv6:
+			size = net.IPv6len
+		default:
+			return "", fmt.Errorf("未知的 ECS 地址族 %d", family)
+		}
+		// 地址只携带前缀覆盖的字节
+		if prefix > size*8 || len(addr) != (prefix+7)/8 {
+			return "", fmt.Errorf("ECS 前缀长度 %d 与地址长度 %d 不符", prefix, len(addr))
+		}
+
+		ip := make(net.IP, size)
+		copy(ip, addr)
+		return fmt.Sprintf("%s/%d", ip, prefix), nil
+	}
+	return "", nil
+}
+
+// 网络协议映射
+var protocolMap = map[uint16]string{
+	6:  "TCP",
+	17: "UDP",
+}
+
+// DNSQuestion 问题部分的一条记录
+type DNSQuestion struct {
+	Name string
+	Type uint16
+}
+
+// DNS查询信息
+type DNSInfo struct {
+	// 第一个问题
+	QueryName string
+	QueryType uint16
+	// 问题部分的全部记录（包括第一个），绝大多数报文只有一个问题
+	Questions []DNSQuestion
+	// AD 位，响应中表示解析器已完成 DNSSEC 验证，查询中表示客户端关心验证结果
+	AuthenticatedData bool
+	// EDNS Client Subnet 选项中的客户端子网，通常出现在递归解析器发往上游的查询中
+	ClientSubnet string
+	// 事务 ID，用于关联查询与响应
+	TransactionID uint16
+	// 响应报文（QR=1）的响应码和应答记录
+	Response bool
+	Rcode    string
+	Answers  []common.Answer
+}
+
+// 解析DNS数据包
+func parseDNSPacket(data []byte) *DNSInfo {
+	if len(data) < 12 {
+		return nil
+	}
+
+	flags := binary.BigEndian.Uint16(data[2:4])
+
+	// 按 QDCOUNT 读取问题部分；没有问题的报文（如只携带 EDNS OPT 记录的报文）不能把
+	// 之后的记录名当作查询域名。截断的报文只保留能完整解析的问题
+	questions, _ := parseQuestions(data, int(binary.BigEndian.Uint16(data[4:6])))
+	if len(questions) == 0 {
+		return nil
+	}
+
+	// ECS 解析失败不影响查询本身
+	subnet, _ := parseECS(data)
+
+	info := &DNSInfo{
+		QueryName:         questions[0].Name,
+		QueryType:         questions[0].Type,
+		Questions:         questions,
+		AuthenticatedData: flags&0x0020 != 0,
+		ClientSubnet:      subnet,
+		TransactionID:     binary.BigEndian.Uint16(data[0:2]),
+	}
+	if flags&0x8000 != 0 {
+		// 截断的响应只保留能解析的应答记录
+		info.Response = true
+		info.Rcode = responseRcode(data)
+		info.Answers, _ = parseAnswers(data)
+	}
+	return info
+}
+
+// 解析问题部分的 count 个问题，根域名（如根服务器的 NS 查询）不作为查询输出。
+// 问题部分一般不使用压缩，但不规范的客户端和模糊测试工具可能在此放置指针，
+// 与应答部分使用同样带循环保护的解析，避免静默丢弃这些查询
+func parseQuestions(msg []byte, count int) ([]DNSQuestion, error) {
+	var questions []DNSQuestion
+	offset := 12
+	for i := 0; i < count; i++ {
+		name, next, err := readName(msg, offset)
+		if err != nil {
+			return questions, err
+		}
+		// 截断的报文可能缺少 class，只要求能读取 type
+		if next+2 > len(msg) {
+			return questions, errShortRecord
+		}
+		if name != "." {
+			questions = append(questions, DNSQuestion{Name: name, Type: binary.BigEndian.Uint16(msg[next:])})
+		}
+		offset = next + 4
+	}
+	return questions, nil
+}
+
+// 未被域名黑白名单过滤的问题
+func unfilteredQuestions(questions []DNSQuestion) []DNSQuestion {
+	var kept []DNSQuestion
+	for _, q := range questions {
+		if !isDomainFiltered(q.Name) {
+			kept = append(kept, q)
+		}
+	}
+	return kept
+}
+
+// 每个问题输出一条记录，其他字段相同；包含多个问题的报文很少见（多数解析器直接拒绝），
+// 附加说明便于排查，应答记录各自复制一份以免后续处理环节相互影响
+func emitQuestions(event DNSEvent, total int, questions []DNSQuestion) {
+	for i, q := range questions {
+		record := event
+		record.QueryName = q.Name
+		record.QueryType = fmt.Sprintf("TYPE%d", q.Type)
+		if name, ok := qtypeNames[q.Type]; ok {
+			record.QueryType = name
+		}
+		if total > 1 {
+			record.Notes = append(slices.Clone(event.Notes), fmt.Sprintf("同一报文包含 %d 个问题", total))
+		}
+		if i > 0 {
+			record.Answers = slices.Clone(event.Answers)
+		}
+		emit(record)
+	}
+}
+
+// 去掉 DNS over TCP 报文的 2 字节长度前缀（RFC 1035 4.2.2），返回报文和去掉前缀后的原始长度。
+// 前缀与报文分段发送时 eBPF 程序已跳过前缀，此时前缀字段与长度不符，原样返回
+func stripTCPLength(data []byte, size int) ([]byte, int) {
+	if len(data) < 2 || int(binary.BigEndian.Uint16(data)) != size-2 {
+		return data, size
+	}
+	return data[2:], size - 2
+}
+
+// 应答中 A/AAAA 记录的地址，以逗号分隔，与 Windows 的查询结果格式一致
+func answerAddresses(answers []common.Answer) string {
+	var addrs []string
+	for _, answer := range answers {
+		if answer.Type == "A" || answer.Type == "AAAA" {
+			addrs = append(addrs, answer.Data)
+		}
+	}
+	return strings.Join(addrs, ", ")
+}
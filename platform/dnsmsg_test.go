@@ -0,0 +1,353 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"dnsflux/common"
+)
+
+// 域名的线路格式，不使用压缩
+func wireName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// 12 字节的报文头部
+func dnsHeader(id, flags uint16, qdcount, ancount, nscount, arcount int) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(qdcount))
+	binary.BigEndian.PutUint16(b[6:], uint16(ancount))
+	binary.BigEndian.PutUint16(b[8:], uint16(nscount))
+	binary.BigEndian.PutUint16(b[10:], uint16(arcount))
+	return b
+}
+
+// 问题部分的一条记录，class 为 IN
+func dnsQuestion(name []byte, qtype uint16) []byte {
+	return append(bytes.Clone(name), byte(qtype>>8), byte(qtype), 0, 1)
+}
+
+func TestReadName(t *testing.T) {
+	// 63 字节的标签，4 个拼成 257 字节的域名
+	long := strings.Repeat("a", 63)
+
+	tests := []struct {
+		name     string
+		msg      []byte
+		offset   int
+		want     string
+		wantNext int
+		wantErr  error // 非 nil 时用 errors.Is 比较
+		fail     bool
+	}{
+		{
+			name:     "uncompressed",
+			msg:      wireName("www.example.com"),
+			want:     "www.example.com",
+			wantNext: 17,
+		},
+		{
+			name:     "root",
+			msg:      []byte{0},
+			want:     ".",
+			wantNext: 1,
+		},
+		{
+			// 第二个域名为 "www" 加指向偏移 0 的指针，返回的偏移在指针之后
+			name:     "compressed",
+			msg:      append(wireName("example.com"), 3, 'w', 'w', 'w', 0xC0, 0x00),
+			offset:   13,
+			want:     "www.example.com",
+			wantNext: 19,
+		},
+		{
+			name:    "pointer loop",
+			msg:     []byte{0xC0, 0x00},
+			wantErr: errPointerLoop,
+		},
+		{
+			name:    "pointer chain loop",
+			msg:     []byte{1, 'a', 0xC0, 0x04, 1, 'b', 0xC0, 0x00},
+			wantErr: errPointerLoop,
+		},
+		{
+			name: "longer than 255 bytes",
+			msg:  wireName(strings.Join([]string{long, long, long, long}, ".")),
+			fail: true,
+		},
+		{
+			// 3 个 63 字节标签加 1 个 61 字节标签，连同长度字节和结尾的零正好 255 字节
+			name:     "exactly 255 bytes",
+			msg:      wireName(strings.Join([]string{long, long, long, long[:61]}, ".")),
+			want:     strings.Join([]string{long, long, long, long[:61]}, "."),
+			wantNext: 255,
+		},
+		{
+			name:    "truncated label",
+			msg:     []byte{7, 'e', 'x', 'a'},
+			wantErr: errShortRecord,
+		},
+		{
+			name:    "truncated pointer",
+			msg:     []byte{3, 'w', 'w', 'w', 0xC0},
+			wantErr: errShortRecord,
+		},
+		{
+			name: "label longer than 63",
+			msg:  append([]byte{64}, make([]byte, 65)...),
+			fail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := readName(tt.msg, tt.offset)
+			if tt.wantErr != nil || tt.fail {
+				if err == nil {
+					t.Fatalf("readName() = %q, %d, want error", got, next)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readName() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readName() error = %v", err)
+			}
+			if got != tt.want || next != tt.wantNext {
+				t.Errorf("readName() = %q, %d, want %q, %d", got, next, tt.want, tt.wantNext)
+			}
+		})
+	}
+}
+
+// 问题部分含压缩指针的报文按指针解析出查询域名，指针形成环时丢弃报文
+func TestParseDNSPacketQuestionPointer(t *testing.T) {
+	// 第一个问题的域名为 "www" 加指向附加数据中 "example.com" 的前向指针
+	msg := dnsHeader(0x1234, 0x0100, 1, 0, 0, 0)
+	target := len(msg) + 4 + 2 + 4
+	msg = append(msg, 3, 'w', 'w', 'w', 0xC0, byte(target))
+	msg = append(msg, 0, 1, 0, 1)
+	msg = append(msg, wireName("example.com")...)
+
+	info := parseDNSPacket(msg)
+	if info == nil {
+		t.Fatal("parseDNSPacket() = nil")
+	}
+	if info.QueryName != "www.example.com" || info.QueryType != typeA {
+		t.Errorf("question = %q type %d, want %q type %d", info.QueryName, info.QueryType, "www.example.com", typeA)
+	}
+	if info.TransactionID != 0x1234 || info.Response {
+		t.Errorf("TransactionID = %#x, Response = %v", info.TransactionID, info.Response)
+	}
+
+	loop := dnsHeader(0x1234, 0x0100, 1, 0, 0, 0)
+	loop = append(loop, 0xC0, 12, 0, 1, 0, 1)
+	if info := parseDNSPacket(loop); info != nil {
+		t.Errorf("parseDNSPacket() with a pointer loop = %+v, want nil", info)
+	}
+}
+
+// 附加部分的 OPT 记录，携带给定的 EDNS 选项
+func optRecord(options ...[]byte) []byte {
+	var rdata []byte
+	for _, o := range options {
+		rdata = append(rdata, o...)
+	}
+	// 根域名 + type OPT + UDP 载荷大小 4096 + 扩展 RCODE 和标志 + rdlength
+	b := []byte{0, 0, typeOPT, 0x10, 0x00, 0, 0, 0, 0}
+	b = append(b, byte(len(rdata)>>8), byte(len(rdata)))
+	return append(b, rdata...)
+}
+
+// ECS 选项：family(2) + source prefix(1) + scope prefix(1) + address
+func ecsOption(family uint16, prefix int, addr ...byte) []byte {
+	data := []byte{byte(family >> 8), byte(family), byte(prefix), 0}
+	data = append(data, addr...)
+	b := []byte{0, ednsOptionClientSubnet, byte(len(data) >> 8), byte(len(data))}
+	return append(b, data...)
+}
+
+func TestParseDNSPacketMultipleQuestions(t *testing.T) {
+	msg := dnsHeader(7, 0x0100, 2, 0, 0, 0)
+	msg = append(msg, dnsQuestion(wireName("example.com"), typeA)...)
+	msg = append(msg, dnsQuestion(wireName("example.org"), typeAAAA)...)
+
+	info := parseDNSPacket(msg)
+	if info == nil {
+		t.Fatal("parseDNSPacket() = nil")
+	}
+	want := []DNSQuestion{{Name: "example.com", Type: typeA}, {Name: "example.org", Type: typeAAAA}}
+	if len(info.Questions) != len(want) || info.Questions[0] != want[0] || info.Questions[1] != want[1] {
+		t.Errorf("Questions = %+v, want %+v", info.Questions, want)
+	}
+	if info.QueryName != "example.com" || info.QueryType != typeA {
+		t.Errorf("first question = %q type %d, want example.com type %d", info.QueryName, info.QueryType, typeA)
+	}
+
+	// 截断在第二个问题中间时只保留第一个问题
+	truncated := msg[:len(msg)-6]
+	info = parseDNSPacket(truncated)
+	if info == nil || len(info.Questions) != 1 || info.QueryName != "example.com" {
+		t.Errorf("parseDNSPacket(truncated) = %+v, want only example.com", info)
+	}
+}
+
+func TestParseECS(t *testing.T) {
+	query := func(options ...[]byte) []byte {
+		msg := dnsHeader(1, 0x0100, 1, 0, 0, 1)
+		msg = append(msg, dnsQuestion(wireName("example.com"), typeA)...)
+		return append(msg, optRecord(options...)...)
+	}
+	// 其他 EDNS 选项（cookie）位于 ECS 之前
+	cookie := []byte{0, 10, 0, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "ipv4", msg: query(ecsOption(ecsFamilyIPv4, 24, 203, 0, 113)), want: "203.0.113.0/24"},
+		{name: "ipv6", msg: query(cookie, ecsOption(ecsFamilyIPv6, 56, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0x12)), want: "2001:db8:0:1200::/56"},
+		{name: "zero prefix", msg: query(ecsOption(ecsFamilyIPv4, 0)), want: "0.0.0.0/0"},
+		{name: "opt without ecs", msg: query(cookie)},
+		{name: "no opt", msg: append(dnsHeader(1, 0x0100, 1, 0, 0, 0), dnsQuestion(wireName("example.com"), typeA)...)},
+		{name: "prefix longer than address", msg: query(ecsOption(ecsFamilyIPv4, 24, 203, 0)), wantErr: true},
+		{name: "unknown family", msg: query(ecsOption(3, 8, 1)), wantErr: true},
+		{name: "truncated option", msg: query([]byte{0, ednsOptionClientSubnet, 0, 8, 0, 1}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseECS(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseECS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseECS() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// ECS 解析失败不影响查询本身
+	info := parseDNSPacket(tests[0].msg)
+	if info == nil || info.ClientSubnet != "203.0.113.0/24" {
+		t.Errorf("parseDNSPacket().ClientSubnet = %+v, want 203.0.113.0/24", info)
+	}
+	if info := parseDNSPacket(query(ecsOption(3, 8, 1))); info == nil || info.QueryName != "example.com" || info.ClientSubnet != "" {
+		t.Errorf("parseDNSPacket() with a bad ECS option = %+v", info)
+	}
+}
+
+func TestStripTCPLength(t *testing.T) {
+	msg := dnsHeader(0xbeef, 0x0100, 1, 0, 0, 1)
+	msg = append(msg, dnsQuestion(wireName("example.com"), typeA)...)
+	msg = append(msg, optRecord()...)
+	prefixed := append([]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		size     int
+		wantData []byte
+		wantSize int
+	}{
+		// 前缀与报文在同一段发送，如 Go 和 systemd-resolved
+		{name: "prefixed query", data: prefixed, size: len(prefixed), wantData: msg, wantSize: len(msg)},
+		// 报文超出 eBPF 缓冲区被截断时，按原始长度核对前缀
+		{name: "truncated capture", data: prefixed[:20], size: len(prefixed), wantData: prefixed[2:20], wantSize: len(msg)},
+		// 前缀单独发送，eBPF 程序已跳过，报文头部的事务 ID 与长度不符
+		{name: "prefix already skipped", data: msg, size: len(msg), wantData: msg, wantSize: len(msg)},
+		{name: "truncated prefix", data: prefixed[:1], size: 1, wantData: prefixed[:1], wantSize: 1},
+		{name: "empty", data: nil, size: 0, wantData: nil, wantSize: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, size := stripTCPLength(tt.data, tt.size)
+			if !bytes.Equal(data, tt.wantData) || size != tt.wantSize {
+				t.Errorf("stripTCPLength() = %x, %d, want %x, %d", data, size, tt.wantData, tt.wantSize)
+			}
+		})
+	}
+
+	// 去掉前缀后可以正常解析
+	data, _ := stripTCPLength(prefixed, len(prefixed))
+	if info := parseDNSPacket(data); info == nil || info.QueryName != "example.com" || info.TransactionID != 0xbeef {
+		t.Errorf("parseDNSPacket(stripped) = %+v", info)
+	}
+}
+
+// SvcParam：key(2) + length(2) + value
+func svcParam(key uint16, value ...byte) []byte {
+	return append([]byte{byte(key >> 8), byte(key), byte(len(value) >> 8), byte(len(value))}, value...)
+}
+
+func TestParseSVCB(t *testing.T) {
+	rdata := []byte{0, 1, 0} // priority 1，目标为 "."
+	rdata = append(rdata, svcParam(svcParamMandatory, 0, svcParamALPN, 0, svcParamPort)...)
+	rdata = append(rdata, svcParam(svcParamALPN, 2, 'h', '3', 2, 'h', '2')...)
+	rdata = append(rdata, svcParam(svcParamNoDefaultALPN)...)
+	rdata = append(rdata, svcParam(svcParamPort, 0x01, 0xbb)...)
+	rdata = append(rdata, svcParam(svcParamIPv4Hint, 192, 0, 2, 1, 192, 0, 2, 2)...)
+	rdata = append(rdata, svcParam(svcParamECH, 0xfe, 0x0d)...)
+	rdata = append(rdata, svcParam(svcParamIPv6Hint, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1)...)
+	rdata = append(rdata, svcParam(65000, 'x')...)
+
+	got, err := parseSVCB(rdata)
+	if err != nil {
+		t.Fatalf("parseSVCB() error = %v", err)
+	}
+	want := &common.SVCBData{
+		Priority:      1,
+		Target:        ".",
+		Mandatory:     []string{"alpn", "port"},
+		ALPN:          []string{"h3", "h2"},
+		NoDefaultALPN: true,
+		Port:          443,
+		IPv4Hint:      []string{"192.0.2.1", "192.0.2.2"},
+		ECH:           true,
+		IPv6Hint:      []string{"2001:db8::1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSVCB() = %+v, want %+v", got, want)
+	}
+	wantText := "1 . mandatory=alpn,port alpn=h3,h2 no-default-alpn port=443 ipv4hint=192.0.2.1,192.0.2.2 ech ipv6hint=2001:db8::1"
+	if text := formatSVCB(got); text != wantText {
+		t.Errorf("formatSVCB() = %q, want %q", text, wantText)
+	}
+
+	// 别名模式：priority 0，只有目标名称
+	alias := append([]byte{0, 0}, wireName("svc.example.net")...)
+	if got, err := parseSVCB(alias); err != nil || got.Priority != 0 || got.Target != "svc.example.net" || got.ALPN != nil {
+		t.Errorf("parseSVCB(alias) = %+v, %v", got, err)
+	}
+
+	for name, param := range map[string][]byte{
+		"ipv4hint length":        svcParam(svcParamIPv4Hint, 192, 0, 2),
+		"empty ipv6hint":         svcParam(svcParamIPv6Hint),
+		"odd mandatory":          svcParam(svcParamMandatory, 0),
+		"no-default-alpn value":  svcParam(svcParamNoDefaultALPN, 1),
+		"truncated alpn":         svcParam(svcParamALPN, 5, 'h'),
+		"param past end of data": svcParam(svcParamPort, 1, 2)[:5],
+	} {
+		if _, err := parseSVCB(append([]byte{0, 1, 0}, param...)); err == nil {
+			t.Errorf("%s: parseSVCB() want error", name)
+		}
+	}
+}
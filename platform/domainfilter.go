@@ -0,0 +1,113 @@
+package platform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// 域名黑白名单中不带前缀的条目的匹配方式
+const (
+	domainMatchSubstring = "substring"
+	domainMatchExact     = "exact"
+)
+
+// 单个域名匹配条件，条目语法：
+//
+//	/^ads?\d+\./   斜杠包围的正则表达式，不区分大小写
+//	*.xyz          含 * 的通配模式，* 匹配任意字符，*.xyz 匹配 xyz 的所有子域名
+//	=example.com   完整域名匹配
+//	example.com    默认按包含匹配，domainMatch 为 exact 时按完整域名匹配
+type domainPattern struct {
+	text    string // 小写且去掉末尾点的条目
+	exact   bool
+	pattern *regexp.Regexp // 正则和通配条目编译后的表达式
+}
+
+// 编译后的域名列表
+type domainList []domainPattern
+
+// 启动时编译的白名单和黑名单
+var (
+	domainAllowlist domainList
+	domainBlacklist domainList
+)
+
+// 编译配置中的域名黑白名单，正则无效时返回错误
+func compileDomainFilters(cfg Config) error {
+	exact := cfg.DomainMatch == domainMatchExact
+	allow, err := compileDomainList(cfg.DomainAllowlist, exact)
+	if err != nil {
+		return fmt.Errorf("%w: 域名白名单: %v", ErrConfig, err)
+	}
+	block, err := compileDomainList(cfg.DomainBlacklist, exact)
+	if err != nil {
+		return fmt.Errorf("%w: 域名黑名单: %v", ErrConfig, err)
+	}
+	domainAllowlist, domainBlacklist = allow, block
+	return nil
+}
+
+// 编译域名列表，exact 决定不带前缀的条目是否按完整域名匹配
+func compileDomainList(entries []string, exact bool) (domainList, error) {
+	list := make(domainList, 0, len(entries))
+	for _, entry := range entries {
+		var p domainPattern
+		switch {
+		case len(entry) >= 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/"):
+			re, err := regexp.Compile("(?i)" + entry[1:len(entry)-1])
+			if err != nil {
+				return nil, fmt.Errorf("无效的正则表达式 %s: %v", entry, err)
+			}
+			p.pattern = re
+		case strings.Contains(entry, "*"):
+			parts := strings.Split(normalizeDomain(entry), "*")
+			for i, part := range parts {
+				parts[i] = regexp.QuoteMeta(part)
+			}
+			p.pattern = regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+		case strings.HasPrefix(entry, "="):
+			p.text, p.exact = normalizeDomain(entry[1:]), true
+		default:
+			p.text, p.exact = normalizeDomain(entry), exact
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// 转为小写的 ASCII 形式（国际化域名编码为 punycode）并去掉末尾的点，
+// 以 Unicode 或 punycode 书写的条目都能匹配查询中的 punycode 域名
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(common.ASCIIDomain(domain), ".")
+}
+
+// 检查域名是否命中列表中的任一条目
+func (l domainList) match(domain string) bool {
+	if len(l) == 0 {
+		return false
+	}
+	domain = normalizeDomain(domain)
+	for _, p := range l {
+		switch {
+		case p.pattern != nil:
+			if p.pattern.MatchString(domain) {
+				return true
+			}
+		case p.exact:
+			if domain == p.text {
+				return true
+			}
+		case strings.Contains(domain, p.text):
+			return true
+		}
+	}
+	return false
+}
+
+// 检查域名是否需要过滤：先判断白名单（已批准的域名），再判断黑名单
+func isDomainFiltered(domain string) bool {
+	return domainAllowlist.match(domain) || domainBlacklist.match(domain)
+}
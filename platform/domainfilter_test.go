@@ -0,0 +1,82 @@
+package platform
+
+import "testing"
+
+func TestDomainListMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		exact   bool
+		domain  string
+		want    bool
+	}{
+		// 默认按包含匹配，同时命中以条目结尾的其他域名
+		{name: "substring", entries: []string{"example.com"}, domain: "www.example.com", want: true},
+		{name: "substring other domain", entries: []string{"example.com"}, domain: "notexample.com", want: true},
+		{name: "exact mode", entries: []string{"example.com"}, exact: true, domain: "example.com", want: true},
+		{name: "exact mode other domain", entries: []string{"example.com"}, exact: true, domain: "notexample.com"},
+		{name: "exact mode subdomain", entries: []string{"example.com"}, exact: true, domain: "www.example.com"},
+		{name: "exact prefix", entries: []string{"=example.com"}, domain: "notexample.com"},
+		{name: "substring false positive", entries: []string{"ad.com"}, domain: "load.com", want: true},
+		{name: "exact prefix avoids false positive", entries: []string{"=ad.com"}, domain: "load.com"},
+		{name: "exact prefix trailing dot", entries: []string{"=Example.COM."}, domain: "example.com.", want: true},
+
+		// *.xyz 只匹配 xyz 的子域名
+		{name: "tld wildcard", entries: []string{"*.xyz"}, domain: "evil.xyz", want: true},
+		{name: "tld wildcard nested", entries: []string{"*.xyz"}, domain: "a.b.XYZ", want: true},
+		{name: "tld wildcard bare tld", entries: []string{"*.xyz"}, domain: "xyz"},
+		{name: "tld wildcard suffix", entries: []string{"*.xyz"}, domain: "notxyz"},
+		{name: "tld wildcard other tld", entries: []string{"*.xyz"}, domain: "xyz.com"},
+		{name: "wildcard dot is literal", entries: []string{"*.example.com"}, domain: "wwwxexample.com"},
+		{name: "wildcard in middle", entries: []string{"ads*.example.com"}, domain: "ads42.cdn.example.com", want: true},
+
+		{name: "regexp", entries: []string{`/^ads?\d+\./`}, domain: "AD7.example.com", want: true},
+		{name: "regexp no match", entries: []string{`/^ads?\d+\./`}, domain: "bads1.example.com"},
+
+		// Unicode 条目与查询中的 punycode 域名互相匹配
+		{name: "idn entry", entries: []string{"=bücher.de"}, domain: "xn--bcher-kva.de", want: true},
+		{name: "punycode entry", entries: []string{"=xn--bcher-kva.de"}, domain: "BÜCHER.de", want: true},
+
+		{name: "empty list", domain: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := compileDomainList(tt.entries, tt.exact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := list.match(tt.domain); got != tt.want {
+				t.Errorf("%q match(%q) = %v, want %v", tt.entries, tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileDomainFilters(t *testing.T) {
+	defer func() { domainAllowlist, domainBlacklist = nil, nil }()
+
+	if err := compileDomainFilters(Config{DomainBlacklist: []string{"/[/"}}); err == nil {
+		t.Error("compileDomainFilters() with an invalid regexp: want error")
+	}
+
+	cfg := Config{
+		DomainAllowlist: []string{"*.internal"},
+		DomainBlacklist: []string{"example.com"},
+		DomainMatch:     domainMatchExact,
+	}
+	if err := compileDomainFilters(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for domain, want := range map[string]bool{
+		"host.internal":   true,
+		"example.com":     true,
+		"notexample.com":  false,
+		"www.example.com": false,
+		"example.org":     false,
+	} {
+		if got := isDomainFiltered(domain); got != want {
+			t.Errorf("isDomainFiltered(%q) = %v, want %v", domain, got, want)
+		}
+	}
+}
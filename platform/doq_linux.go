@@ -0,0 +1,110 @@
+package platform
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"dnsflux/common"
+)
+
+// DNS over QUIC 使用的 UDP 端口（RFC 9250）
+const doqPort = 853
+
+// 同一进程发往同一地址的加密 DNS 流量在该间隔内只上报一次，QUIC 连接会持续发送大量报文
+const doqReportInterval = time.Minute
+
+// 最多记录的上报时间条目数，超出时清理过期条目
+const maxDoQReported = 4096
+
+// filter_config 中启用 DoQ 检测的下标，与 dnsfilter.c 中的 CONFIG_DETECT_DOQ 一致
+const configDetectDoQ = 1
+
+type doqKey struct {
+	pid   uint32
+	daddr [16]byte
+}
+
+var (
+	doqMu       sync.Mutex
+	doqReported = make(map[doqKey]time.Time)
+)
+
+// 是否为发往 853 端口的 UDP 报文，内核只在启用 DoQ 检测时上报
+func isDoQEvent(event *dnsEvent) bool {
+	return event.Protocol == 17 && event.Dport == doqPort
+}
+
+// 判断进程发往该地址的加密 DNS 流量是否需要上报，同一组合每 doqReportInterval 只上报一次
+func shouldReportEncrypted(pid uint32, daddr [16]byte) bool {
+	key := doqKey{pid, daddr}
+	now := time.Now()
+
+	doqMu.Lock()
+	defer doqMu.Unlock()
+	last, ok := doqReported[key]
+	if ok && now.Sub(last) < doqReportInterval {
+		return false
+	}
+	if len(doqReported) >= maxDoQReported {
+		for k, t := range doqReported {
+			if now.Sub(t) >= doqReportInterval {
+				delete(doqReported, k)
+			}
+		}
+	}
+	doqReported[key] = now
+	return true
+}
+
+// 上报可能的 DoQ 流量，报文已加密，只输出连接信息
+func handleDoQ(event *dnsEvent) {
+	if !shouldReportEncrypted(event.PID, event.Daddr) {
+		return
+	}
+
+	procInfo := getProcessInfo(event.PID)
+	netns := procInfo.NetNS
+	if netnsFilter != nil && !netnsFilter[netns] {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+	if isProcessFiltered(event.PID, procInfo.Name, config) {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	container := eventContainer(procInfo, event.CgroupID)
+	resolver := eventIP(event.Family, event.Daddr)
+	threadName := string(bytes.TrimRight(event.Comm[:], "\x00"))
+	if threadName == procInfo.Name {
+		threadName = ""
+	}
+	emit(DNSEvent{
+		Timestamp:         displayTime(time.Now()),
+		QueryType:         "DoQ",
+		ProcessID:         event.PID,
+		ThreadID:          event.TID,
+		ThreadName:        threadName,
+		SocketCookie:      event.SocketCookie,
+		ProcessName:       procInfo.Name,
+		ProcessPath:       procInfo.Path,
+		ProcessStartTime:  procInfo.StartTime,
+		ParentProcessID:   procInfo.ParentPID,
+		ParentProcessName: parentProcessName(procInfo.ParentPID),
+		CommandLine:       procInfo.CommandLine,
+		CgroupID:          event.CgroupID,
+		ContainerID:       container.ID,
+		PodUID:            container.PodUID,
+		ClientIP:          eventIP(event.Family, event.Saddr).String(),
+		ResolverIP:        resolver.String(),
+		ResolverPort:      event.Dport,
+		Protocol:          "QUIC",
+		NetNS:             netns,
+		EncryptedDNS:      "DoQ",
+		Notes:             []string{fmt.Sprintf("DoQ to %s", net.JoinHostPort(resolver.String(), strconv.Itoa(doqPort)))},
+	})
+}
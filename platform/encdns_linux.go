@@ -0,0 +1,131 @@
+package platform
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"dnsflux/common"
+)
+
+// DoT（RFC 7858）和 DoH 的默认端口
+const (
+	defaultDoTPort = 853
+	defaultDoHPort = 443
+)
+
+// 内置的公共 DoH 解析器地址。连接事件只覆盖 IPv4，因此只列出 IPv4 地址
+var defaultDoHAddresses = []string{
+	"1.1.1.1", "1.0.0.1", // Cloudflare
+	"8.8.8.8", "8.8.4.4", // Google
+	"9.9.9.9", "149.112.112.112", // Quad9
+	"94.140.14.14", "94.140.15.15", // AdGuard
+	"208.67.222.222", "208.67.220.220", // OpenDNS
+	"45.90.28.0/24", "45.90.30.0/24", // NextDNS
+	"185.228.168.168", "185.228.169.168", // CleanBrowsing
+}
+
+// 根据连接的目标地址和端口判断可能的加密 DNS，只看地址和端口，不检查 TLS 的 SNI
+type encryptedDNSDetector struct {
+	dotPorts []uint16
+	dohPorts []uint16
+	dohNets  []*net.IPNet
+}
+
+// 启用时的加密 DNS 检测器，未启用时为 nil
+var encryptedDNS *encryptedDNSDetector
+
+// 按配置创建检测器，未启用时返回 nil；DoH 地址为 IP 或 CIDR，追加到内置列表
+func newEncryptedDNSDetector(cfg Config) (*encryptedDNSDetector, error) {
+	if !cfg.DetectEncryptedDNS {
+		return nil, nil
+	}
+	d := &encryptedDNSDetector{dotPorts: cfg.DoTPorts, dohPorts: cfg.DoHPorts}
+	if len(d.dotPorts) == 0 {
+		d.dotPorts = []uint16{defaultDoTPort}
+	}
+	if len(d.dohPorts) == 0 {
+		d.dohPorts = []uint16{defaultDoHPort}
+	}
+	for _, addr := range append(slices.Clone(defaultDoHAddresses), cfg.DoHAddresses...) {
+		ipnet, err := parseAddressOrCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: DoH 地址 %q 无效", ErrConfig, addr)
+		}
+		d.dohNets = append(d.dohNets, ipnet)
+	}
+	return d, nil
+}
+
+// 解析 IP 地址或 CIDR，单个地址视为只含该地址的网段
+func parseAddressOrCIDR(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	return ipnet, err
+}
+
+// 返回连接可能使用的加密 DNS 类型（DoT 或 DoH），不像时返回空
+func (d *encryptedDNSDetector) classify(ip net.IP, port uint16, protocol string) string {
+	if protocol == "TCP" && slices.Contains(d.dotPorts, port) {
+		return "DoT"
+	}
+	// DoH 可能走 HTTP/3，UDP 连接同样计入
+	if !slices.Contains(d.dohPorts, port) {
+		return ""
+	}
+	for _, ipnet := range d.dohNets {
+		if ipnet.Contains(ip) {
+			return "DoH"
+		}
+	}
+	return ""
+}
+
+// 上报可能的 DoT/DoH 连接，与 DoQ 相同，同一进程发往同一地址每分钟只上报一次
+func handleEncryptedConnect(event *connectEvent, ip net.IP, protocol, kind string) {
+	var daddr [16]byte
+	copy(daddr[:], ip.To4())
+	if !shouldReportEncrypted(event.PID, daddr) {
+		return
+	}
+
+	procInfo := getProcessInfo(event.PID)
+	netns := procInfo.NetNS
+	if netnsFilter != nil && !netnsFilter[netns] {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+	if isProcessFiltered(event.PID, procInfo.Name, config) {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	container := eventContainer(procInfo, 0)
+	emit(DNSEvent{
+		Timestamp:         displayTime(time.Now()),
+		QueryType:         kind,
+		ProcessID:         event.PID,
+		ThreadID:          event.TID,
+		ProcessName:       procInfo.Name,
+		ProcessPath:       procInfo.Path,
+		ProcessStartTime:  procInfo.StartTime,
+		ParentProcessID:   procInfo.ParentPID,
+		ParentProcessName: parentProcessName(procInfo.ParentPID),
+		CommandLine:       procInfo.CommandLine,
+		ContainerID:       container.ID,
+		PodUID:            container.PodUID,
+		ResolverIP:        ip.String(),
+		ResolverPort:      event.Dport,
+		Protocol:          protocol,
+		NetNS:             netns,
+		EncryptedDNS:      kind,
+		Notes:             []string{fmt.Sprintf("可能的 %s 连接 %s，查询内容已加密", kind, net.JoinHostPort(ip.String(), strconv.Itoa(int(event.Dport))))},
+	})
+}
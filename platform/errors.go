@@ -0,0 +1,13 @@
+package platform
+
+import "errors"
+
+// 监控退出的错误类别，调用方可用 errors.Is 区分并决定退出码
+var (
+	// ErrPermission 权限不足，如 Linux 非 root 运行
+	ErrPermission = errors.New("权限不足")
+	// ErrConfig 配置无效
+	ErrConfig = errors.New("配置无效")
+	// ErrSetup 初始化采集失败，如加载 eBPF 程序或启用 ETW Provider 失败
+	ErrSetup = errors.New("初始化失败")
+)
@@ -0,0 +1,64 @@
+package platform
+
+import "dnsflux/common"
+
+// DNSEvent 是 Linux eBPF 和 Windows ETW 两个后端统一产生的 DNS 事件，
+// 两个平台对同名字段的含义一致，平台取不到的字段保持零值（字符串为空），不使用占位符
+type DNSEvent = common.DNSRecord
+
+// DNS 记录类型名称（IANA DNS Parameters），两个平台的查询类型和应答记录类型共用，
+// 未列出的类型由调用方回退为 TYPEn（Linux）或 UNKNOWN(n)（Windows）
+var qtypeNames = map[uint16]string{
+	1:     "A",
+	2:     "NS",
+	5:     "CNAME",
+	6:     "SOA",
+	12:    "PTR",
+	13:    "HINFO",
+	15:    "MX",
+	16:    "TXT",
+	17:    "RP",
+	18:    "AFSDB",
+	24:    "SIG",
+	25:    "KEY",
+	28:    "AAAA",
+	29:    "LOC",
+	33:    "SRV",
+	35:    "NAPTR",
+	36:    "KX",
+	37:    "CERT",
+	39:    "DNAME",
+	41:    "OPT",
+	42:    "APL",
+	43:    "DS",
+	44:    "SSHFP",
+	45:    "IPSECKEY",
+	46:    "RRSIG",
+	47:    "NSEC",
+	48:    "DNSKEY",
+	49:    "DHCID",
+	50:    "NSEC3",
+	51:    "NSEC3PARAM",
+	52:    "TLSA",
+	53:    "SMIMEA",
+	55:    "HIP",
+	59:    "CDS",
+	60:    "CDNSKEY",
+	61:    "OPENPGPKEY",
+	62:    "CSYNC",
+	63:    "ZONEMD",
+	64:    "SVCB",
+	65:    "HTTPS",
+	99:    "SPF",
+	108:   "EUI48",
+	109:   "EUI64",
+	249:   "TKEY",
+	250:   "TSIG",
+	251:   "IXFR",
+	252:   "AXFR",
+	255:   "ANY",
+	256:   "URI",
+	257:   "CAA",
+	32768: "TA",
+	32769: "DLV",
+}
@@ -0,0 +1,55 @@
+//go:build !windows
+
+package platform
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// 输出格式定义
+const outputFormat = "%-19s  %-6d  %-6d  %-15s  %-40s  %-4s  %-21s  %-6s  %s\n"
+
+// FormatRecord 将记录格式化为单行文本
+func FormatRecord(record common.DNSRecord) string {
+	name := record.QueryName
+	if record.QueryNameUnicode != "" {
+		name += " (" + record.QueryNameUnicode + ")"
+	}
+	line := fmt.Sprintf(outputFormat,
+		record.Timestamp.Format("2006-01-02 15:04:05"),
+		record.ProcessID,
+		record.ThreadID,
+		record.ProcessName,
+		record.ProcessPath,
+		record.Protocol,
+		resolverAddress(record),
+		record.QueryType,
+		name,
+	)
+	if record.Response {
+		line = strings.TrimSuffix(line, "\n") + "  => " + strings.TrimSpace(record.Rcode+" "+record.QueryResult) + "\n"
+		if record.LatencyMs > 0 {
+			line = strings.TrimSuffix(line, "\n") + fmt.Sprintf(" (%.1fms)", record.LatencyMs) + "\n"
+		}
+	}
+	if len(record.ResolutionPath) > 0 {
+		line = strings.TrimSuffix(line, "\n") + "  " + strings.Join(record.ResolutionPath, " -> ") + "\n"
+	}
+	if len(record.Notes) > 0 {
+		line = strings.TrimSuffix(line, "\n") + "  [" + strings.Join(record.Notes, "; ") + "]\n"
+	}
+	return line
+}
+
+// 解析器地址，形如 8.8.8.8:53，未知时为 -
+func resolverAddress(record common.DNSRecord) string {
+	if record.ResolverIP == "" {
+		return "-"
+	}
+	return net.JoinHostPort(record.ResolverIP, strconv.Itoa(int(record.ResolverPort)))
+}
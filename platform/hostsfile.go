@@ -0,0 +1,93 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"dnsflux/common"
+)
+
+// hosts 文件中常见的本机条目，不作为拦截域名
+var hostsLocalNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+}
+
+// LoadHostsFile 读取 hosts 格式的拦截列表（如 Pi-hole 使用的 `0.0.0.0 example.com`），返回其中的域名。
+// 忽略行首的 IP 地址、整行及行内 # 注释，也接受只有域名的行；格式错误的行输出警告后跳过
+func LoadHostsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 读取 hosts 文件失败: %v", ErrConfig, err)
+	}
+	defer f.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// 行首为 IP 时其后为一个或多个域名，否则整行应为单个域名
+		names := fields
+		if net.ParseIP(fields[0]) != nil {
+			names = fields[1:]
+		}
+		if len(names) == 0 || (len(names) > 1 && len(names) == len(fields)) {
+			common.Warnf("%s:%d: 无法识别的 hosts 行，已跳过: %q", path, lineNo, scanner.Text())
+			continue
+		}
+
+		for _, name := range names {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			if hostsLocalNames[name] || net.ParseIP(name) != nil {
+				continue
+			}
+			if !isHostname(name) {
+				common.Warnf("%s:%d: 无效的域名 %q，已跳过", path, lineNo, name)
+				continue
+			}
+			domains = append(domains, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: 读取 hosts 文件 %s 失败: %v", ErrConfig, path, err)
+	}
+	return domains, nil
+}
+
+// 判断是否为合法的主机名：由字母、数字、- 和 _ 组成的非空标签，以 . 分隔
+func isHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
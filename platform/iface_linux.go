@@ -0,0 +1,162 @@
+//go:build linux
+
+package platform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// 需要监控的网络接口 ifindex，nil 表示全部
+var ifindexFilter map[uint32]bool
+
+// 将接口名称解析为 ifindex 集合
+func resolveInterfaces(names []string) (map[uint32]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	filter := make(map[uint32]bool, len(names))
+	for _, name := range names {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("未知的网络接口 %q: %v", name, err)
+		}
+		filter[uint32(iface.Index)] = true
+	}
+	return filter, nil
+}
+
+// 将接口过滤条件写入 eBPF map，由内核直接丢弃绑定到其他接口的套接字上的查询
+func applyInterfaceFilter(configMap, ifindexMap *ebpf.Map) error {
+	if ifindexFilter == nil {
+		return nil
+	}
+	for index := range ifindexFilter {
+		if err := ifindexMap.Put(index, uint8(1)); err != nil {
+			return fmt.Errorf("写入接口过滤表失败: %v", err)
+		}
+	}
+	if err := configMap.Put(uint32(0), uint32(1)); err != nil {
+		return fmt.Errorf("启用接口过滤失败: %v", err)
+	}
+	return nil
+}
+
+// IPv4 路由表项
+type routeEntry struct {
+	dest, mask uint32
+	ifindex    uint32
+}
+
+// 路由表缓存刷新间隔
+const routeCacheTTL = 30 * time.Second
+
+var (
+	routes        []routeEntry
+	routesLoaded  time.Time
+	routesMu      sync.Mutex
+	ifindexByName = make(map[string]uint32)
+)
+
+// 读取 /proc/net/route，地址字段为主机字节序的十六进制
+func loadRoutes() ([]routeEntry, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []routeEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // 跳过表头
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+
+		index, ok := ifindexByName[fields[0]]
+		if !ok {
+			iface, err := net.InterfaceByName(fields[0])
+			if err != nil {
+				continue
+			}
+			index = uint32(iface.Index)
+			ifindexByName[fields[0]] = index
+		}
+
+		dest, err1 := hex.DecodeString(fields[1])
+		mask, err2 := hex.DecodeString(fields[7])
+		if err1 != nil || err2 != nil || len(dest) != 4 || len(mask) != 4 {
+			continue
+		}
+		entries = append(entries, routeEntry{
+			dest:    binary.LittleEndian.Uint32(dest),
+			mask:    binary.LittleEndian.Uint32(mask),
+			ifindex: index,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// 按最长前缀匹配查找发往 addr 的出口接口，未找到时返回 0
+func routeInterface(addr net.IP) uint32 {
+	ip4 := addr.To4()
+	if ip4 == nil {
+		return 0
+	}
+	// 与 /proc/net/route 相同的主机字节序表示
+	target := binary.LittleEndian.Uint32(ip4)
+
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	if time.Since(routesLoaded) > routeCacheTTL {
+		if entries, err := loadRoutes(); err == nil {
+			routes = entries
+			routesLoaded = time.Now()
+		}
+	}
+
+	var best uint32
+	bestLen := -1
+	for _, r := range routes {
+		if target&r.mask != r.dest {
+			continue
+		}
+		if n := maskLen(r.mask); n > bestLen {
+			best, bestLen = r.ifindex, n
+		}
+	}
+	return best
+}
+
+// 掩码中置位的位数
+func maskLen(mask uint32) int {
+	n := 0
+	for ; mask != 0; mask &= mask - 1 {
+		n++
+	}
+	return n
+}
+
+// 判断事件所属接口是否需要监控，未绑定接口的套接字按路由表推断出口接口
+func interfaceAllowed(ifindex uint32, daddr net.IP) bool {
+	if ifindexFilter == nil {
+		return true
+	}
+	if ifindex == 0 {
+		ifindex = routeInterface(daddr)
+	}
+	return ifindexFilter[ifindex]
+}
@@ -0,0 +1,73 @@
+package platform
+
+import (
+	"fmt"
+
+	"dnsflux/common"
+
+	"github.com/cilium/ebpf"
+)
+
+// filter_config 中启用内核域名过滤的下标，与 dnsfilter.c 中的 CONFIG_DOMAIN_FILTER 一致
+const configDomainFilter = 2
+
+// filter_config 中启用自定义 DNS 端口的下标，与 dnsfilter.c 中的 CONFIG_DNS_PORTS 一致
+const configDNSPorts = 3
+
+// domain_filter 的键长度，与 dnsfilter.c 中的 DOMAIN_KEY_LEN 一致
+const domainKeyLen = 128
+
+// 可以在内核中按完整域名丢弃的条目：不带前缀和带 = 前缀的条目都会命中与其相同的域名，
+// 子域名、包含匹配、通配和正则仍由用户态的 isDomainFiltered 处理
+func kernelDomains() []string {
+	var names []string
+	for _, list := range []domainList{domainAllowlist, domainBlacklist} {
+		for _, p := range list {
+			if p.pattern == nil && p.text != "" && len(p.text) < domainKeyLen {
+				names = append(names, p.text)
+			}
+		}
+	}
+	return names
+}
+
+// 将 53 和配置的其他端口写入 eBPF map，只有发往这些端口的流量才会拷贝到 ring buffer；
+// 未配置其他端口时内核只认 53，不必写入
+func applyPortFilter(configMap, portMap *ebpf.Map, ports []uint16) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	for _, port := range append([]uint16{53}, ports...) {
+		if err := portMap.Put(port, uint8(1)); err != nil {
+			return fmt.Errorf("写入 DNS 端口 %d 失败: %v", port, err)
+		}
+	}
+	if err := configMap.Put(uint32(configDNSPorts), uint32(1)); err != nil {
+		return fmt.Errorf("启用 DNS 端口过滤失败: %v", err)
+	}
+	return nil
+}
+
+// 将黑白名单中的完整域名写入 eBPF map，命中的 UDP 查询和响应在内核中丢弃，
+// 不再占用 ring buffer；map 写满后剩余的域名仍由用户态过滤
+func applyDomainFilter(configMap, domainMap *ebpf.Map) error {
+	names := kernelDomains()
+	if len(names) == 0 {
+		return nil
+	}
+
+	added := 0
+	for _, name := range names {
+		var key [domainKeyLen]byte
+		copy(key[:], name)
+		if err := domainMap.Put(key, uint8(1)); err != nil {
+			common.Warnf("写入内核域名过滤表失败（已写入 %d 条），其余 %d 个域名由用户态过滤: %v", added, len(names)-added, err)
+			break
+		}
+		added++
+	}
+	if err := configMap.Put(uint32(configDomainFilter), uint32(1)); err != nil {
+		return fmt.Errorf("启用内核域名过滤失败: %v", err)
+	}
+	return nil
+}
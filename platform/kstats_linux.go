@@ -0,0 +1,130 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dnsflux/common"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// kernel_stats 中的下标，与 dnsfilter.c 中的 STAT_* 一致
+const (
+	statSubmitted = iota
+	statDropped
+	statRingbufAvail
+	statFiltered
+)
+
+// 当前使用中的采集器，重新加载时替换
+var activeCollector atomic.Pointer[bpfCollector]
+
+// 程序运行统计开关，启用后保持到进程退出
+var (
+	programStatsOnce sync.Once
+	programStats     bool
+)
+
+// ProgramStats 单个 eBPF 程序的运行统计，需启用 bpf_stats
+type ProgramStats struct {
+	Name     string
+	RunCount uint64
+	RunTime  time.Duration
+}
+
+// KernelStats eBPF 侧的统计信息
+type KernelStats struct {
+	Submitted    uint64 // 提交到 ring buffer 的事件数
+	Dropped      uint64 // ring buffer 已满而在内核中丢弃的事件数
+	Filtered     uint64 // 命中内核域名过滤表而丢弃的事件数
+	RingbufUsed  uint64 // 待读取的字节数（各 CPU 最近一次观测中的最大值）
+	RingbufSize  int
+	Programs     []ProgramStats // 未启用 bpf_stats 时为空
+	StatsEnabled bool
+}
+
+// String 输出一行统计摘要
+func (s KernelStats) String() string {
+	line := fmt.Sprintf("submitted=%d dropped=%d filtered=%d ringbuf=%d/%d", s.Submitted, s.Dropped, s.Filtered, s.RingbufUsed, s.RingbufSize)
+	for _, p := range s.Programs {
+		avg := time.Duration(0)
+		if p.RunCount > 0 {
+			avg = p.RunTime / time.Duration(p.RunCount)
+		}
+		line += fmt.Sprintf(" %s=%d次/%s(平均 %s)", p.Name, p.RunCount, p.RunTime, avg)
+	}
+	return line
+}
+
+// EnableKernelStats 启用内核的 eBPF 程序运行统计（bpf_stats），失败时仅输出警告
+func EnableKernelStats() {
+	programStatsOnce.Do(func() {
+		// 关闭返回的句柄会停用统计，这里保持到进程退出
+		if _, err := ebpf.EnableStats(unix.BPF_STATS_RUN_TIME); err != nil {
+			common.Warnf("启用 eBPF 程序运行统计失败，将不输出运行次数和耗时: %v", err)
+			return
+		}
+		programStats = true
+	})
+}
+
+// 读取 per-CPU 统计项，返回各 CPU 之和与最大值
+func readPerCPU(m *ebpf.Map, key uint32) (sum, peak uint64, err error) {
+	var values []uint64
+	if err := m.Lookup(key, &values); err != nil {
+		return 0, 0, fmt.Errorf("读取 kernel_stats 失败: %v", err)
+	}
+	for _, v := range values {
+		sum += v
+		peak = max(peak, v)
+	}
+	return sum, peak, nil
+}
+
+// ReadKernelStats 读取当前采集器的内核侧统计
+func ReadKernelStats() (KernelStats, error) {
+	c := activeCollector.Load()
+	if c == nil {
+		return KernelStats{}, errors.New("eBPF 程序未加载")
+	}
+
+	stats := KernelStats{RingbufSize: c.reader.BufferSize(), StatsEnabled: programStats}
+	var err error
+	if stats.Submitted, _, err = readPerCPU(c.objs.KernelStats, statSubmitted); err != nil {
+		return stats, err
+	}
+	if stats.Dropped, _, err = readPerCPU(c.objs.KernelStats, statDropped); err != nil {
+		return stats, err
+	}
+	if stats.Filtered, _, err = readPerCPU(c.objs.KernelStats, statFiltered); err != nil {
+		return stats, err
+	}
+	if _, stats.RingbufUsed, err = readPerCPU(c.objs.KernelStats, statRingbufAvail); err != nil {
+		return stats, err
+	}
+
+	if !programStats {
+		return stats, nil
+	}
+	for _, p := range []struct {
+		name    string
+		program *ebpf.Program
+	}{
+		{"udp_sendmsg", c.objs.TraceUdpSendmsg},
+		{"tcp_sendmsg", c.objs.TraceTcpSendmsg},
+	} {
+		info, err := p.program.Info()
+		if err != nil {
+			continue
+		}
+		count, _ := info.RunCount()
+		runtime, _ := info.Runtime()
+		stats.Programs = append(stats.Programs, ProgramStats{Name: p.name, RunCount: count, RunTime: runtime})
+	}
+	return stats, nil
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+
+package platform
+
+import (
+	"errors"
+	"time"
+)
+
+// ProgramStats 单个 eBPF 程序的运行统计
+type ProgramStats struct {
+	Name     string
+	RunCount uint64
+	RunTime  time.Duration
+}
+
+// KernelStats eBPF 侧的统计信息
+type KernelStats struct {
+	Submitted    uint64
+	Dropped      uint64
+	Filtered     uint64
+	RingbufUsed  uint64
+	RingbufSize  int
+	Programs     []ProgramStats
+	StatsEnabled bool
+}
+
+// String 输出一行统计摘要
+func (s KernelStats) String() string {
+	return ""
+}
+
+// EnableKernelStats eBPF 统计仅在 Linux 上可用
+func EnableKernelStats() {}
+
+// ReadKernelStats eBPF 统计仅在 Linux 上可用
+func ReadKernelStats() (KernelStats, error) {
+	return KernelStats{}, errors.New("eBPF 统计仅支持 Linux 平台")
+}
@@ -0,0 +1,152 @@
+//go:build windows
+// +build windows
+
+package platform
+
+import (
+	"container/list"
+	"strings"
+	"time"
+)
+
+// DNS-Client 事件 ID
+const (
+	eventQueryStart      = 3006 // 开始查询
+	eventQueryCompleted  = 3008 // 已完成的查询
+	eventIndexedQuery    = 3009 // 发起索引查询
+	eventServerQuery     = 3010 // 发起 DNS 服务查询
+	eventServerResponse  = 3011 // DNS 服务器响应
+	eventCacheResponse   = 3018 // 缓存查询响应
+	eventIndexedResponse = 3020 // 索引查询响应
+)
+
+// 查询事件合并的窗口和容量，超出后事件直接输出
+const (
+	lookupWindow      = 5 * time.Second
+	maxLookupEvents   = 16   // 单次查询暂存的事件数
+	maxPendingLookups = 4096 // 待合并的查询数，超出时最早的查询先输出
+)
+
+// 同一次查询的关联键：进程、查询域名（不区分大小写）和查询类型
+type lookupKey struct {
+	pid   uint32
+	name  string
+	qtype string
+}
+
+// 等待 3008 事件的查询
+type pendingLookup struct {
+	key     lookupKey
+	events  []DNSEvent
+	expires time.Time
+}
+
+// 将同一次查询的缓存、服务器响应等事件与 3008 事件合并为一条记录，
+// 窗口内等不到 3008 的事件到期后逐条输出。只在事件处理协程中使用，不加锁
+type lookupTable struct {
+	entries map[lookupKey]*list.Element
+	order   *list.List // 最早的在前，到期时间与加入顺序一致
+}
+
+func newLookupTable() *lookupTable {
+	return &lookupTable{
+		entries: make(map[lookupKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// 处理一个 DNS-Client 事件：3008 事件与暂存的事件合并后输出，其他事件暂存
+func (t *lookupTable) add(event DNSEvent, now time.Time) {
+	key := lookupKey{pid: event.ProcessID, name: strings.ToLower(event.QueryName), qtype: event.QueryType}
+	elem, ok := t.entries[key]
+	if event.EventID == eventQueryCompleted {
+		if ok {
+			t.remove(elem)
+			event = mergeLookup(event, elem.Value.(*pendingLookup).events)
+		}
+		emit(event)
+		return
+	}
+
+	if !ok {
+		if t.order.Len() >= maxPendingLookups {
+			t.flush(t.order.Front())
+		}
+		elem = t.order.PushBack(&pendingLookup{key: key, expires: now.Add(lookupWindow)})
+		t.entries[key] = elem
+	}
+	pending := elem.Value.(*pendingLookup)
+	if len(pending.events) >= maxLookupEvents {
+		emit(event)
+		return
+	}
+	pending.events = append(pending.events, event)
+}
+
+// 输出已超过合并窗口的查询
+func (t *lookupTable) expire(now time.Time) {
+	for elem := t.order.Front(); elem != nil; elem = t.order.Front() {
+		if now.Before(elem.Value.(*pendingLookup).expires) {
+			return
+		}
+		t.flush(elem)
+	}
+}
+
+// 输出全部暂存的事件，事件处理结束时调用
+func (t *lookupTable) flushAll() {
+	for elem := t.order.Front(); elem != nil; elem = t.order.Front() {
+		t.flush(elem)
+	}
+}
+
+// 移除查询并逐条输出其事件
+func (t *lookupTable) flush(elem *list.Element) {
+	t.remove(elem)
+	for _, event := range elem.Value.(*pendingLookup).events {
+		emit(event)
+	}
+}
+
+func (t *lookupTable) remove(elem *list.Element) {
+	t.order.Remove(elem)
+	delete(t.entries, elem.Value.(*pendingLookup).key)
+}
+
+// 将同一次查询的其他事件合并到 3008 事件：缓存命中时应答来源为 cache，
+// 向 DNS 服务器发出查询或收到其响应时为 server，并补充 3008 事件中缺少的解析器地址和查询结果；
+// 有开始查询或发出查询的事件（3006、3009、3010，需在事件 ID 白名单中）时由最早的一个计算耗时
+func mergeLookup(completed DNSEvent, events []DNSEvent) DNSEvent {
+	cached, queried := false, false
+	var started time.Time
+	for _, event := range events {
+		switch event.EventID {
+		case eventQueryStart, eventIndexedQuery, eventServerQuery:
+			if started.IsZero() || event.Timestamp.Before(started) {
+				started = event.Timestamp
+			}
+		}
+		switch event.EventID {
+		case eventCacheResponse:
+			cached = cached || event.Status == statusMap[0]
+		case eventIndexedQuery, eventServerQuery, eventServerResponse, eventIndexedResponse:
+			queried = true
+		}
+		if completed.ResolverIP == "" {
+			completed.ResolverIP = event.ResolverIP
+		}
+		if completed.QueryResult == "" && event.QueryResult != "" {
+			completed.QueryResult, completed.Answers = event.QueryResult, event.Answers
+		}
+	}
+	switch {
+	case cached:
+		completed.AnswerSource = "cache"
+	case queried:
+		completed.AnswerSource = "server"
+	}
+	if !started.IsZero() && !completed.Timestamp.Before(started) {
+		completed.LatencyMs = float64(completed.Timestamp.Sub(started).Microseconds()) / 1000
+	}
+	return completed
+}
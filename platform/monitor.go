@@ -0,0 +1,106 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"dnsflux/common"
+	"dnsflux/pipeline"
+)
+
+// Monitor 事件 channel 的缓冲长度，调用方消费不及时、缓冲写满时丢弃事件
+const monitorQueueSize = 1024
+
+// 采集到的事件的去向，默认进入处理流程，由 Monitor 改为写入事件 channel
+var emit = pipeline.Submit
+
+// 采集后端使用包级状态，同一时间只能运行一个监控
+var monitorRunning atomic.Bool
+
+// Monitor 以库的形式运行 DNS 监控，事件通过 channel 交给调用方：
+//
+//	mon := platform.NewMonitor()
+//	ch, err := mon.Start(ctx)
+//	for event := range ch { ... }
+//
+// 事件不经过处理环节和输出端。同一时间只能运行一个 Monitor
+type Monitor struct {
+	// Config 在 Start 之前修改生效，默认为 DefaultConfig()
+	Config Config
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+// NewMonitor 使用默认配置创建监控
+func NewMonitor() *Monitor {
+	return &Monitor{Config: DefaultConfig()}
+}
+
+// Start 校验配置并开始采集，配置无效或初始化失败时返回错误而不是退出进程。返回的 channel 在 ctx 取消、调用 Stop 或采集出错后关闭，
+// 之后可通过 Err 取得出错原因
+func (m *Monitor) Start(ctx context.Context) (<-chan DNSEvent, error) {
+	// 校验会就地整理事件 ID 列表，使用副本以免改动调用方的配置
+	cfg := m.Config
+	cfg.EventIDWhitelist = slices.Clone(cfg.EventIDWhitelist)
+	if err := validateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	if !monitorRunning.CompareAndSwap(false, true) {
+		return nil, errors.New("已有监控在运行")
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	events := make(chan DNSEvent, monitorQueueSize)
+	emit = func(event DNSEvent) {
+		select {
+		case events <- event:
+		default:
+			common.Stats.Dropped.Add(1)
+		}
+	}
+
+	started := make(chan struct{})
+	go func() {
+		m.err = run(ctx, cfg, func() { close(started) })
+		// run 返回后不再产生事件，可以安全关闭 channel
+		close(events)
+		emit = pipeline.Submit
+		monitorRunning.Store(false)
+		close(m.done)
+	}()
+
+	select {
+	case <-started:
+		return events, nil
+	case <-m.done:
+		m.cancel()
+		return nil, m.err
+	}
+}
+
+// Stop 停止采集并等待后端释放资源，可重复调用
+func (m *Monitor) Stop() {
+	m.once.Do(func() {
+		if m.cancel != nil {
+			m.cancel()
+			<-m.done
+		}
+	})
+}
+
+// Err 返回采集结束的原因，正常停止时为 nil
+func (m *Monitor) Err() error {
+	select {
+	case <-m.done:
+		return m.err
+	default:
+		return nil
+	}
+}
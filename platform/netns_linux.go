@@ -0,0 +1,118 @@
+//go:build linux
+// +build linux
+
+package platform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 具名网络命名空间的挂载目录（ip netns add 创建）
+const netnsRunDir = "/var/run/netns"
+
+// NetNamespace 网络命名空间信息
+type NetNamespace struct {
+	Inode uint64
+	Name  string // 具名命名空间的名称，匿名（如容器）为空
+	PIDs  int    // 位于该命名空间中的进程数
+}
+
+// 读取进程所属网络命名空间的 inode，失败返回 0
+func netnsInode(pid uint32) uint64 {
+	link, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		return 0
+	}
+	// 链接内容形如 net:[4026531840]
+	link = strings.TrimSuffix(strings.TrimPrefix(link, "net:["), "]")
+	inode, err := strconv.ParseUint(link, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return inode
+}
+
+// 读取具名网络命名空间，返回 inode 到名称的映射
+func namedNetNamespaces() map[uint64]string {
+	names := make(map[uint64]string)
+	entries, err := os.ReadDir(netnsRunDir)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		var st syscall.Stat_t
+		if err := syscall.Stat(filepath.Join(netnsRunDir, entry.Name()), &st); err == nil {
+			names[st.Ino] = entry.Name()
+		}
+	}
+	return names
+}
+
+// ListNetNamespaces 枚举具名网络命名空间及所有进程所在的网络命名空间
+func ListNetNamespaces() ([]NetNamespace, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	byInode := make(map[uint64]*NetNamespace)
+	for inode, name := range namedNetNamespaces() {
+		byInode[inode] = &NetNamespace{Inode: inode, Name: name}
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		inode := netnsInode(uint32(pid))
+		if inode == 0 {
+			continue
+		}
+		ns, ok := byInode[inode]
+		if !ok {
+			ns = &NetNamespace{Inode: inode}
+			byInode[inode] = ns
+		}
+		ns.PIDs++
+	}
+
+	list := make([]NetNamespace, 0, len(byInode))
+	for _, ns := range byInode {
+		list = append(list, *ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Inode < list[j].Inode })
+	return list, nil
+}
+
+// 将命名空间名称或 inode 解析为 inode 集合
+func resolveNetNamespaces(specs []string) (map[uint64]bool, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	named := make(map[string]uint64)
+	for inode, name := range namedNetNamespaces() {
+		named[name] = inode
+	}
+
+	inodes := make(map[uint64]bool)
+	for _, spec := range specs {
+		if inode, err := strconv.ParseUint(spec, 10, 64); err == nil {
+			inodes[inode] = true
+			continue
+		}
+		inode, ok := named[spec]
+		if !ok {
+			return nil, fmt.Errorf("未找到网络命名空间: %s", spec)
+		}
+		inodes[inode] = true
+	}
+	return inodes, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package platform
+
+import "errors"
+
+// NetNamespace 网络命名空间信息
+type NetNamespace struct {
+	Inode uint64
+	Name  string
+	PIDs  int
+}
+
+// ListNetNamespaces 网络命名空间仅在 Linux 上可用
+func ListNetNamespaces() ([]NetNamespace, error) {
+	return nil, errors.New("网络命名空间仅支持 Linux 平台")
+}
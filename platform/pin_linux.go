@@ -0,0 +1,190 @@
+//go:build linux
+
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dnsflux/common"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+// 固定在 bpffs 中的 kprobe link 文件名前缀，map 以其名称固定在同一目录
+const pinnedLinkPrefix = "link_"
+
+// 加载 eBPF 对象；设置了 PinPath 时 map 按名称固定在该目录，已存在且兼容的固定 map 直接复用，
+// 与当前程序不兼容（升级后布局变化）时清理旧的固定对象后重新加载
+func loadObjects(spec *ebpf.CollectionSpec, objs any) error {
+	if config.PinPath == "" {
+		return spec.LoadAndAssign(objs, nil)
+	}
+
+	// .rodata 等数据段随程序加载，不固定
+	for name, m := range spec.Maps {
+		if !strings.HasPrefix(name, ".") {
+			m.Pinning = ebpf.PinByName
+		}
+	}
+	if err := os.MkdirAll(config.PinPath, 0o700); err != nil {
+		return fmt.Errorf("创建固定目录失败: %v", err)
+	}
+	opts := &ebpf.CollectionOptions{Maps: ebpf.MapOptions{PinPath: config.PinPath}}
+	err := spec.LoadAndAssign(objs, opts)
+	if errors.Is(err, ebpf.ErrMapIncompatible) {
+		common.Warnf("%s 中固定的 map 与当前程序不兼容，清理后重新加载: %v", config.PinPath, err)
+		if err := removePins(config.PinPath, spec); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(config.PinPath, 0o700); err != nil {
+			return fmt.Errorf("创建固定目录失败: %v", err)
+		}
+		err = spec.LoadAndAssign(objs, opts)
+	}
+	return err
+}
+
+// 清空复用的固定 map 中上次运行写入的过滤配置，随后按本次配置重新写入
+func resetFilterMaps(c *bpfCollector) error {
+	for _, m := range []*ebpf.Map{c.objs.IfindexFilter, c.objs.DomainFilter, c.objs.DNSPorts} {
+		key := make([]byte, m.KeySize())
+		for m.NextKey(nil, key) == nil {
+			if err := m.Delete(key); err != nil {
+				return fmt.Errorf("清空固定的过滤表失败: %v", err)
+			}
+		}
+	}
+	for i := uint32(0); i < c.objs.FilterConfig.MaxEntries(); i++ {
+		if err := c.objs.FilterConfig.Put(i, uint32(0)); err != nil {
+			return fmt.Errorf("清空固定的过滤配置失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// 附加 kprobe；设置了 PinPath 时先查找固定的 link，其程序与本次加载的相同时直接复用，
+// 重启期间探针不中断，*prog 替换为正在运行的程序；否则移除旧 link，重新附加并固定。
+// 返回 link 固定的路径，未固定或内核不支持固定 kprobe link（5.15 以前）时为空
+func attachKprobe(symbol string, ret bool, prog **ebpf.Program) (l link.Link, path string, err error) {
+	attach, name := link.Kprobe, symbol
+	if ret {
+		attach, name = link.Kretprobe, symbol+"_ret"
+	}
+	if config.PinPath == "" {
+		l, err = attach(symbol, *prog, nil)
+		return l, "", err
+	}
+
+	path = filepath.Join(config.PinPath, pinnedLinkPrefix+name)
+	if l, running := loadPinnedLink(path, *prog); l != nil {
+		(*prog).Close()
+		*prog = running
+		return l, path, nil
+	}
+	if l, err = attach(symbol, *prog, nil); err != nil {
+		return nil, "", err
+	}
+	if err := l.Pin(path); err != nil {
+		common.Debugf("固定 kprobe %s 失败: %v", name, err)
+		return l, "", nil
+	}
+	return l, path, nil
+}
+
+// 打开固定的 link 及其程序，程序与 prog 的类型和 tag 一致时返回二者；
+// 不一致或无法读取时移除固定文件（旧探针随之卸载）并返回 nil
+func loadPinnedLink(path string, prog *ebpf.Program) (link.Link, *ebpf.Program) {
+	l, err := link.LoadPinnedLink(path, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err == nil {
+		if running := linkProgram(l); running != nil {
+			if sameProgram(running, prog) {
+				return l, running
+			}
+			running.Close()
+		}
+		l.Close()
+	}
+	common.Debugf("移除过期的固定 link %s", path)
+	os.Remove(path)
+	return nil, nil
+}
+
+// link 当前挂载的程序，读取失败时返回 nil
+func linkProgram(l link.Link) *ebpf.Program {
+	info, err := l.Info()
+	if err != nil {
+		return nil
+	}
+	prog, err := ebpf.NewProgramFromID(info.Program)
+	if err != nil {
+		return nil
+	}
+	return prog
+}
+
+// 两个程序的类型和指令 tag 是否相同
+func sameProgram(a, b *ebpf.Program) bool {
+	ai, err := a.Info()
+	if err != nil {
+		return false
+	}
+	bi, err := b.Info()
+	if err != nil {
+		return false
+	}
+	return ai.Type == bi.Type && ai.Tag == bi.Tag
+}
+
+// 移除本次未使用的固定 link（如上次启用而本次关闭的响应采集），对应探针随之卸载
+func removeStaleLinks(dir string, keep map[string]bool) {
+	paths, _ := filepath.Glob(filepath.Join(dir, pinnedLinkPrefix+"*"))
+	for _, path := range paths {
+		if !keep[path] {
+			common.Debugf("移除未使用的固定 link %s", path)
+			os.Remove(path)
+		}
+	}
+}
+
+// 移除目录中本程序固定的 link 和 map，目录为空时一并删除；
+// 只删除本程序的 map 名称和 link_ 前缀的文件，不影响其他程序固定在同一目录下的对象
+func removePins(dir string, spec *ebpf.CollectionSpec) error {
+	removeStaleLinks(dir, nil)
+	for name := range spec.Maps {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("移除固定的 map %s 失败: %v", name, err)
+		}
+	}
+	os.Remove(dir)
+	return nil
+}
+
+// 退出时按配置处理固定的对象：KeepPinned 时保留，探针继续采集到 ring buffer，下次启动复用；
+// 否则移除，探针随之卸载
+func releasePins(probes int) {
+	if config.PinPath == "" {
+		common.Infof("正在退出，已卸载 %d 个探针", probes)
+		return
+	}
+	if config.KeepPinned {
+		common.Infof("正在退出，%d 个探针保持固定在 %s，下次启动时复用", probes, config.PinPath)
+		return
+	}
+	spec, err := loadDns_bpf()
+	if err == nil {
+		err = removePins(config.PinPath, spec)
+	}
+	if err != nil {
+		common.Warnf("清理 %s 中固定的 eBPF 对象失败: %v", config.PinPath, err)
+		return
+	}
+	common.Infof("正在退出，已卸载 %d 个探针并清理 %s", probes, config.PinPath)
+}
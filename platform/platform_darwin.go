@@ -0,0 +1,552 @@
+//go:build darwin
+
+package platform
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"dnsflux/common"
+	"dnsflux/pipeline"
+
+	"golang.org/x/sys/unix"
+)
+
+// 应用通常经由 mDNSResponder 解析，发往 DNS 服务器的查询大多属于它，默认不屏蔽任何进程
+var defaultProcessDenylist []string
+
+// BPF 设备的读取缓冲区大小，不超过系统的 debug.bpf_maxbufsize（默认 512KB）
+const bpfBufferSize = 512 * 1024
+
+// 读取超时，超时后检查是否需要退出
+const bpfReadTimeout = time.Second
+
+// 抓包只能取得本地端口，按端口查找所属进程的结果缓存时间
+const socketOwnerTTL = 2 * time.Second
+
+// 一个网络接口上的抓包
+type bpfTap struct {
+	fd       int
+	ifname   string
+	linkType int
+	buf      []byte
+}
+
+// 打开空闲的 /dev/bpfN 并绑定到网络接口
+func openTap(ifname string) (*bpfTap, error) {
+	fd := -1
+	for i := 0; i < 256; i++ {
+		var err error
+		fd, err = unix.Open(fmt.Sprintf("/dev/bpf%d", i), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, unix.EBUSY) {
+			continue
+		}
+		if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPERM) {
+			return nil, fmt.Errorf("%w: 打开 BPF 设备失败: %v", ErrPermission, err)
+		}
+		return nil, fmt.Errorf("%w: 打开 BPF 设备失败: %v", ErrSetup, err)
+	}
+	if fd < 0 {
+		return nil, fmt.Errorf("%w: 没有空闲的 BPF 设备", ErrSetup)
+	}
+
+	tap := &bpfTap{fd: fd, ifname: ifname}
+	if err := tap.setup(); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("%w: 在接口 %s 上抓包失败: %v", ErrSetup, ifname, err)
+	}
+	return tap, nil
+}
+
+// 设置缓冲区、绑定接口、开启即时模式和读取超时
+func (t *bpfTap) setup() error {
+	// 缓冲区大小必须在绑定接口之前设置
+	if err := unix.IoctlSetPointerInt(t.fd, unix.BIOCSBLEN, bpfBufferSize); err != nil {
+		return fmt.Errorf("设置缓冲区失败: %v", err)
+	}
+	size, err := unix.IoctlGetInt(t.fd, unix.BIOCGBLEN)
+	if err != nil {
+		return fmt.Errorf("读取缓冲区大小失败: %v", err)
+	}
+	t.buf = make([]byte, size)
+
+	var ifreq [unix.IFNAMSIZ + 16]byte
+	copy(ifreq[:unix.IFNAMSIZ-1], t.ifname)
+	if err := ioctlPtr(t.fd, unix.BIOCSETIF, unsafe.Pointer(&ifreq)); err != nil {
+		return fmt.Errorf("绑定接口失败: %v", err)
+	}
+	if err := unix.IoctlSetPointerInt(t.fd, unix.BIOCIMMEDIATE, 1); err != nil {
+		return fmt.Errorf("开启即时模式失败: %v", err)
+	}
+	// 同时抓取本机发出的报文
+	if err := unix.IoctlSetPointerInt(t.fd, unix.BIOCSSEESENT, 1); err != nil {
+		return fmt.Errorf("开启发送方向抓包失败: %v", err)
+	}
+	timeout := unix.NsecToTimeval(bpfReadTimeout.Nanoseconds())
+	if err := ioctlPtr(t.fd, unix.BIOCSRTIMEOUT, unsafe.Pointer(&timeout)); err != nil {
+		return fmt.Errorf("设置读取超时失败: %v", err)
+	}
+	if t.linkType, err = unix.IoctlGetInt(t.fd, unix.BIOCGDLT); err != nil {
+		return fmt.Errorf("读取链路类型失败: %v", err)
+	}
+	switch t.linkType {
+	case unix.DLT_NULL, unix.DLT_EN10MB, unix.DLT_RAW:
+	default:
+		return fmt.Errorf("不支持的链路类型 %d", t.linkType)
+	}
+	return nil
+}
+
+// x/sys/unix 没有导出任意结构体参数的 ioctl
+func ioctlPtr(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (t *bpfTap) Close() {
+	unix.Close(t.fd)
+}
+
+// 持续读取报文直到 ctx 取消（返回 nil）或读取出错
+func (t *bpfTap) readPackets(ctx context.Context) error {
+	for ctx.Err() == nil {
+		n, err := unix.Read(t.fd, t.buf)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN) {
+				continue
+			}
+			return err
+		}
+
+		// 缓冲区中为若干 bpf_hdr + 报文，每条按 4 字节对齐
+		for off := 0; off+unix.SizeofBpfHdr <= n; {
+			hdr := (*unix.BpfHdr)(unsafe.Pointer(&t.buf[off]))
+			start := off + int(hdr.Hdrlen)
+			end := start + int(hdr.Caplen)
+			if end > n {
+				break
+			}
+			timestamp := time.Unix(int64(hdr.Tstamp.Sec), int64(hdr.Tstamp.Usec)*1000)
+			t.handleFrame(t.buf[start:end], timestamp, hdr.Caplen < hdr.Datalen)
+			off += (int(hdr.Hdrlen) + int(hdr.Caplen) + 3) &^ 3
+		}
+	}
+	return nil
+}
+
+// 去掉链路层头部，返回 IP 报文
+func (t *bpfTap) ipPayload(frame []byte) []byte {
+	switch t.linkType {
+	case unix.DLT_NULL:
+		// 4 字节主机字节序的地址族
+		if len(frame) < 4 {
+			return nil
+		}
+		return frame[4:]
+	case unix.DLT_EN10MB:
+		if len(frame) < 14 {
+			return nil
+		}
+		etherType, offset := binary.BigEndian.Uint16(frame[12:14]), 14
+		if etherType == 0x8100 && len(frame) >= 18 {
+			etherType, offset = binary.BigEndian.Uint16(frame[16:18]), 18
+		}
+		if etherType != 0x0800 && etherType != 0x86DD {
+			return nil
+		}
+		return frame[offset:]
+	}
+	return frame
+}
+
+// 一个 DNS 报文及其地址
+type capturedPacket struct {
+	protocol     uint16 // 6 或 17
+	src, dst     net.IP
+	sport, dport uint16
+	payload      []byte
+}
+
+// 解析 IPv4/IPv6 和 UDP/TCP 头部，分片和带扩展头的 IPv6 报文不处理
+func parseIPPacket(data []byte) (capturedPacket, bool) {
+	var pkt capturedPacket
+	if len(data) < 1 {
+		return pkt, false
+	}
+	var transport []byte
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return pkt, false
+		}
+		ihl := int(data[0]&0x0F) * 4
+		total := int(binary.BigEndian.Uint16(data[2:4]))
+		// 非首个分片没有传输层头部
+		if ihl < 20 || len(data) < ihl || binary.BigEndian.Uint16(data[6:8])&0x1FFF != 0 {
+			return pkt, false
+		}
+		if total >= ihl && total < len(data) {
+			data = data[:total]
+		}
+		pkt.protocol = uint16(data[9])
+		pkt.src, pkt.dst = net.IP(data[12:16]), net.IP(data[16:20])
+		transport = data[ihl:]
+	case 6:
+		if len(data) < 40 {
+			return pkt, false
+		}
+		if length := 40 + int(binary.BigEndian.Uint16(data[4:6])); length < len(data) {
+			data = data[:length]
+		}
+		pkt.protocol = uint16(data[6])
+		pkt.src, pkt.dst = net.IP(data[8:24]), net.IP(data[24:40])
+		transport = data[40:]
+	default:
+		return pkt, false
+	}
+
+	switch pkt.protocol {
+	case 17:
+		if len(transport) < 8 {
+			return pkt, false
+		}
+		pkt.payload = transport[8:]
+	case 6:
+		if len(transport) < 20 {
+			return pkt, false
+		}
+		dataOffset := int(transport[12]>>4) * 4
+		if dataOffset < 20 || len(transport) < dataOffset {
+			return pkt, false
+		}
+		pkt.payload = transport[dataOffset:]
+	default:
+		return pkt, false
+	}
+	pkt.sport = binary.BigEndian.Uint16(transport[0:2])
+	pkt.dport = binary.BigEndian.Uint16(transport[2:4])
+	return pkt, len(pkt.payload) > 0
+}
+
+// 是否为 DNS 端口：53 和 DNSPorts 中的端口
+func isDNSPort(port uint16) bool {
+	if port == 53 {
+		return true
+	}
+	for _, p := range config.DNSPorts {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析单个链路层帧并输出
+func (t *bpfTap) handleFrame(frame []byte, timestamp time.Time, truncated bool) {
+	if pipeline.Paused() {
+		return
+	}
+	pkt, ok := parseIPPacket(t.ipPayload(frame))
+	if !ok {
+		return
+	}
+
+	// 发往 DNS 端口的是查询，来自 DNS 端口的是响应
+	response := false
+	switch {
+	case isDNSPort(pkt.dport):
+	case isDNSPort(pkt.sport) && config.CaptureResponses:
+		response = true
+	default:
+		return
+	}
+	localIP, localPort, resolver, resolverPort := pkt.src, pkt.sport, pkt.dst, pkt.dport
+	if response {
+		localIP, localPort, resolver, resolverPort = pkt.dst, pkt.dport, pkt.src, pkt.sport
+	}
+
+	data, size := pkt.payload, len(pkt.payload)
+	if pkt.protocol == 6 {
+		data, size = stripTCPLength(data, size)
+	}
+	dnsInfo := parseDNSPacket(data)
+	if dnsInfo == nil {
+		common.Stats.ParseErrors.Add(1)
+		return
+	}
+	// 本机作为 DNS 服务端时的查询和回复方向相反，不处理
+	if dnsInfo.Response != response {
+		return
+	}
+
+	questions := unfilteredQuestions(dnsInfo.Questions)
+	if len(questions) == 0 {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+	if !config.IncludeLoopback && resolver.IsLoopback() {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	proto := protocolMap[pkt.protocol]
+	pid := socketOwner(proto, localPort)
+	procInfo := ProcessInfo{Name: "unknown", Path: "unknown"}
+	if pid != 0 {
+		procInfo = procCache.get(pid, readProcessInfo)
+	}
+	if isProcessFiltered(pid, procInfo.Name, config) {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	// 每个问题一条记录，提交到处理流程，再分发到各输出端
+	emitQuestions(DNSEvent{
+		Timestamp:        displayTime(timestamp),
+		ProcessID:        pid,
+		ProcessName:      procInfo.Name,
+		ProcessPath:      procInfo.Path,
+		ProcessStartTime: procInfo.StartTime,
+		ClientIP:         localIP.String(),
+		ResolverIP:       resolver.String(),
+		ResolverPort:     resolverPort,
+		Protocol:         proto,
+		ClientSubnet:     dnsInfo.ClientSubnet,
+		MessageSize:      size,
+
+		ParentProcessID:   procInfo.ParentPID,
+		ParentProcessName: parentProcessName(procInfo.ParentPID),
+		CommandLine:       procInfo.CommandLine,
+
+		TransactionID: dnsInfo.TransactionID,
+		Response:      dnsInfo.Response,
+		Rcode:         dnsInfo.Rcode,
+		Answers:       dnsInfo.Answers,
+		QueryResult:   answerAddresses(dnsInfo.Answers),
+
+		AuthenticatedData: dnsInfo.AuthenticatedData,
+		TruncatedCapture:  truncated,
+	}, len(dnsInfo.Questions), questions)
+}
+
+// 按本地端口查找套接字所属进程的缓存
+var (
+	socketOwners   = make(map[string]socketOwnerEntry)
+	socketOwnersMu sync.Mutex
+)
+
+type socketOwnerEntry struct {
+	pid     uint32
+	expires time.Time
+}
+
+// 通过 lsof 查找使用本地端口的进程，找不到时返回 0。
+// 抓包看不到发送方进程，套接字在查询结束后很快关闭，结果只能尽力而为
+func socketOwner(proto string, port uint16) uint32 {
+	key := proto + ":" + strconv.Itoa(int(port))
+	now := time.Now()
+
+	socketOwnersMu.Lock()
+	if entry, ok := socketOwners[key]; ok && now.Before(entry.expires) {
+		socketOwnersMu.Unlock()
+		return entry.pid
+	}
+	socketOwnersMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var pid uint32
+	out, err := exec.CommandContext(ctx, "lsof", "-nP", "-i", key, "-Fp").Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if v, ok := strings.CutPrefix(line, "p"); ok {
+				if n, err := strconv.ParseUint(v, 10, 32); err == nil && uint32(n) != uint32(os.Getpid()) {
+					pid = uint32(n)
+					break
+				}
+			}
+		}
+	}
+
+	socketOwnersMu.Lock()
+	defer socketOwnersMu.Unlock()
+	for k, entry := range socketOwners {
+		if now.After(entry.expires) {
+			delete(socketOwners, k)
+		}
+	}
+	socketOwners[key] = socketOwnerEntry{pid: pid, expires: now.Add(socketOwnerTTL)}
+	return pid
+}
+
+// 通过 sysctl 读取进程信息：kern.procargs2 给出可执行文件路径和参数，kern.proc.pid 给出父进程和启动时间
+func readProcessInfo(pid uint32) ProcessInfo {
+	info := ProcessInfo{
+		Name: "unknown",
+		Path: "unknown",
+	}
+	if kp, err := unix.SysctlKinfoProc("kern.proc.pid", int(pid)); err == nil {
+		info.Name = string(bytes.TrimRight(kp.Proc.P_comm[:], "\x00"))
+		info.ParentPID = uint32(kp.Eproc.Ppid)
+		info.StartTime = time.Unix(kp.Proc.P_starttime.Unix())
+	}
+
+	// 格式为 argc（int32）、可执行文件路径、填充的空字节、argc 个以空字节结尾的参数
+	if data, err := unix.SysctlRaw("kern.procargs2", int(pid)); err == nil && len(data) > 4 {
+		argc := int(binary.LittleEndian.Uint32(data))
+		path, rest, _ := bytes.Cut(data[4:], []byte{0})
+		if len(path) > 0 {
+			info.Path = string(path)
+			info.Name = filepath.Base(info.Path)
+		}
+		rest = bytes.TrimLeft(rest, "\x00")
+		args := make([]string, 0, argc)
+		for len(args) < argc && len(rest) > 0 {
+			var arg []byte
+			arg, rest, _ = bytes.Cut(rest, []byte{0})
+			args = append(args, string(arg))
+		}
+		info.CommandLine = strings.Join(args, " ")
+	}
+	return info
+}
+
+// 父进程名，读取失败时为空
+func parentProcessName(ppid uint32) string {
+	if ppid == 0 {
+		return ""
+	}
+	if name := procCache.get(ppid, readProcessInfo).Name; name != "unknown" {
+		return name
+	}
+	return ""
+}
+
+// 需要抓包的网络接口：配置的接口，未配置时为所有已启用且有地址的接口，
+// 不包含回环接口时跳过 lo0
+func captureInterfaces(cfg Config) ([]string, error) {
+	if len(cfg.Interfaces) > 0 {
+		for _, name := range cfg.Interfaces {
+			if _, err := net.InterfaceByName(name); err != nil {
+				return nil, fmt.Errorf("未知的网络接口 %q: %v", name, err)
+			}
+		}
+		return cfg.Interfaces, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || (iface.Flags&net.FlagLoopback != 0 && !cfg.IncludeLoopback) {
+			continue
+		}
+		if addrs, err := iface.Addrs(); err != nil || len(addrs) == 0 {
+			continue
+		}
+		names = append(names, iface.Name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("没有可用的网络接口")
+	}
+	return names, nil
+}
+
+// 概括抓包后端配置
+func describeBackend(cfg Config) string {
+	ports := "53"
+	for _, port := range cfg.DNSPorts {
+		ports += "," + strconv.Itoa(int(port))
+	}
+	return fmt.Sprintf("backend=BPF ports=%s interfaces=%s loopback=%t responses=%t",
+		ports, listOrAll(cfg.Interfaces), cfg.IncludeLoopback, cfg.CaptureResponses)
+}
+
+// 实现 macOS 平台 DNS 监控，记录进入处理流程；ctx 取消时停止抓包并返回 nil，初始化失败时返回错误
+func DnsFluxImpl(ctx context.Context, cfg Config) error {
+	return run(ctx, cfg, nil)
+}
+
+// 在各网络接口上抓取 DNS 报文，初始化完成后调用 started（可为 nil），
+// ctx 取消时返回 nil，所有接口都停止抓包时返回错误
+func run(ctx context.Context, cfg Config, started func()) error {
+	config = cfg
+	if err := setTimezone(config.Timezone); err != nil {
+		return err
+	}
+	configureProcessCache(config.ProcessCache)
+	if err := compileDomainFilters(config); err != nil {
+		return err
+	}
+	// 没有进程创建事件，进程树沿父进程向上查找
+	followTree = newProcessTree(config)
+
+	names, err := captureInterfaces(config)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%w: 必须以 root 权限运行此程序", ErrPermission)
+	}
+
+	// 配置了接口时任一接口失败即返回，自动选择的接口跳过不支持的链路类型
+	var taps []*bpfTap
+	for _, name := range names {
+		tap, err := openTap(name)
+		if err != nil {
+			if len(config.Interfaces) > 0 || errors.Is(err, ErrPermission) {
+				for _, t := range taps {
+					t.Close()
+				}
+				return err
+			}
+			common.Debugf("跳过网络接口 %s: %v", name, err)
+			continue
+		}
+		taps = append(taps, tap)
+	}
+	if len(taps) == 0 {
+		return fmt.Errorf("%w: 没有可以抓包的网络接口", ErrSetup)
+	}
+	if started != nil {
+		started()
+	}
+
+	var wg sync.WaitGroup
+	for _, tap := range taps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer tap.Close()
+			if err := tap.readPackets(ctx); err != nil {
+				common.Warnf("接口 %s 抓包中断: %v", tap.ifname, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		common.Infof("正在退出，已停止 %d 个接口的抓包", len(taps))
+		return nil
+	}
+	return errors.New("所有网络接口的抓包均已中断")
+}
@@ -5,293 +5,736 @@ package platform
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"dnsflux/common"
-	"dnsflux/output"
+	"dnsflux/pipeline"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
 )
 
-//go:generate sh -c "if [ \"$GOARCH\" = \"amd64\" ]; then go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel dns_bpf bpf/dnsfilter.c -- -I. -O2 -g -Wall -Werror -D__TARGET_ARCH_x86; fi"
-//go:generate sh -c "if [ \"$GOARCH\" = \"arm64\" ]; then go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel dns_bpf bpf/dnsfilter.c -- -I. -O2 -g -Wall -Werror -D__TARGET_ARCH_arm64; fi"
+//go:generate sh -c "if [ \"$GOARCH\" = \"amd64\" ]; then go run github.com/cilium/ebpf/cmd/bpf2go -no-global-types -target bpfel dns_bpf bpf/dnsfilter.c -- -I. -O2 -g -Wall -Werror -D__TARGET_ARCH_x86; fi"
+//go:generate sh -c "if [ \"$GOARCH\" = \"arm64\" ]; then go run github.com/cilium/ebpf/cmd/bpf2go -no-global-types -target bpfel dns_bpf bpf/dnsfilter.c -- -I. -O2 -g -Wall -Werror -D__TARGET_ARCH_arm64; fi"
 
-// DNS查询类型映射
-var dnsTypeMap = map[uint16]string{
-	1:  "A",
-	2:  "NS",
-	5:  "CNAME",
-	28: "AAAA",
-}
-
-// 网络协议映射
-var protocolMap = map[uint16]string{
-	6:  "TCP",
-	17: "UDP",
-}
-
-// DNS查询信息
-type DNSInfo struct {
-	QueryName string
-	QueryType uint16
-}
-
-// 进程信息
-type ProcessInfo struct {
-	Name string
-	Path string
-}
-
-// 输出格式定义
-const outputFormat = "%-19s  %-6d  %-15s  %-40s  %-4s  %-6s  %s\n"
+// 默认不输出的进程：本地缓存解析器转发的上游查询与应用查询重复
+var defaultProcessDenylist = []string{"systemd-resolve", "dnsmasq"}
 
-// 获取北京时间
-func getBeijingTime() time.Time {
-	loc, err := time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		loc = time.FixedZone("CST", 8*3600)
-	}
-	return time.Now().In(loc)
+// 获取进程信息，优先从缓存读取
+func getProcessInfo(pid uint32) ProcessInfo {
+	return procCache.get(pid, readProcessInfo)
 }
 
-// 获取进程信息
-func getProcessInfo(pid uint32) ProcessInfo {
+// 从 /proc 读取进程信息
+func readProcessInfo(pid uint32) ProcessInfo {
 	info := ProcessInfo{
 		Name: "unknown",
 		Path: "unknown",
 	}
+	info.StartTime, info.ParentPID = readProcessStat(pid)
+	container := readContainerInfo(pid)
+	info.ContainerID, info.PodUID = container.ID, container.PodUID
+	info.NetNS = netnsInode(pid)
 
 	// 获取进程名
 	if commBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
 		info.Name = strings.TrimSpace(string(commBytes))
 	}
 
+	// 获取命令行，参数以空字节分隔
+	var args []string
+	if cmdlineBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		args = strings.Split(strings.TrimRight(string(cmdlineBytes), "\x00"), "\x00")
+		info.CommandLine = strings.Join(args, " ")
+	}
+
 	// 获取进程路径
 	if exePath, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
 		info.Path = exePath
-	} else if cmdlineBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
-		args := strings.Split(string(cmdlineBytes), "\x00")
-		if len(args) > 0 && args[0] != "" {
-			info.Path = args[0]
-		}
+	} else if len(args) > 0 && args[0] != "" {
+		info.Path = args[0]
 	}
 
 	return info
 }
 
-// 解析DNS数据包
-func parseDNSPacket(data []byte) *DNSInfo {
-	if len(data) < 12 {
-		return nil
+// 父进程名，读取失败时为空
+func parentProcessName(ppid uint32) string {
+	if ppid == 0 {
+		return ""
 	}
-
-	// 检查是否是查询包（QR=0）
-	flags := binary.BigEndian.Uint16(data[2:4])
-	if (flags & 0x8000) != 0 {
-		return nil
+	if name := getProcessInfo(ppid).Name; name != "unknown" {
+		return name
 	}
+	return ""
+}
 
-	offset := 12
-	var queryName []byte
+// /proc/<pid>/stat 中时间的单位，Linux 上 USER_HZ 固定为 100
+const clockTicksPerSecond = 100
 
-	// 解析域名
-	for offset < len(data) {
-		length := int(data[offset])
-		if length == 0 {
-			break
-		}
-		if length > 63 || offset+1+length > len(data) {
-			return nil
+var (
+	bootTime     time.Time
+	bootTimeOnce sync.Once
+)
+
+// 系统启动时间，读取 /proc/stat 的 btime
+func getBootTime() time.Time {
+	bootTimeOnce.Do(func() {
+		data, err := ioutil.ReadFile("/proc/stat")
+		if err != nil {
+			return
 		}
-		if len(queryName) > 0 {
-			queryName = append(queryName, '.')
+		for _, line := range strings.Split(string(data), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "btime" {
+				if sec, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+					bootTime = time.Unix(sec, 0)
+				}
+			}
 		}
-		queryName = append(queryName, data[offset+1:offset+1+length]...)
-		offset += length + 1
+	})
+	return bootTime
+}
+
+// 从 /proc/<pid>/stat 读取进程启动时间和父进程 ID，失败时返回零值
+func readProcessStat(pid uint32) (start time.Time, ppid uint32) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, 0
 	}
 
-	// 确保有足够的数据读取类型
-	if offset+5 > len(data) {
-		return nil
+	// 进程名可能包含空格和括号，从最后一个 ) 之后开始按空白分割，
+	// ppid 为第 4 个字段，starttime 为第 22 个字段
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return time.Time{}, 0
+	}
+	if v, err := strconv.ParseUint(fields[1], 10, 32); err == nil {
+		ppid = uint32(v)
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	boot := getBootTime()
+	if err != nil || boot.IsZero() {
+		return time.Time{}, ppid
 	}
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicksPerSecond), ppid
+}
+
+// 转换连接事件中的 IPv4 地址
+// eBPF 程序对网络序地址做了 ntohl，按小端读取后最高字节即第一段
+func eventAddr(addr uint32) net.IP {
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr))
+}
 
-	offset++
-	queryType := binary.BigEndian.Uint16(data[offset:])
+// 地址族，与内核的 AF_* 对应
+const (
+	afInet  = 2
+	afInet6 = 10
+)
 
-	if len(queryName) == 0 {
-		return nil
+// 转换 DNS 事件中网络字节序的地址，IPv4 地址只占前 4 字节；
+// IPv6 套接字上的 IPv4 映射地址按 IPv4 形式输出
+func eventIP(family uint16, addr [16]byte) net.IP {
+	if family == afInet6 {
+		return net.IP(addr[:]).To16()
 	}
+	return net.IPv4(addr[0], addr[1], addr[2], addr[3])
+}
+
+// 需要监控的网络命名空间 inode，nil 表示全部
+var netnsFilter map[uint64]bool
 
-	return &DNSInfo{
-		QueryName: string(queryName),
-		QueryType: queryType,
+// 已提示过的本地解析器地址
+var (
+	localResolvers   = make(map[string]bool)
+	localResolversMu sync.Mutex
+)
+
+// 首次发现发往某个回环地址的查询时提示本机使用了本地解析器
+func noteLocalResolver(addr net.IP) {
+	localResolversMu.Lock()
+	defer localResolversMu.Unlock()
+
+	key := addr.String()
+	if localResolvers[key] {
+		return
 	}
+	localResolvers[key] = true
+	common.Infof("检测到本地 DNS 解析器 %s，已忽略发往回环地址的查询，上游解析器不可见", key)
 }
 
-// 实现 Linux 平台 DNS 监控
-func DnsFluxImpl() {
-	// 检查 root 权限
-	if os.Geteuid() != 0 {
-		log.Fatal("必须以 root 权限运行此程序")
+// ring buffer 连续读取失败达到该次数视为 eBPF 资源异常，需要重新加载
+const maxReadErrors = 16
+
+// 重新加载 eBPF 程序的退避时间范围
+const (
+	minReloadBackoff = time.Second
+	maxReloadBackoff = time.Minute
+)
+
+// 与 C 结构体 struct dns_event 完全匹配的事件结构，加载时由 checkEventLayout 核对
+type dnsEvent struct {
+	Timestamp    uint64
+	SocketCookie uint64
+	CgroupID     uint64 // 发起查询的任务所在 cgroup v2 的 ID
+	PID          uint32 // 进程 ID（tgid）
+	TID          uint32 // 线程 ID
+	UID          uint32
+	GID          uint32
+	Ifindex      uint32
+	Comm         [64]byte
+	Sport        uint16   // 本地端口，网络字节序
+	Dport        uint16   // 解析器端口，已转换为主机字节序
+	Saddr        [16]byte // 本地地址，网络字节序，见 eventIP
+	Daddr        [16]byte // 解析器地址
+	Family       uint16
+	Protocol     uint16
+	PktLen       uint16
+	Direction    uint8 // eventSend 或 eventRecv
+	_            uint8
+	OrigLen      uint32
+	PktData      [512]byte
+}
+
+// dnsEvent 的编码长度，以及 ring buffer 中每条记录的长度：C 结构体含 __u64 成员，
+// 末尾按 8 字节对齐填充
+var (
+	eventSize       = binary.Size(dnsEvent{})
+	eventRecordSize = (eventSize + 7) &^ 7
+)
+
+// errEventLayout 事件结构与 eBPF 对象不一致，重新加载也无法恢复
+var errEventLayout = errors.New("eBPF 事件结构与 Go 定义不一致")
+
+// 对照 eBPF 对象中 struct dns_event 的 BTF 检查 dnsEvent 的长度和各成员偏移，
+// 避免 C 结构体改动后字段被静默错位；对象不含该类型的 BTF 时跳过
+func checkEventLayout(spec *ebpf.CollectionSpec) error {
+	var layout *btf.Struct
+	if spec.Types == nil || spec.Types.TypeByName("dns_event", &layout) != nil {
+		common.Debugf("eBPF 对象中没有 dns_event 的 BTF 信息，跳过事件结构检查")
+		return nil
+	}
+	if int(layout.Size) != eventRecordSize {
+		return fmt.Errorf("%w: C 结构体 %d 字节，Go 结构体 %d 字节", errEventLayout, layout.Size, eventRecordSize)
 	}
 
-	// 允许当前进程锁定内存以使用 eBPF 资源
-	if err := rlimit.RemoveMemlock(); err != nil {
-		log.Fatalf("移除内存锁限制失败: %v", err)
+	goType := reflect.TypeOf(dnsEvent{})
+	var fields []reflect.StructField
+	for i := 0; i < goType.NumField(); i++ {
+		if f := goType.Field(i); f.Name != "_" {
+			fields = append(fields, f)
+		}
+	}
+	var members []btf.Member
+	for _, m := range layout.Members {
+		if !strings.HasPrefix(m.Name, "_") {
+			members = append(members, m)
+		}
+	}
+	if len(fields) != len(members) {
+		return fmt.Errorf("%w: C 结构体 %d 个成员，Go 结构体 %d 个字段", errEventLayout, len(members), len(fields))
+	}
+
+	// 按编码布局而不是 Go 的内存布局计算偏移，与 binary 解码一致
+	offsets := make(map[string]int, goType.NumField())
+	offset := 0
+	for i := 0; i < goType.NumField(); i++ {
+		f := goType.Field(i)
+		offsets[f.Name] = offset
+		offset += binary.Size(reflect.Zero(f.Type).Interface())
+	}
+	for i, m := range members {
+		if got := offsets[fields[i].Name]; got != int(m.Offset.Bytes()) {
+			return fmt.Errorf("%w: %s 在 C 结构体中偏移 %d，对应的 %s 偏移 %d", errEventLayout, m.Name, m.Offset.Bytes(), fields[i].Name, got)
+		}
+	}
+	return nil
+}
+
+// 事件方向，与 C 代码中的 DIR_* 对应
+const (
+	eventSend = 0
+	eventRecv = 1
+)
+
+// 已加载的 eBPF 对象、kprobe 挂载点和 ring buffer 读取器
+type bpfCollector struct {
+	objs struct {
+		TraceUdpSendmsg  *ebpf.Program `ebpf:"trace_udp_sendmsg"`
+		TraceUdp6Sendmsg *ebpf.Program `ebpf:"trace_udpv6_sendmsg"`
+		TraceTcpSendmsg  *ebpf.Program `ebpf:"trace_tcp_sendmsg"`
+		TraceTcpConnect  *ebpf.Program `ebpf:"trace_tcp_v4_connect"`
+		TraceUdpConnect  *ebpf.Program `ebpf:"trace_ip4_datagram_connect"`
+		TraceUdpRecv     *ebpf.Program `ebpf:"trace_udp_recvmsg"`
+		TraceUdpRecvRet  *ebpf.Program `ebpf:"trace_udp_recvmsg_ret"`
+		TraceUdp6Recv    *ebpf.Program `ebpf:"trace_udpv6_recvmsg"`
+		TraceUdp6RecvRet *ebpf.Program `ebpf:"trace_udpv6_recvmsg_ret"`
+		TraceTcpRecv     *ebpf.Program `ebpf:"trace_tcp_recvmsg"`
+		TraceTcpRecvRet  *ebpf.Program `ebpf:"trace_tcp_recvmsg_ret"`
+		Events           *ebpf.Map     `ebpf:"events"`
+		Connects         *ebpf.Map     `ebpf:"connects"`
+		FilterConfig     *ebpf.Map     `ebpf:"filter_config"`
+		IfindexFilter    *ebpf.Map     `ebpf:"ifindex_filter"`
+		DomainFilter     *ebpf.Map     `ebpf:"domain_filter"`
+		DNSPorts         *ebpf.Map     `ebpf:"dns_ports"`
+		KernelStats      *ebpf.Map     `ebpf:"kernel_stats"`
+		RecvArgs         *ebpf.Map     `ebpf:"recv_args"`
 	}
+	links  []link.Link
+	reader *ringbuf.Reader
+	// 后台读取协程，Close 等待其退出
+	wg sync.WaitGroup
+	// 连接事件读取器，未启用连接跟踪时为 nil
+	connReader *ringbuf.Reader
+}
 
-	// 加载 eBPF 程序
+// 加载 eBPF 程序、附加 kprobes 并创建 ring buffer 读取器
+func openCollector() (*bpfCollector, error) {
 	spec, err := loadDns_bpf()
 	if err != nil {
-		log.Fatalf("加载 eBPF spec 失败: %v", err)
+		return nil, fmt.Errorf("加载 eBPF spec 失败: %v", err)
+	}
+	if err := checkEventLayout(spec); err != nil {
+		return nil, err
 	}
 
-	var objs struct {
-		TraceUdpSendmsg *ebpf.Program `ebpf:"trace_udp_sendmsg"`
-		TraceTcpSendmsg *ebpf.Program `ebpf:"trace_tcp_sendmsg"`
-		Events          *ebpf.Map     `ebpf:"events"`
+	c := &bpfCollector{}
+	if err := loadObjects(spec, &c.objs); err != nil {
+		return nil, fmt.Errorf("加载 eBPF 对象失败: %v", err)
 	}
 
-	if err := spec.LoadAndAssign(&objs, nil); err != nil {
-		log.Fatalf("加载 eBPF 对象失败: %v", err)
+	// 复用的固定 map 中留有上次运行的过滤配置
+	if config.PinPath != "" {
+		if err := resetFilterMaps(c); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if err := applyInterfaceFilter(c.objs.FilterConfig, c.objs.IfindexFilter); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := applyDomainFilter(c.objs.FilterConfig, c.objs.DomainFilter); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := applyPortFilter(c.objs.FilterConfig, c.objs.DNSPorts, config.DNSPorts); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if config.DetectDoQ {
+		if err := c.objs.FilterConfig.Put(uint32(configDetectDoQ), uint32(1)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("启用 DoQ 检测失败: %v", err)
+		}
 	}
-	defer objs.Events.Close()
-	defer objs.TraceUdpSendmsg.Close()
-	defer objs.TraceTcpSendmsg.Close()
 
 	// 附加 kprobes
-	kprobes := []struct {
+	type kprobe struct {
 		name    string
-		program *ebpf.Program
-	}{
-		{"udp_sendmsg", objs.TraceUdpSendmsg},
-		{"tcp_sendmsg", objs.TraceTcpSendmsg},
+		program **ebpf.Program // 复用固定的 link 时替换为正在运行的程序
+		ret     bool           // 挂载为 kretprobe
+	}
+	kprobes := []kprobe{
+		{"udp_sendmsg", &c.objs.TraceUdpSendmsg, false},
+		{"udpv6_sendmsg", &c.objs.TraceUdp6Sendmsg, false},
+		{"tcp_sendmsg", &c.objs.TraceTcpSendmsg, false},
+	}
+	if config.TrackConnections {
+		kprobes = append(kprobes,
+			kprobe{"tcp_v4_connect", &c.objs.TraceTcpConnect, false},
+			kprobe{"ip4_datagram_connect", &c.objs.TraceUdpConnect, false})
+	}
+	if config.CaptureResponses {
+		kprobes = append(kprobes,
+			kprobe{"udp_recvmsg", &c.objs.TraceUdpRecv, false},
+			kprobe{"udp_recvmsg", &c.objs.TraceUdpRecvRet, true},
+			kprobe{"udpv6_recvmsg", &c.objs.TraceUdp6Recv, false},
+			kprobe{"udpv6_recvmsg", &c.objs.TraceUdp6RecvRet, true},
+			kprobe{"tcp_recvmsg", &c.objs.TraceTcpRecv, false},
+			kprobe{"tcp_recvmsg", &c.objs.TraceTcpRecvRet, true})
 	}
 
-	var kps []link.Link
+	pinned := make(map[string]bool, len(kprobes))
 	for _, kp := range kprobes {
-		probe, err := link.Kprobe(kp.name, kp.program, nil)
+		probe, path, err := attachKprobe(kp.name, kp.ret, kp.program)
 		if err != nil {
-			log.Fatalf("附加 kprobe %s 失败: %v", kp.name, err)
+			c.Close()
+			return nil, fmt.Errorf("附加 kprobe %s 失败: %v", kp.name, err)
+		}
+		c.links = append(c.links, probe)
+		if path != "" {
+			pinned[path] = true
 		}
-		kps = append(kps, probe)
 	}
-	defer func() {
-		for _, kp := range kps {
-			kp.Close()
+	if config.PinPath != "" {
+		if len(pinned) < len(kprobes) {
+			common.Warnf("内核不支持固定 kprobe link（需要 5.15 及以上），重启时探针会中断，仅 map 保持固定")
 		}
-	}()
+		removeStaleLinks(config.PinPath, pinned)
+	}
 
 	// 创建 ring buffer 读取器
-	rd, err := ringbuf.NewReader(objs.Events)
-	if err != nil {
-		log.Fatalf("创建 ring buffer 读取器失败: %v", err)
-	}
-	defer rd.Close()
-
-	// 读取事件
-	go func() {
-		// 定义与 C 结构体完全匹配的事件结构
-		var event struct {
-			Timestamp uint64
-			PID       uint32
-			TGID      uint32
-			UID       uint32
-			GID       uint32
-			Ifindex   uint32
-			Comm      [64]byte
-			Sport     uint16
-			Dport     uint16
-			Saddr     uint32
-			Daddr     uint32
-			Protocol  uint16
-			PktLen    uint16
-			PktData   [512]byte
+	if c.reader, err = ringbuf.NewReader(c.objs.Events); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("创建 ring buffer 读取器失败: %v", err)
+	}
+	if config.TrackConnections {
+		if c.connReader, err = ringbuf.NewReader(c.objs.Connects); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("创建连接事件读取器失败: %v", err)
 		}
+	}
 
-		for {
-			record, err := rd.Read()
-			if err != nil {
-				if err == ringbuf.ErrClosed {
-					fmt.Println("Ring buffer 已关闭")
-					return
-				}
-				continue
-			}
+	activeCollector.Store(c)
+	return c, nil
+}
 
-			if err := binary.Read(bytes.NewBuffer(record.RawSample), binary.LittleEndian, &event); err != nil {
-				continue
+// Close 释放读取器、kprobes 和 eBPF 对象
+func (c *bpfCollector) Close() {
+	activeCollector.CompareAndSwap(c, nil)
+	if c.reader != nil {
+		c.reader.Close()
+	}
+	if c.connReader != nil {
+		c.connReader.Close()
+	}
+	c.wg.Wait()
+	for _, l := range c.links {
+		l.Close()
+	}
+	for _, m := range []*ebpf.Map{c.objs.Events, c.objs.FilterConfig, c.objs.IfindexFilter, c.objs.KernelStats, c.objs.Connects, c.objs.RecvArgs, c.objs.DomainFilter, c.objs.DNSPorts} {
+		if m != nil {
+			m.Close()
+		}
+	}
+	programs := []*ebpf.Program{
+		c.objs.TraceUdpSendmsg, c.objs.TraceUdp6Sendmsg, c.objs.TraceTcpSendmsg, c.objs.TraceTcpConnect, c.objs.TraceUdpConnect,
+		c.objs.TraceUdpRecv, c.objs.TraceUdpRecvRet, c.objs.TraceUdp6Recv, c.objs.TraceUdp6RecvRet,
+		c.objs.TraceTcpRecv, c.objs.TraceTcpRecvRet,
+	}
+	for _, p := range programs {
+		if p != nil {
+			p.Close()
+		}
+	}
+}
+
+// 持续读取事件，读取器关闭时返回 nil，连续读取失败过多时返回最后一次错误，
+// 记录长度与 dnsEvent 不一致时返回 errEventLayout
+func (c *bpfCollector) readEvents() error {
+	var event dnsEvent
+	failures := 0
+
+	if c.connReader != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.readConnects()
+		}()
+	}
+
+	// 复用记录缓冲区，每条事件不再分配内存
+	var record ringbuf.Record
+	for {
+		if err := c.reader.ReadInto(&record); err != nil {
+			if errors.Is(err, ringbuf.ErrClosed) {
+				common.Debugf("Ring buffer 已关闭")
+				return nil
 			}
+			failures++
+			if failures >= maxReadErrors {
+				return err
+			}
+			continue
+		}
+		failures = 0
 
-			if event.PktLen > 0 {
-				dnsInfo := parseDNSPacket(event.PktData[:event.PktLen])
-				if dnsInfo != nil {
-					procInfo := getProcessInfo(event.PID)
-
-					// 获取协议名称
-					proto := "UNK"
-					if p, ok := protocolMap[event.Protocol]; ok {
-						proto = p
-					}
-
-					// 获取查询类型
-					qtype := fmt.Sprintf("TYPE%d", dnsInfo.QueryType)
-					if t, ok := dnsTypeMap[dnsInfo.QueryType]; ok {
-						qtype = t
-					}
-
-					// 获取当前时间
-					currentTime := getBeijingTime()
-
-					// 格式化输出内容
-					logEntry := fmt.Sprintf(outputFormat,
-						currentTime.Format("2001-02-03 04:05:06"),
-						event.PID,
-						procInfo.Name,
-						procInfo.Path,
-						proto,
-						qtype,
-						dnsInfo.QueryName,
-					)
-
-					// 控制台输出
-					fmt.Print(logEntry)
-
-					// 写入日志文件
-					if err := output.WriteLog(logEntry); err != nil {
-						log.Printf("写入日志失败: %v", err)
-					}
-
-					// 添加到 Web 展示
-					common.AddDNSRecord(common.DNSRecord{
-						Timestamp:   currentTime,
-						QueryName:   dnsInfo.QueryName,
-						QueryType:   qtype,
-						QueryResult: "-", // Linux 平台暂时没有查询结果
-						ProcessID:   event.PID,
-						ProcessName: procInfo.Name,
-						ProcessPath: procInfo.Path,
-						ClientIP: fmt.Sprintf("%d.%d.%d.%d",
-							byte(event.Saddr),
-							byte(event.Saddr>>8),
-							byte(event.Saddr>>16),
-							byte(event.Saddr>>24)),
-					})
+		if len(record.RawSample) != eventRecordSize {
+			return fmt.Errorf("%w: 记录长度 %d 字节，应为 %d 字节", errEventLayout, len(record.RawSample), eventRecordSize)
+		}
+		decodeEvent(record.RawSample, &event)
+		handleEvent(&event)
+	}
+}
 
-				}
+// 按 C 结构体的固定偏移解码事件，避免 binary.Read 每条记录的反射和内存分配。
+// 调用方需保证 b 至少有 eventSize 字节；事件由本机内核写入，按本机字节序解码
+func decodeEvent(b []byte, e *dnsEvent) {
+	ne := binary.NativeEndian
+	e.Timestamp = ne.Uint64(b[0:])
+	e.SocketCookie = ne.Uint64(b[8:])
+	e.CgroupID = ne.Uint64(b[16:])
+	e.PID = ne.Uint32(b[24:])
+	e.TID = ne.Uint32(b[28:])
+	e.UID = ne.Uint32(b[32:])
+	e.GID = ne.Uint32(b[36:])
+	e.Ifindex = ne.Uint32(b[40:])
+	copy(e.Comm[:], b[44:108])
+	e.Sport = ne.Uint16(b[108:])
+	e.Dport = ne.Uint16(b[110:])
+	copy(e.Saddr[:], b[112:128])
+	copy(e.Daddr[:], b[128:144])
+	e.Family = ne.Uint16(b[144:])
+	e.Protocol = ne.Uint16(b[146:])
+	e.PktLen = ne.Uint16(b[148:])
+	e.Direction = b[150]
+	e.OrigLen = ne.Uint32(b[152:])
+	// 只拷贝实际有效的报文数据，其余部分不会被读取
+	n := min(int(e.PktLen), len(e.PktData))
+	copy(e.PktData[:n], b[156:156+n])
+}
+
+// 解析单个事件并输出
+func handleEvent(event *dnsEvent) {
+	if pipeline.Paused() {
+		return
+	}
+	if config.DetectDoQ && isDoQEvent(event) {
+		handleDoQ(event)
+		return
+	}
+	if event.PktLen == 0 {
+		return
+	}
+
+	data, size := event.PktData[:event.PktLen], int(event.OrigLen)
+	if event.Protocol == 6 {
+		data, size = stripTCPLength(data, size)
+	}
+	dnsInfo := parseDNSPacket(data)
+	if dnsInfo == nil {
+		common.Stats.ParseErrors.Add(1)
+		return
+	}
+	// 发送路径上的响应是本机 DNS 服务端的回复，接收路径上只关心响应
+	if dnsInfo.Response != (event.Direction == eventRecv) {
+		return
+	}
+
+	// 过滤白名单和黑名单域名，报文中的问题全部被过滤时才丢弃
+	questions := unfilteredQuestions(dnsInfo.Questions)
+	if len(questions) == 0 {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	// 过滤发往回环地址的查询，并提示本机存在本地解析器
+	resolver := eventIP(event.Family, event.Daddr)
+	if !config.IncludeLoopback && resolver.IsLoopback() {
+		noteLocalResolver(resolver)
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	// 按网络接口过滤，内核已过滤绑定了接口的套接字
+	if !interfaceAllowed(event.Ifindex, resolver) {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	// 按网络命名空间过滤，命名空间 inode 随进程信息缓存，不必每个事件都读取 /proc
+	procInfo := getProcessInfo(event.PID)
+	netns := procInfo.NetNS
+	if netnsFilter != nil && !netnsFilter[netns] {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	if isProcessFiltered(event.PID, procInfo.Name, config) {
+		common.Stats.Filtered.Add(1)
+		return
+	}
+
+	container := eventContainer(procInfo, event.CgroupID)
+
+	// 获取协议名称
+	proto := "UNK"
+	if p, ok := protocolMap[event.Protocol]; ok {
+		proto = p
+	}
+
+	// 发起查询的线程名，与进程名相同时不重复记录
+	threadName := string(bytes.TrimRight(event.Comm[:], "\x00"))
+	if threadName == procInfo.Name {
+		threadName = ""
+	}
+
+	// 每个问题一条记录，提交到处理流程，再分发到各输出端
+	emitQuestions(DNSEvent{
+		Timestamp:        displayTime(time.Now()),
+		ProcessID:        event.PID,
+		ThreadID:         event.TID,
+		ThreadName:       threadName,
+		SocketCookie:     event.SocketCookie,
+		ClientSubnet:     dnsInfo.ClientSubnet,
+		MessageSize:      size,
+		ProcessName:      procInfo.Name,
+		ProcessPath:      procInfo.Path,
+		ProcessStartTime: procInfo.StartTime,
+		ClientIP:         eventIP(event.Family, event.Saddr).String(),
+		ResolverIP:       resolver.String(),
+		ResolverPort:     event.Dport,
+		Protocol:         proto,
+
+		ParentProcessID:   procInfo.ParentPID,
+		ParentProcessName: parentProcessName(procInfo.ParentPID),
+		CommandLine:       procInfo.CommandLine,
+		CgroupID:          event.CgroupID,
+		ContainerID:       container.ID,
+		PodUID:            container.PodUID,
+
+		NetNS: netns,
+
+		TransactionID: dnsInfo.TransactionID,
+		Response:      dnsInfo.Response,
+		Rcode:         dnsInfo.Rcode,
+		Answers:       dnsInfo.Answers,
+		QueryResult:   answerAddresses(dnsInfo.Answers),
+
+		AuthenticatedData: dnsInfo.AuthenticatedData,
+		TruncatedCapture:  event.OrigLen > uint32(event.PktLen),
+	}, len(dnsInfo.Questions), questions)
+}
+
+// 概括 eBPF 后端配置
+func describeBackend(cfg Config) string {
+	kprobes := "udp_sendmsg,udpv6_sendmsg,tcp_sendmsg"
+	if cfg.TrackConnections {
+		kprobes += ",tcp_v4_connect,ip4_datagram_connect"
+	}
+	if cfg.CaptureResponses {
+		kprobes += ",udp_recvmsg,udpv6_recvmsg,tcp_recvmsg"
+	}
+	ports := "53"
+	for _, port := range cfg.DNSPorts {
+		ports += "," + strconv.Itoa(int(port))
+	}
+	desc := fmt.Sprintf("backend=eBPF kprobes=%s ports=%s interfaces=%s netns=%s loopback=%t doq=%t encrypted-dns=%t",
+		kprobes, ports, listOrAll(cfg.Interfaces), listOrAll(cfg.NetNamespaces), cfg.IncludeLoopback, cfg.DetectDoQ, cfg.DetectEncryptedDNS)
+	if cfg.PinPath != "" {
+		desc += fmt.Sprintf(" pin=%s keep-pinned=%t", cfg.PinPath, cfg.KeepPinned)
+	}
+	return desc
+}
+
+// 实现 Linux 平台 DNS 监控，记录进入处理流程；ctx 取消时卸载 kprobes 并返回 nil，初始化失败时返回错误
+func DnsFluxImpl(ctx context.Context, cfg Config) error {
+	return run(ctx, cfg, nil)
+}
+
+// 加载 eBPF 程序并持续读取事件，初始化完成后调用 started（可为 nil），
+// ctx 取消时关闭读取器并返回 nil，运行中出错时退避后重新加载
+func run(ctx context.Context, cfg Config, started func()) error {
+	config = cfg
+	if err := setTimezone(config.Timezone); err != nil {
+		return err
+	}
+	configureProcessCache(config.ProcessCache)
+	if err := compileDomainFilters(config); err != nil {
+		return err
+	}
+	if followTree = newProcessTree(config); followTree != nil {
+		go watchForks(ctx, followTree)
+	}
+
+	// 解析需要监控的网络命名空间
+	var err error
+	if netnsFilter, err = resolveNetNamespaces(config.NetNamespaces); err != nil {
+		return fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	if ifindexFilter, err = resolveInterfaces(config.Interfaces); err != nil {
+		return fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	if encryptedDNS, err = newEncryptedDNSDetector(config); err != nil {
+		return err
+	}
+	if encryptedDNS != nil {
+		config.TrackConnections = true
+	}
+
+	// 检查 root 权限
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%w: 必须以 root 权限运行此程序", ErrPermission)
+	}
+
+	// 允许当前进程锁定内存以使用 eBPF 资源
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return fmt.Errorf("%w: 移除内存锁限制失败: %v", ErrSetup, err)
+	}
+
+	// 首次加载失败直接返回，运行中出错则退避后重新加载
+	collector, err := openCollector()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSetup, err)
+	}
+	if started != nil {
+		started()
+	}
+
+	// ctx 取消时关闭当前读取器，readEvents 随即返回 nil
+	stop := context.AfterFunc(ctx, func() {
+		if c := activeCollector.Load(); c != nil {
+			c.reader.Close()
+		}
+	})
+	defer stop()
+
+	backoff := minReloadBackoff
+	for {
+		readStarted := time.Now()
+		err := collector.readEvents()
+		collector.Close()
+		if ctx.Err() != nil {
+			releasePins(len(collector.links))
+			return nil
+		}
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errEventLayout) {
+			return fmt.Errorf("%w: %v", ErrSetup, err)
+		}
+
+		// 稳定运行一段时间后再出错，从最小退避时间重新开始
+		if time.Since(readStarted) > maxReloadBackoff {
+			backoff = minReloadBackoff
+		}
+
+		for {
+			common.Warnf("ring buffer 持续读取失败: %v，%s 后重新加载 eBPF 程序", err, backoff)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxReloadBackoff)
+
+			if collector, err = openCollector(); err == nil {
+				common.Infof("eBPF 程序重新加载成功")
+				break
 			}
 		}
-	}()
 
-	// 保持程序运行
-	select {}
+		// 重新加载期间 ctx 被取消，AfterFunc 可能已错过新的读取器
+		if ctx.Err() != nil {
+			collector.Close()
+			releasePins(len(collector.links))
+			return nil
+		}
+	}
 }
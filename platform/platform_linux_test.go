@@ -0,0 +1,118 @@
+//go:build linux
+
+package platform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+// 按 C 结构体 struct dns_event 的布局构造一条 ring buffer 记录
+func eventRecord() []byte {
+	b := make([]byte, eventRecordSize)
+	ne := binary.NativeEndian
+	ne.PutUint64(b[0:], 1_700_000_000_123)
+	ne.PutUint64(b[8:], 0x1122334455667788)
+	ne.PutUint64(b[16:], 4242)
+	ne.PutUint32(b[24:], 1234)
+	ne.PutUint32(b[28:], 1235)
+	ne.PutUint32(b[32:], 1000)
+	ne.PutUint32(b[36:], 1001)
+	ne.PutUint32(b[40:], 2)
+	copy(b[44:108], "curl")
+	ne.PutUint16(b[108:], 0x3930)
+	ne.PutUint16(b[110:], 53)
+	copy(b[112:128], []byte{192, 168, 1, 10})
+	copy(b[128:144], []byte{8, 8, 8, 8})
+	ne.PutUint16(b[144:], 2)
+	ne.PutUint16(b[146:], 17)
+	ne.PutUint16(b[148:], 4)
+	b[150] = eventRecv
+	ne.PutUint32(b[152:], 600)
+	copy(b[156:], []byte{0xab, 0xcd, 0x81, 0x80})
+	// 有效长度之后的内容不应被拷贝
+	b[160] = 0xff
+	return b
+}
+
+func TestDecodeEvent(t *testing.T) {
+	var got dnsEvent
+	decodeEvent(eventRecord(), &got)
+
+	want := dnsEvent{
+		Timestamp:    1_700_000_000_123,
+		SocketCookie: 0x1122334455667788,
+		CgroupID:     4242,
+		PID:          1234,
+		TID:          1235,
+		UID:          1000,
+		GID:          1001,
+		Ifindex:      2,
+		Sport:        0x3930,
+		Dport:        53,
+		Family:       2,
+		Protocol:     17,
+		PktLen:       4,
+		Direction:    eventRecv,
+		OrigLen:      600,
+	}
+	copy(want.Comm[:], "curl")
+	copy(want.Saddr[:], []byte{192, 168, 1, 10})
+	copy(want.Daddr[:], []byte{8, 8, 8, 8})
+	copy(want.PktData[:], []byte{0xab, 0xcd, 0x81, 0x80})
+
+	if got != want {
+		t.Errorf("decodeEvent() = %+v, want %+v", got, want)
+	}
+}
+
+// decodeEvent 与按结构体定义解码的结果一致（有效长度之外的报文数据除外）
+func TestDecodeEventMatchesBinaryRead(t *testing.T) {
+	record := eventRecord()
+	record[160] = 0
+
+	var fast, slow dnsEvent
+	decodeEvent(record, &fast)
+	if err := binary.Read(bytes.NewReader(record), binary.NativeEndian, &slow); err != nil {
+		t.Fatal(err)
+	}
+	if fast != slow {
+		t.Errorf("decodeEvent() = %+v, binary.Read = %+v", fast, slow)
+	}
+}
+
+// 优化前使用 binary.Read 解码，作为对照
+func BenchmarkDecodeEvent(b *testing.B) {
+	record := eventRecord()
+
+	b.Run("decodeEvent", func(b *testing.B) {
+		var e dnsEvent
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decodeEvent(record, &e)
+		}
+	})
+	b.Run("binary.Read", func(b *testing.B) {
+		var e dnsEvent
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := binary.Read(bytes.NewReader(record), binary.NativeEndian, &e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// 网络命名空间随进程信息读取，缓存命中时无需再读取 /proc/<pid>/ns/net
+func TestReadProcessInfoNetNS(t *testing.T) {
+	pid := uint32(os.Getpid())
+	want := netnsInode(pid)
+	if want == 0 {
+		t.Skip("无法读取 /proc/self/ns/net")
+	}
+	if got := readProcessInfo(pid).NetNS; got != want {
+		t.Errorf("readProcessInfo(%d).NetNS = %d, want %d", pid, got, want)
+	}
+}
@@ -4,9 +4,11 @@ package platform
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"regexp"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -14,14 +16,20 @@ import (
 	"unsafe"
 
 	"dnsflux/common"
-	"dnsflux/output"
+	"dnsflux/pipeline"
 
 	"github.com/0xrawsec/golang-etw/etw"
+	"golang.org/x/sys/windows"
 )
 
 const (
 	// Microsoft-Windows-DNS-Client Provider GUID
 	dnsProviderGUID = "{1C95126E-7EEA-49A9-A3FE-A378B03DDB4D}"
+	// Microsoft-Windows-Kernel-Process Provider GUID，跟随进程树时启用以获取进程创建事件
+	kernelProcessGUID = "{22FB2CD6-0E7B-422B-A0C7-2FAD1FD0E716}"
+	// Kernel-Process 的 WINEVENT_KEYWORD_PROCESS 关键字及进程创建事件 ID
+	kernelProcessKeyword = 0x10
+	eventProcessStart    = 1
 
 	// 进程访问权限
 	PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
@@ -36,18 +44,8 @@ var (
 	procGetProcessImageFileNameW   = modpsapi.NewProc("GetProcessImageFileNameW")
 )
 
-// DNS查询类型映射
-var queryTypes = map[int]string{
-	1:  "A",
-	2:  "NS",
-	5:  "CNAME",
-	6:  "SOA",
-	12: "PTR",
-	15: "MX",
-	16: "TXT",
-	28: "AAAA",
-	33: "SRV",
-}
+// 默认不输出的进程：svchost 承载的系统服务产生大量后台遥测和更新查询
+var defaultProcessDenylist = []string{"svchost.exe"}
 
 // DNS查询状态码映射
 var statusMap = map[int]string{
@@ -58,29 +56,6 @@ var statusMap = map[int]string{
 	9501: "ERROR(query record not found)",
 }
 
-// IP地址匹配
-var (
-	// IPv4 地址模式
-	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
-
-	// IPv6 地址模式 (包括压缩格式)
-	ipv6Pattern = regexp.MustCompile(`(?i)\b(?:(?:[0-9A-F]{1,4}:){7}[0-9A-F]{1,4}|(?:[0-9A-F]{1,4}:){6}:[0-9A-F]{1,4}|(?:[0-9A-F]{1,4}:){5}(?::[0-9A-F]{1,4}){1,2}|(?:[0-9A-F]{1,4}:){4}(?::[0-9A-F]{1,4}){1,3}|(?:[0-9A-F]{1,4}:){3}(?::[0-9A-F]{1,4}){1,4}|(?:[0-9A-F]{1,4}:){2}(?::[0-9A-F]{1,4}){1,5}|[0-9A-F]{1,4}:(?::[0-9A-F]{1,4}){1,6}|:(?:(?::[0-9A-F]{1,4}){1,7}|:)|FE80:(?::[0-9A-F]{0,4}){0,4}%[0-9a-zA-Z]{1,}|::(?:FFFF(?::0{1,4}){0,1}:){0,1}(?:(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9])|(?:[0-9A-F]{1,4}:){1,4}:(?:(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9])\.){3,3}(?:25[0-5]|(?:2[0-4]|1{0,1}[0-9]){0,1}[0-9]))\b`)
-)
-
-type Config struct {
-	// 事件ID白名单，为空则不过滤
-	EventIDWhitelist []uint16
-	// 域名黑名单，为空则不过滤
-	DomainBlacklist []string
-}
-
-// 配置事件白名单ID和域名黑名单
-var config = Config{
-	// DNS查询事件ID：3006【开始查询】，3008【已完成的查询】，3009【发起索引查询】，3010【发起DNS服务查询】，3011【DNS服务器响应】，3018【缓存查询响应】，3020【索引查询响应】
-	EventIDWhitelist: []uint16{3008},
-	DomainBlacklist:  []string{"localhost"},
-}
-
 // 检查事件ID是否在白名单中
 func isEventIDAllowed(eventID uint16, whitelist []uint16) bool {
 	if len(whitelist) == 0 {
@@ -94,20 +69,6 @@ func isEventIDAllowed(eventID uint16, whitelist []uint16) bool {
 	return false
 }
 
-// 检查域名是否在黑名单中
-func isDomainBlocked(domain string, blacklist []string) bool {
-	if len(blacklist) == 0 {
-		return false
-	}
-	domain = strings.ToLower(domain)
-	for _, blocked := range blacklist {
-		if strings.Contains(domain, strings.ToLower(blocked)) {
-			return true
-		}
-	}
-	return false
-}
-
 // 获取进程路径
 func getProcessPath(processHandle syscall.Handle) string {
 	// 创建缓冲区来存储路径信息
@@ -135,7 +96,7 @@ func getProcessPath(processHandle syscall.Handle) string {
 		return syscall.UTF16ToString(buffer[:size])
 	}
 
-	log.Printf("无法获取进程路径, 错误: %v", err)
+	common.Debugf("无法获取进程路径, 错误: %v", err)
 	// 如果都失败，返回空字符串
 	return ""
 }
@@ -150,265 +111,595 @@ func getProcessName(processPath string) string {
 	return processPath
 }
 
-// 获取进程信息
+// 获取进程信息，优先从缓存读取
 func getProcessInfo(pid uint32) (name, path string) {
+	info := procCache.get(pid, readProcessInfo)
+	return info.Name, info.Path
+}
+
+// 父进程名，无法打开父进程时为空
+func parentProcessName(ppid uint32) string {
+	if ppid == 0 {
+		return ""
+	}
+	name, path := getProcessInfo(ppid)
+	if path == "" {
+		return ""
+	}
+	return name
+}
+
+// 命令行的最大读取长度，UNICODE_STRING 的长度字段为 16 位
+const maxCommandLineBuffer = 64 * 1024
+
+// 读取父进程 ID 和命令行，权限不足时保持零值
+func readProcessDetails(handle windows.Handle, info *ProcessInfo) {
+	var basic windows.PROCESS_BASIC_INFORMATION
+	if err := windows.NtQueryInformationProcess(handle, windows.ProcessBasicInformation,
+		unsafe.Pointer(&basic), uint32(unsafe.Sizeof(basic)), nil); err == nil {
+		info.ParentPID = uint32(basic.InheritedFromUniqueProcessId)
+	}
+
+	// ProcessCommandLineInformation 返回 UNICODE_STRING 及其后的字符数据（Windows 8.1+）
+	buf := make([]byte, 1024)
+	for {
+		var needed uint32
+		err := windows.NtQueryInformationProcess(handle, windows.ProcessCommandLineInformation,
+			unsafe.Pointer(&buf[0]), uint32(len(buf)), &needed)
+		if err == windows.STATUS_INFO_LENGTH_MISMATCH && int(needed) > len(buf) && needed <= maxCommandLineBuffer {
+			buf = make([]byte, needed)
+			continue
+		}
+		if err == nil {
+			info.CommandLine = (*windows.NTUnicodeString)(unsafe.Pointer(&buf[0])).String()
+		}
+		return
+	}
+}
+
+// 打开进程读取路径和启动时间
+func readProcessInfo(pid uint32) ProcessInfo {
 	// 使用 PROCESS_QUERY_LIMITED_INFORMATION 权限
 	handle, err := syscall.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
 	if err != nil {
-		log.Printf("无法打开进程 %d: %v", pid, err)
+		common.Debugf("无法打开进程 %d: %v", pid, err)
 		// 返回默认值或空值
-		return fmt.Sprintf("PID: %d", pid), ""
+		return ProcessInfo{Name: fmt.Sprintf("PID: %d", pid)}
 	}
 	defer syscall.CloseHandle(handle)
 
-	// 获取路径信息
-	path = getProcessPath(handle)
-	if path == "" {
-		return fmt.Sprintf("PID: %d", pid), ""
+	info := ProcessInfo{Name: fmt.Sprintf("PID: %d", pid)}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err == nil {
+		info.StartTime = time.Unix(0, creation.Nanoseconds())
 	}
 
-	// 获取进程名称
-	name = getProcessName(path)
-	return name, path
+	// 获取路径信息
+	if path := getProcessPath(handle); path != "" {
+		info.Path = path
+		// 获取进程名称
+		info.Name = getProcessName(path)
+	}
+	readProcessDetails(windows.Handle(handle), &info)
+	return info
 }
 
 // 获取DNS查询类型的字符串表示
 func getDNSQueryType(queryType interface{}) string {
 	switch t := queryType.(type) {
 	case float64:
-		if name, ok := queryTypes[int(t)]; ok {
-			return name
-		}
-		return fmt.Sprintf("UNKNOWN(%d)", int(t))
+		return queryTypeName(int(t))
 	case int:
-		if name, ok := queryTypes[t]; ok {
-			return name
-		}
-		return fmt.Sprintf("UNKNOWN(%d)", t)
+		return queryTypeName(t)
 	case string:
 		tInt, err := strconv.Atoi(t)
 		if err != nil {
 			return fmt.Sprintf("UNKNOWN(%s)", t)
 		}
-		if name, ok := queryTypes[tInt]; ok {
-			return name
-		}
-		return fmt.Sprintf("UNKNOWN(%d)", tInt)
+		return queryTypeName(tInt)
 	default:
 		return fmt.Sprintf("%v", queryType)
 	}
 }
 
-// 获取DNS查询状态的字符串表示
+// 查询类型名称，未知类型为 UNKNOWN(n)
+func queryTypeName(t int) string {
+	if name, ok := qtypeNames[uint16(t)]; ok && t >= 0 && t <= 0xFFFF {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", t)
+}
+
+// 获取DNS查询状态的字符串表示，无法识别为状态码时返回空字符串
 func getDNSStatus(status interface{}) string {
+	var code int
 	switch s := status.(type) {
 	case float64:
-		if statusStr, ok := statusMap[int(s)]; ok {
-			return statusStr
-		}
-		return fmt.Sprintf("ERROR(%d)", int(s))
+		code = int(s)
 	case int:
-		if statusStr, ok := statusMap[s]; ok {
-			return statusStr
-		}
-		return fmt.Sprintf("ERROR(%d)", s)
-	case string:
-		sInt, err := strconv.Atoi(s)
-		if err != nil {
+		code = s
+	default:
+		var err error
+		if code, err = strconv.Atoi(strings.TrimSpace(fmt.Sprintf("%v", status))); err != nil {
 			return ""
 		}
-		if statusStr, ok := statusMap[sInt]; ok {
-			return statusStr
-		}
-		return fmt.Sprintf("ERROR(%d)", sInt)
-	default:
-		return fmt.Sprintf("%v", status)
 	}
+	if statusStr, ok := statusMap[code]; ok {
+		return statusStr
+	}
+	return fmt.Sprintf("ERROR(%d)", code)
 }
 
-// 提取查询结果中的 IP 地址
-func extractIPs(result string) (ipv4s []string, ipv6s []string) {
-	// 提取所有 IPv4 地址
-	ipv4s = ipv4Pattern.FindAllString(result, -1)
-
-	// 提取所有 IPv6 地址
-	ipv6s = ipv6Pattern.FindAllString(result, -1)
-
-	// 去重
-	ipv4s = removeDuplicates(ipv4s)
-	ipv6s = removeDuplicates(ipv6s)
+// 事件中的查询状态和响应码，依次读取 QueryStatus、Status 和 ResponseStatus（3011 事件），
+// 后面的字段只在存在且能识别为状态码时覆盖前面的结果
+func eventStatus(data map[string]interface{}) (status, rcode string) {
+	for _, field := range []string{"QueryStatus", "Status", "ResponseStatus"} {
+		r, ok := data[field]
+		if !ok {
+			continue
+		}
+		if s := getDNSStatus(r); s != "" {
+			status, rcode = s, getDNSRcode(r)
+		}
+	}
+	return status, rcode
+}
 
-	return
+// Windows DNS 错误码与标准响应码的对应关系，DNS_ERROR_RCODE_* 为 9000 + RCODE
+var windowsRcodes = map[int]uint16{
+	0:    0, // ERROR_SUCCESS
+	9501: 0, // DNS_INFO_NO_RECORDS，名称存在但没有该类型的记录
 }
 
-// 去重函数
-func removeDuplicates(arr []string) []string {
-	seen := make(map[string]bool)
-	result := []string{}
+// 将查询状态转换为标准响应码名称，超时等非 DNS 协议层面的错误返回空字符串
+func getDNSRcode(status interface{}) string {
+	code, err := strconv.Atoi(fmt.Sprintf("%v", status))
+	if err != nil {
+		return ""
+	}
+	if rcode, ok := windowsRcodes[code]; ok {
+		return rcodeName(rcode)
+	}
+	if code > 9000 && code <= 9000+0x0F {
+		return rcodeName(uint16(code - 9000))
+	}
+	return ""
+}
 
-	for _, item := range arr {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
+// 解析 QueryResults 中的应答记录，格式为以分号分隔的条目：
+// 地址直接给出（IPv4 多为 ::ffff: 映射形式），其他记录为 "type: 5 target.example.com"。
+// ETW 不提供 TTL，记录的所有者按 CNAME 链从查询域名依次推得
+func parseQueryResults(queryName, results string) []common.Answer {
+	var answers []common.Answer
+	owner := queryName
+	for _, item := range strings.Split(results, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(item, "type:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) < 2 {
+				continue
+			}
+			rrtype, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+			answer := common.Answer{Name: owner, Type: fmt.Sprintf("TYPE%d", rrtype), Data: strings.Join(fields[1:], " ")}
+			if name, ok := qtypeNames[uint16(rrtype)]; ok {
+				answer.Type = name
+			}
+			if rrtype == typeCNAME {
+				owner = answer.Data
+			}
+			answers = append(answers, answer)
+			continue
+		}
+		ip := net.ParseIP(item)
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			answers = append(answers, common.Answer{Name: owner, Type: "A", Data: ip4.String()})
+		} else {
+			answers = append(answers, common.Answer{Name: owner, Type: "AAAA", Data: ip.String()})
 		}
 	}
-
-	return result
+	return answers
 }
 
-// 提取并格式化 IP 地址结果
-func formatDNSResult(result string) string {
-	ipv4s, ipv6s := extractIPs(result)
+// 应答记录的单行表示，如 CNAME a.example.net, A 93.184.216.34
+func formatAnswers(answers []common.Answer) string {
+	parts := make([]string, 0, len(answers))
+	for _, answer := range answers {
+		part := answer.Type + " " + answer.Data
+		if answer.PTR != "" {
+			part += " (" + answer.PTR + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
 
-	// 优先使用 IPv4 地址
-	if len(ipv4s) > 0 {
-		if len(ipv4s) == 1 {
-			return ipv4s[0]
+// 由解析出的应答记录得到查询结果：去重后的地址，有 IPv4 地址时只取 IPv4。
+// 地址只取自 A/AAAA 应答，CNAME 目标域名中形似地址的部分不会被误认
+func formatDNSResult(answers []common.Answer) string {
+	var ipv4s, ipv6s []string
+	for _, answer := range answers {
+		switch answer.Type {
+		case "A":
+			if !slices.Contains(ipv4s, answer.Data) {
+				ipv4s = append(ipv4s, answer.Data)
+			}
+		case "AAAA":
+			if !slices.Contains(ipv6s, answer.Data) {
+				ipv6s = append(ipv6s, answer.Data)
+			}
 		}
+	}
+	if len(ipv4s) > 0 {
 		return strings.Join(ipv4s, ", ")
 	}
+	return strings.Join(ipv6s, ", ")
+}
 
-	// 如果没有 IPv4 地址，则使用 IPv6 地址
-	if len(ipv6s) > 0 {
-		if len(ipv6s) == 1 {
-			return ipv6s[0]
-		}
-		return strings.Join(ipv6s, ", ")
+// FormatRecord 将记录格式化为多行文本
+func FormatRecord(record common.DNSRecord) string {
+	result := record.QueryResult
+	if record.PreviousResult != "" {
+		result = fmt.Sprintf("%s（原结果: %s）", result, record.PreviousResult)
 	}
 
-	return ""
+	notes := ""
+	if len(record.ResolutionPath) > 0 {
+		notes += fmt.Sprintf("解析路径: %s\n", strings.Join(record.ResolutionPath, " -> "))
+	}
+	if len(record.Answers) > 0 {
+		notes += fmt.Sprintf("应答记录: %s\n", formatAnswers(record.Answers))
+	}
+	if record.ResolverIP != "" {
+		notes += fmt.Sprintf("DNS服务器: %s\n", record.ResolverIP)
+	}
+	switch record.AnswerSource {
+	case "cache":
+		notes += "应答来源: 本机缓存\n"
+	case "server":
+		notes += "应答来源: DNS服务器\n"
+	}
+	if record.LatencyMs > 0 {
+		notes += fmt.Sprintf("耗时: %.1fms\n", record.LatencyMs)
+	}
+	name := record.QueryName
+	if record.QueryNameUnicode != "" {
+		name += " (" + record.QueryNameUnicode + ")"
+	}
+	for _, note := range record.Notes {
+		notes += fmt.Sprintf("备注: %s\n", note)
+	}
+
+	return fmt.Sprintf("\n检测到DNS查询:\n时间: %s\n查询域名: %s\n查询类型: %s\n查询状态: %s\n响应码: %s\n查询结果: %s\n进程ID: %d\n线程ID: %d\n进程名: %s\n进程路径: %s\n事件ID: %d\n%s------------------------\n",
+		record.Timestamp.Format("2006-01-02 15:04:05"),
+		name,
+		record.QueryType,
+		record.Status,
+		record.Rcode,
+		result,
+		record.ProcessID,
+		record.ThreadID,
+		record.ProcessName,
+		record.ProcessPath,
+		record.EventID,
+		notes,
+	)
 }
 
-// 格式化为北京时间
-func formatTimeAsBeijing(t time.Time) time.Time {
-	// 设置时区为北京
-	const beijingTimeZone = "Asia/Shanghai"
-	loc, err := time.LoadLocation(beijingTimeZone)
-	if err != nil {
-		return t // 如果加载时区失败，返回原始时间
+// 将 Windows 错误归类，拒绝访问视为权限不足
+func classifyError(err error) error {
+	if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		return ErrPermission
 	}
+	return ErrSetup
+}
 
-	// 将时间转换为北京时间并返回
-	return t.In(loc)
+// 概括 ETW 后端配置
+func describeBackend(cfg Config) string {
+	events := make([]string, 0, len(cfg.EventIDWhitelist))
+	for _, id := range cfg.EventIDWhitelist {
+		events = append(events, strconv.Itoa(int(id)))
+	}
+	providers := cfg.Providers
+	if len(providers) == 0 {
+		providers = []string{dnsProviderGUID}
+	}
+	return fmt.Sprintf("backend=ETW session=%s provider=%s level=%d keywords=%#x/%#x events=%s",
+		cfg.SessionName, strings.Join(providers, ","), cfg.Provider.Level, cfg.Provider.MatchAnyKeyword, cfg.Provider.MatchAllKeyword, listOrAll(events))
 }
 
-// 实现 Windows 平台 DNS 监控
-func DnsFluxImpl() {
-	// 创建实时会话
-	session := etw.NewRealTimeSession("DNSMonitor")
-	defer session.Stop()
+// 实现 Windows 平台 DNS 监控，记录进入处理流程；ctx 取消时停止 ETW 会话并返回 nil，会话结束或出错时返回
+func DnsFluxImpl(ctx context.Context, cfg Config) error {
+	return run(ctx, cfg, nil)
+}
+
+// 启用 DNS Provider 并消费事件，初始化完成后调用 started（可为 nil），ctx 取消或会话停止时返回
+func run(ctx context.Context, cfg Config, started func()) error {
+	config = cfg
+	if err := setTimezone(config.Timezone); err != nil {
+		return err
+	}
+	configureProcessCache(config.ProcessCache)
+	if err := compileDomainFilters(config); err != nil {
+		return err
+	}
+
+	if config.TrackConnections {
+		common.Warnf("Windows 平台暂不支持采集连接事件，解析后连接的关联不会产生事件")
+	}
+
+	// 先解析全部 Provider，名称无法识别时不创建会话
+	names := config.Providers
+	if len(names) == 0 {
+		names = []string{dnsProviderGUID}
+	}
+	providers := make([]etw.Provider, 0, len(names))
+	for _, name := range names {
+		provider, err := resolveProvider(name)
+		if err != nil {
+			return err
+		}
+		provider.EnableLevel = config.Provider.Level
+		provider.MatchAnyKeyword = config.Provider.MatchAnyKeyword
+		provider.MatchAllKeyword = config.Provider.MatchAllKeyword
+		providers = append(providers, provider)
+	}
+	if followTree = newProcessTree(config); followTree != nil {
+		providers = append(providers, etw.Provider{
+			GUID:            kernelProcessGUID,
+			EnableLevel:     0xff,
+			MatchAnyKeyword: kernelProcessKeyword,
+		})
+	}
 
-	// 解析并启用 DNS Provider
-	dnsProvider := etw.MustParseProvider(dnsProviderGUID)
-	if err := session.EnableProvider(dnsProvider); err != nil {
-		log.Fatalf("启用 Provider 失败: %v", err)
+	// 创建实时会话并启用全部 Provider
+	sessionName := config.SessionName
+	if sessionName == "" {
+		sessionName = defaultSessionName
+	}
+	session := newTunedSession(sessionName, config.SessionBuffers)
+	defer session.Stop()
+	for _, provider := range providers {
+		if err := session.EnableProvider(provider); err != nil {
+			return fmt.Errorf("%w: 启用 Provider %s 失败: %v", classifyError(err), provider.GUID, err)
+		}
 	}
-	fmt.Println("DNS Provider 启用成功")
 
-	// 创建消费者并启动异步监听
-	ctx, cancel := context.WithCancel(context.Background())
+	// 创建消费者并启动异步监听，ctx 取消时停止消费者，返回前等待停止完成
+	ctx, cancel := context.WithCancel(ctx)
 	consumer := etw.NewRealTimeConsumer(ctx)
-	defer cancel()
-	defer consumer.Stop()
+	stopped := make(chan struct{})
+	handled := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		consumer.Stop()
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+		<-handled
+	}()
 
 	// 将消费者与会话关联
 	consumer.FromSessions(session)
 
-	// 处理事件
+	// 处理事件，同一次查询的事件在 lookupWindow 内合并，消费者停止后输出未合并的事件
+	lookups := newLookupTable()
 	go func() {
-		for evt := range consumer.Events {
-			handleProcessEvent(evt)
+		defer close(handled)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case evt, ok := <-consumer.Events:
+				if !ok {
+					lookups.flushAll()
+					return
+				}
+				handleProcessEvent(evt, lookups)
+			case now := <-ticker.C:
+				lookups.expire(now)
+			}
 		}
 	}()
 
 	// 启动消费者
-	errChan := make(chan error, 1)
-	go func() {
-		if err := consumer.Start(); err != nil {
-			errChan <- fmt.Errorf("DNS事件消费者启动失败: %v", err)
-		}
-	}()
+	if err := consumer.Start(); err != nil {
+		return fmt.Errorf("%w: DNS事件消费者启动失败: %v", classifyError(err), err)
+	}
+	if started != nil {
+		started()
+	}
 
-	<-ctx.Done()
+	// ProcessTrace 返回说明会话已停止
+	consumer.Wait()
+	if ctx.Err() != nil {
+		common.Infof("正在退出，停止 ETW 会话")
+		return nil
+	}
+	if err := consumer.Err(); err != nil {
+		return fmt.Errorf("ETW 事件处理中断: %v", err)
+	}
+	return nil
 }
 
-func handleProcessEvent(evt *etw.Event) {
-	if evt.System.Provider.Guid == dnsProviderGUID {
-		// 过滤白名单事件
-		if !isEventIDAllowed(evt.System.EventID, config.EventIDWhitelist) {
-			return
-		}
+// 解析 Provider 的 GUID 或注册名称，GUID 无需在本机注册清单即可启用
+func resolveProvider(name string) (etw.Provider, error) {
+	if guid, err := etw.ParseGUID(name); err == nil {
+		return etw.Provider{GUID: guid.String()}, nil
+	}
+	provider := etw.ResolveProvider(name)
+	if provider.IsZero() {
+		return provider, fmt.Errorf("%w: 未找到 ETW Provider %q，可用 logman query providers 查看已注册的名称", ErrConfig, name)
+	}
+	return provider, nil
+}
 
-		queryName, hasQuery := evt.EventData["QueryName"]
-		if !hasQuery {
-			return
+// 按顺序返回第一个存在的事件字段，DNS-Client 与 DNS Server 等 Provider 的字段名不同
+func eventField(data map[string]interface{}, names ...string) (interface{}, bool) {
+	for _, name := range names {
+		if value, ok := data[name]; ok {
+			return value, true
 		}
+	}
+	return nil, false
+}
 
-		// 过滤黑名单域名
-		if isDomainBlocked(fmt.Sprintf("%v", queryName), config.DomainBlacklist) {
-			return
-		}
+// 解析单个 ETW 事件，3008 在事件 ID 白名单中时 DNS-Client 事件交给 lookups 合并后输出
+func handleProcessEvent(evt *etw.Event, lookups *lookupTable) {
+	if evt.System.Provider.Guid == kernelProcessGUID {
+		handleKernelProcessEvent(evt)
+		return
+	}
+	if pipeline.Paused() {
+		return
+	}
+	// 事件 ID 白名单只适用于 DNS-Client，其他 Provider 的事件 ID 含义不同
+	if evt.System.Provider.Guid == dnsProviderGUID && !isEventIDAllowed(evt.System.EventID, config.EventIDWhitelist) {
+		common.Stats.Filtered.Add(1)
+		debugEvent(evt, "事件 ID 不在白名单中")
+		return
+	}
 
-		queryType := getDNSQueryType(evt.EventData["QueryType"])
+	queryName, hasQuery := eventField(evt.EventData, "QueryName", "QNAME")
+	if !hasQuery {
+		common.Stats.Dropped.Add(1)
+		common.Stats.ParseErrors.Add(1)
+		debugEvent(evt, "缺少 QueryName 字段")
+		return
+	}
+	if _, ok := queryName.(string); !ok {
+		debugEvent(evt, fmt.Sprintf("QueryName 字段类型为 %T", queryName))
+	}
 
-		result := ""
-		if r, ok := evt.EventData["QueryResults"]; ok {
-			result = formatDNSResult(fmt.Sprintf("%v", r))
-		}
+	// 过滤白名单和黑名单域名
+	if isDomainFiltered(fmt.Sprintf("%v", queryName)) {
+		common.Stats.Filtered.Add(1)
+		debugEvent(evt, "域名被白名单或黑名单过滤")
+		return
+	}
 
-		status := ""
-		if r, ok := evt.EventData["QueryStatus"]; ok {
-			status = getDNSStatus(r)
-		}
-		if r, ok := evt.EventData["Status"]; ok {
-			status = getDNSStatus(r)
-		}
+	qtype, _ := eventField(evt.EventData, "QueryType", "QTYPE")
+	queryType := getDNSQueryType(qtype)
+	switch qtype.(type) {
+	case string, float64, int, nil:
+	default:
+		debugEvent(evt, fmt.Sprintf("QueryType 字段类型为 %T", qtype))
+	}
+
+	result := ""
+	var answers []common.Answer
+	if r, ok := evt.EventData["QueryResults"]; ok {
+		answers = parseQueryResults(fmt.Sprintf("%v", queryName), fmt.Sprintf("%v", r))
+		result = formatDNSResult(answers)
+	}
 
-		processId := evt.System.Execution.ProcessID
-		threadId := evt.System.Execution.ThreadID
-		processName, processPath := getProcessInfo(processId)
-
-		beijingTime := formatTimeAsBeijing(evt.System.TimeCreated.SystemTime)
-		timestamp := beijingTime.Format("2001-02-03 04:05:06")
-
-		// 格式化输出内容
-		logEntry := fmt.Sprintf("\n检测到DNS查询:\n时间: %s\n查询域名: %s\n查询类型: %s\n查询状态: %s\n查询结果: %s\n进程ID: %d\n线程ID: %d\n进程名: %s\n进程路径: %s\n事件ID: %d\n------------------------\n",
-			timestamp,
-			queryName,
-			queryType,
-			status,
-			result,
-			processId,
-			threadId,
-			processName,
-			processPath,
-			evt.System.EventID,
-		)
-
-		// 控制台输出
-		fmt.Print(logEntry)
-
-		//// 调试用：打印完整事件数据
-		//if data, err := json.MarshalIndent(evt, "", "  "); err == nil {
-		//	fmt.Printf("调试信息 - 完整事件数据:\n%s\n", string(data))
-		//}
-
-		// 写入日志文件
-		if err := output.WriteLog(logEntry); err != nil {
-			log.Printf("写入日志失败: %v", err)
+	status, rcode := eventStatus(evt.EventData)
+	// DNS Server 的响应事件直接给出响应码
+	if r, ok := evt.EventData["RCODE"]; ok && rcode == "" {
+		if code, err := strconv.Atoi(fmt.Sprintf("%v", r)); err == nil {
+			rcode = rcodeName(uint16(code))
+		} else {
+			debugEvent(evt, fmt.Sprintf("无法解析 RCODE 字段 %v", r))
 		}
+	}
 
-		// 添加到 Web 展示
-		common.AddDNSRecord(common.DNSRecord{
-			Timestamp:   beijingTime,
-			QueryName:   fmt.Sprintf("%v", queryName),
-			QueryType:   queryType,
-			QueryResult: result,
-			ProcessID:   processId,
-			ProcessName: processName,
-			ProcessPath: processPath,
-			ClientIP:    "-", // Windows ETW 事件中可能没有客户端 IP
-		})
+	// DNS Server 事件中的查询来源或响应目标即客户端地址
+	client := ""
+	if c, ok := eventField(evt.EventData, "Source", "Destination"); ok {
+		client = fmt.Sprintf("%v", c)
+	}
+
+	// 3011 事件给出应答所来自的 DNS 服务器
+	resolver := ""
+	if r, ok := evt.EventData["DnsServerIpAddress"]; ok {
+		resolver = fmt.Sprintf("%v", r)
+	}
 
+	processId := evt.System.Execution.ProcessID
+	threadId := evt.System.Execution.ThreadID
+	procInfo := procCache.get(processId, readProcessInfo)
+	processName, processPath := procInfo.Name, procInfo.Path
+	if isProcessFiltered(processId, processName, config) {
+		common.Stats.Filtered.Add(1)
+		debugEvent(evt, fmt.Sprintf("进程 %s(%d) 被过滤", processName, processId))
+		return
+	}
+
+	event := DNSEvent{
+		Timestamp:         displayTime(evt.System.TimeCreated.SystemTime),
+		QueryName:         fmt.Sprintf("%v", queryName),
+		QueryType:         queryType,
+		QueryResult:       result,
+		ProcessID:         processId,
+		ProcessName:       processName,
+		ProcessPath:       processPath,
+		ClientIP:          client,
+		ProcessStartTime:  procInfo.StartTime,
+		ParentProcessID:   procInfo.ParentPID,
+		ParentProcessName: parentProcessName(procInfo.ParentPID),
+		CommandLine:       procInfo.CommandLine,
+		Status:            status,
+		Rcode:             rcode,
+		ThreadID:          threadId,
+		EventID:           evt.System.EventID,
+		ResolverIP:        resolver,
+		Response:          evt.System.Provider.Guid == dnsProviderGUID && evt.System.EventID == 3011,
+		Answers:           answers,
+	}
+
+	// 提交到处理流程，再分发到各输出端
+	if evt.System.Provider.Guid == dnsProviderGUID && isEventIDAllowed(eventQueryCompleted, config.EventIDWhitelist) {
+		lookups.add(event, time.Now())
+		return
+	}
+	emit(event)
+}
+
+// 在 debug 级别输出被丢弃或字段异常的事件及原因，附带原始 EventData，用于排查查询未出现的原因
+func debugEvent(evt *etw.Event, reason string) {
+	if !common.DebugEnabled() {
+		return
+	}
+	data, err := json.Marshal(evt.EventData)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%v", evt.EventData))
+	}
+	common.Debugf("ETW 事件 %s/%d: %s，EventData: %s", evt.System.Provider.Guid, evt.System.EventID, reason, data)
+}
+
+// 用进程创建事件维护跟随的进程树
+func handleKernelProcessEvent(evt *etw.Event) {
+	if followTree == nil || evt.System.EventID != eventProcessStart {
+		return
+	}
+	pid, ok := eventUint32(evt.EventData, "ProcessID")
+	if !ok {
+		return
+	}
+	if ppid, ok := eventUint32(evt.EventData, "ParentProcessID"); ok {
+		followTree.forked(ppid, pid)
+	}
+}
+
+// 读取整数类型的事件字段，兼容十进制和 0x 开头的十六进制文本
+func eventUint32(data map[string]interface{}, name string) (uint32, bool) {
+	value, ok := data[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(fmt.Sprintf("%v", value), 0, 32)
+	if err != nil {
+		return 0, false
 	}
+	return uint32(n), true
 }
@@ -0,0 +1,132 @@
+//go:build windows
+
+package platform
+
+import (
+	"reflect"
+	"testing"
+
+	"dnsflux/common"
+)
+
+func TestParseQueryResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		results string
+		want    []common.Answer
+	}{
+		{
+			// 地址记录的所有者为 CNAME 链末端的域名
+			name:    "cname chain then addresses",
+			query:   "www.example.com",
+			results: "type: 5 edge.example.net;type: 5 a1.edge.example.net;::ffff:93.184.216.34;::ffff:93.184.216.35;2606:2800:220:1::1;",
+			want: []common.Answer{
+				{Name: "www.example.com", Type: "CNAME", Data: "edge.example.net"},
+				{Name: "edge.example.net", Type: "CNAME", Data: "a1.edge.example.net"},
+				{Name: "a1.edge.example.net", Type: "A", Data: "93.184.216.34"},
+				{Name: "a1.edge.example.net", Type: "A", Data: "93.184.216.35"},
+				{Name: "a1.edge.example.net", Type: "AAAA", Data: "2606:2800:220:1::1"},
+			},
+		},
+		{
+			name:    "multiple addresses",
+			query:   "one.one.one.one",
+			results: "::ffff:1.1.1.1; ::ffff:1.0.0.1;",
+			want: []common.Answer{
+				{Name: "one.one.one.one", Type: "A", Data: "1.1.1.1"},
+				{Name: "one.one.one.one", Type: "A", Data: "1.0.0.1"},
+			},
+		},
+		{
+			name:    "other record types",
+			query:   "example.com",
+			results: "type: 15 10 mail.example.com;type: 65280 opaque;",
+			want: []common.Answer{
+				{Name: "example.com", Type: "MX", Data: "10 mail.example.com"},
+				{Name: "example.com", Type: "TYPE65280", Data: "opaque"},
+			},
+		},
+		{
+			name:    "unrecognised entries",
+			query:   "example.com",
+			results: "type: x y;type: 5;not-an-address;;",
+		},
+		{
+			name:  "empty",
+			query: "example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseQueryResults(tt.query, tt.results)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQueryResults() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDNSStatus(t *testing.T) {
+	tests := []struct {
+		status interface{}
+		want   string
+	}{
+		{float64(0), "succeeded"},
+		{9501, "ERROR(query record not found)"},
+		{"1460", "ERROR(query timeout)"},
+		{" 9003 ", "ERROR(DNS name does not exist)"},
+		{"87", "ERROR(87)"},
+		{"pending", ""},
+		{"", ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := getDNSStatus(tt.status); got != tt.want {
+			t.Errorf("getDNSStatus(%#v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestEventStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       map[string]interface{}
+		wantStatus string
+		wantRcode  string
+	}{
+		{name: "no status fields", data: map[string]interface{}{"QueryName": "example.com"}},
+		{name: "query status", data: map[string]interface{}{"QueryStatus": "0"}, wantStatus: "succeeded", wantRcode: "NOERROR"},
+		{name: "status only", data: map[string]interface{}{"Status": float64(9003)}, wantStatus: "ERROR(DNS name does not exist)", wantRcode: "NXDOMAIN"},
+		{
+			// 非数字的 Status 不覆盖有效的 QueryStatus
+			name:       "non-numeric status",
+			data:       map[string]interface{}{"QueryStatus": "9501", "Status": "unknown"},
+			wantStatus: "ERROR(query record not found)",
+			wantRcode:  "NOERROR",
+		},
+		{
+			name:       "response status overrides",
+			data:       map[string]interface{}{"QueryStatus": "0", "ResponseStatus": "9003"},
+			wantStatus: "ERROR(DNS name does not exist)",
+			wantRcode:  "NXDOMAIN",
+		},
+		{
+			// 超时等非协议层面的错误没有响应码
+			name:       "timeout",
+			data:       map[string]interface{}{"QueryStatus": "1460"},
+			wantStatus: "ERROR(query timeout)",
+		},
+		{name: "unrecognised only", data: map[string]interface{}{"Status": "", "ResponseStatus": "n/a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, rcode := eventStatus(tt.data)
+			if status != tt.wantStatus || rcode != tt.wantRcode {
+				t.Errorf("eventStatus() = %q, %q, want %q, %q", status, rcode, tt.wantStatus, tt.wantRcode)
+			}
+		})
+	}
+}
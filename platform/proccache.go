@@ -0,0 +1,99 @@
+package platform
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ProcessInfo 进程信息
+type ProcessInfo struct {
+	Name string
+	Path string
+	// 进程启动时间，未知时为零值
+	StartTime time.Time
+	// 父进程 ID 和完整命令行，权限不足等读取失败时为零值
+	ParentPID   uint32
+	CommandLine string
+	// 进程所在容器的 ID 和 Kubernetes Pod UID，主机进程为空（Linux）
+	ContainerID string
+	PodUID      string
+	// 进程所在网络命名空间的 inode，读取失败时为 0（Linux）
+	NetNS uint64
+}
+
+// 进程信息缓存的默认参数
+const (
+	defaultProcessCacheSize = 1024
+	defaultProcessCacheTTL  = 5 // 秒
+)
+
+// 按 PID 缓存进程信息的 LRU，避免同一进程的每次查询都读取 /proc 或调用 OpenProcess。
+// 条目在 ttl 后过期，PID 被复用时最多在 ttl 内返回旧进程的信息
+type processCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[uint32]*list.Element
+	order   *list.List // 最近使用的在前
+}
+
+type processCacheEntry struct {
+	pid     uint32
+	info    ProcessInfo
+	expires time.Time
+}
+
+var procCache = newProcessCache(defaultProcessCacheSize, defaultProcessCacheTTL*time.Second)
+
+// 创建进程信息缓存，size 为 0 时不缓存
+func newProcessCache(size int, ttl time.Duration) *processCache {
+	return &processCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[uint32]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// 按配置重建缓存
+func configureProcessCache(cfg ProcessCacheConfig) {
+	procCache = newProcessCache(cfg.Size, time.Duration(cfg.TTL)*time.Second)
+}
+
+// 返回缓存的进程信息，未命中或已过期时调用 load 读取。
+// load 在锁外执行，并发未命中同一 PID 时可能重复读取，但不会阻塞其他 PID 的查询
+func (c *processCache) get(pid uint32, load func(uint32) ProcessInfo) ProcessInfo {
+	if c.size <= 0 || c.ttl <= 0 {
+		return load(pid)
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if elem, ok := c.entries[pid]; ok {
+		entry := elem.Value.(*processCacheEntry)
+		if now.Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.info
+		}
+		c.order.Remove(elem)
+		delete(c.entries, pid)
+	}
+	c.mu.Unlock()
+
+	info := load(pid)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[pid]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[pid] = c.order.PushFront(&processCacheEntry{pid: pid, info: info, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*processCacheEntry).pid)
+	}
+	return info
+}
@@ -0,0 +1,61 @@
+package platform
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProcessCacheEviction(t *testing.T) {
+	loads := 0
+	load := func(pid uint32) ProcessInfo {
+		loads++
+		return ProcessInfo{ParentPID: pid}
+	}
+
+	c := newProcessCache(2, time.Minute)
+	c.get(1, load)
+	c.get(2, load)
+	c.get(1, load) // 命中，1 成为最近使用的条目
+	c.get(3, load) // 淘汰最久未使用的 2
+	if loads != 3 {
+		t.Fatalf("loads = %d, want 3", loads)
+	}
+	if info := c.get(1, load); info.ParentPID != 1 || loads != 3 {
+		t.Errorf("pid 1 should still be cached, loads = %d", loads)
+	}
+	if c.get(2, load); loads != 4 {
+		t.Errorf("pid 2 should have been evicted, loads = %d", loads)
+	}
+
+	// 过期的条目重新读取
+	c = newProcessCache(2, time.Nanosecond)
+	c.get(1, load)
+	time.Sleep(time.Millisecond)
+	if c.get(1, load); loads != 6 {
+		t.Errorf("expired entry should be reloaded, loads = %d", loads)
+	}
+}
+
+func BenchmarkProcCacheGet(b *testing.B) {
+	load := func(pid uint32) ProcessInfo {
+		return ProcessInfo{Name: "curl", Path: "/usr/bin/curl", ParentPID: pid}
+	}
+
+	b.Run("hit", func(b *testing.B) {
+		c := newProcessCache(defaultProcessCacheSize, time.Minute)
+		c.get(1234, load)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.get(1234, load)
+		}
+	})
+	// PID 数超过缓存容量，每次都未命中并淘汰最久未使用的条目
+	b.Run("miss", func(b *testing.B) {
+		c := newProcessCache(defaultProcessCacheSize, time.Minute)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.get(uint32(i%(2*defaultProcessCacheSize)), load)
+		}
+	})
+}
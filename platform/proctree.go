@@ -0,0 +1,129 @@
+package platform
+
+import (
+	"strings"
+	"sync"
+
+	"dnsflux/common"
+)
+
+// 沿父进程向上查找根进程的最大层数，防止 PID 复用造成的环
+const maxProcessTreeDepth = 64
+
+// 进程树成员表的容量上限，超出时清空已知不属于进程树的条目
+const maxProcessTreeEntries = 65536
+
+// 跟随进程树：只输出根进程及其子孙进程发起的查询。根进程由 PID 或进程名指定，
+// 平台能取得进程创建事件时实时记录新的子进程，否则沿父进程向上查找，
+// 此时父进程已退出的子进程会被过继给 init 而无法识别
+type processTree struct {
+	rootPID  uint32
+	rootName string
+
+	mu      sync.Mutex
+	members map[uint32]bool // PID 是否属于进程树，不在表中的需要向上查找
+}
+
+// 当前跟随的进程树，未启用时为 nil
+var followTree *processTree
+
+// 按配置创建进程树，未指定根进程时返回 nil
+func newProcessTree(cfg Config) *processTree {
+	if cfg.FollowPID == 0 && cfg.FollowProcess == "" {
+		return nil
+	}
+	t := &processTree{
+		rootPID:  cfg.FollowPID,
+		rootName: cfg.FollowProcess,
+		members:  make(map[uint32]bool),
+	}
+	if t.rootPID != 0 {
+		if info := procCache.get(t.rootPID, readProcessInfo); info.Name == "" {
+			common.Warnf("跟随的进程 %d 不存在或无法读取，只有之后以该 PID 启动的进程会被跟随", t.rootPID)
+		}
+		t.members[t.rootPID] = true
+	}
+	return t
+}
+
+// 判断进程是否为根进程
+func (t *processTree) isRoot(pid uint32, name string) bool {
+	return pid == t.rootPID || (t.rootName != "" && strings.EqualFold(name, t.rootName))
+}
+
+// contains 判断进程是否属于进程树，name 为该进程的进程名
+func (t *processTree) contains(pid uint32, name string) bool {
+	if t.isRoot(pid, name) {
+		t.set(pid, true)
+		return true
+	}
+	t.mu.Lock()
+	member, known := t.members[pid]
+	t.mu.Unlock()
+	if known {
+		return member
+	}
+
+	// 沿父进程向上查找，途经的进程一并记录结果
+	chain := []uint32{pid}
+	member = false
+	for p := pid; len(chain) < maxProcessTreeDepth; {
+		parent := procCache.get(p, readProcessInfo).ParentPID
+		if parent == 0 || parent == p {
+			break
+		}
+		t.mu.Lock()
+		parentMember, parentKnown := t.members[parent]
+		t.mu.Unlock()
+		if parentKnown {
+			member = parentMember
+			break
+		}
+		if t.isRoot(parent, procCache.get(parent, readProcessInfo).Name) {
+			member = true
+			break
+		}
+		chain = append(chain, parent)
+		p = parent
+	}
+	for _, p := range chain {
+		t.set(p, member)
+	}
+	return member
+}
+
+// 记录进程是否属于进程树。进程退出时不移除，其查询事件可能晚于退出才被处理；
+// 表满时先清空不属于进程树的条目，仍然满时只保留根进程
+func (t *processTree) set(pid uint32, member bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.members) >= maxProcessTreeEntries {
+		for p, m := range t.members {
+			if !m {
+				delete(t.members, p)
+			}
+		}
+		if len(t.members) >= maxProcessTreeEntries {
+			clear(t.members)
+			if t.rootPID != 0 {
+				t.members[t.rootPID] = true
+			}
+		}
+	}
+	t.members[pid] = member
+}
+
+// forked 处理进程创建事件：父进程属于进程树时子进程也属于，
+// 否则清除子进程 PID 的旧结果（来自已退出的同 PID 进程），之后按需向上查找
+func (t *processTree) forked(parent, child uint32) {
+	t.mu.Lock()
+	member := t.members[parent]
+	t.mu.Unlock()
+	if member {
+		t.set(child, true)
+		return
+	}
+	t.mu.Lock()
+	delete(t.members, child)
+	t.mu.Unlock()
+}
@@ -0,0 +1,103 @@
+package platform
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"time"
+
+	"dnsflux/common"
+
+	"golang.org/x/sys/unix"
+)
+
+// netlink 进程事件连接器（proc connector）的常量，见 linux/connector.h 和 linux/cn_proc.h
+const (
+	cnIdxProc         = 1
+	cnValProc         = 1
+	procCnMcastListen = 1
+	procEventFork     = 0x00000001
+
+	cnMsgLen = 20 // struct cn_msg 的长度
+)
+
+// 通过 proc connector 接收进程创建事件，实时维护进程树，ctx 取消时返回。
+// 订阅失败时只输出警告，进程树退回到沿父进程向上查找
+func watchForks(ctx context.Context, t *processTree) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_CONNECTOR)
+	if err != nil {
+		common.Warnf("订阅进程事件失败: %v，父进程已退出的子进程可能无法识别", err)
+		return
+	}
+	defer unix.Close(fd)
+	if err := subscribeProcEvents(fd); err != nil {
+		common.Warnf("订阅进程事件失败: %v，父进程已退出的子进程可能无法识别", err)
+		return
+	}
+	// 定期超时返回以检查 ctx
+	tv := unix.NsecToTimeval(time.Second.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		common.Warnf("设置进程事件接收超时失败: %v", err)
+		return
+	}
+
+	buf := make([]byte, 4096)
+	for ctx.Err() == nil {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			// ENOBUFS 表示事件过多而丢失，继续接收
+			if errors.Is(err, unix.ENOBUFS) {
+				common.Debugf("进程事件缓冲区溢出，部分子进程需向上查找")
+				continue
+			}
+			common.Warnf("接收进程事件失败: %v", err)
+			return
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, msg := range msgs {
+			handleProcEvent(msg.Data, t)
+		}
+	}
+}
+
+// 绑定进程事件组播组并请求内核开始发送
+func subscribeProcEvents(fd int) error {
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: cnIdxProc}); err != nil {
+		return err
+	}
+	ne := binary.NativeEndian
+	msg := make([]byte, unix.SizeofNlMsghdr+cnMsgLen+4)
+	ne.PutUint32(msg[0:], uint32(len(msg)))
+	ne.PutUint16(msg[4:], unix.NLMSG_DONE)
+	cn := msg[unix.SizeofNlMsghdr:]
+	ne.PutUint32(cn[0:], cnIdxProc)
+	ne.PutUint32(cn[4:], cnValProc)
+	ne.PutUint16(cn[16:], 4)
+	ne.PutUint32(cn[cnMsgLen:], procCnMcastListen)
+	return unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK})
+}
+
+// 解析 cn_msg 中的 proc_event，只处理进程（而非线程）的创建
+func handleProcEvent(data []byte, t *processTree) {
+	if len(data) < cnMsgLen+32 {
+		return
+	}
+	ne := binary.NativeEndian
+	ev := data[cnMsgLen:]
+	if ne.Uint32(ev[0:]) != procEventFork {
+		return
+	}
+	// proc_event: what、cpu、timestamp_ns 之后是 fork 事件的 parent_pid、parent_tgid、child_pid、child_tgid
+	body := ev[16:]
+	parentTgid, childPid, childTgid := ne.Uint32(body[4:]), ne.Uint32(body[8:]), ne.Uint32(body[12:])
+	if childPid == childTgid {
+		t.forked(parentTgid, childTgid)
+	}
+}
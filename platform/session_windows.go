@@ -0,0 +1,104 @@
+//go:build windows
+
+package platform
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/0xrawsec/golang-etw/etw"
+)
+
+// 可配置缓冲参数的实时 ETW 会话
+// golang-etw 自带的 RealTimeSession 不开放会话属性，这里直接调用其导出的 advapi32 封装
+type tunedSession struct {
+	properties    *etw.EventTraceProperties
+	sessionHandle syscall.Handle
+	traceName     string
+	providers     []etw.Provider
+}
+
+// 创建实时 ETW 会话并应用缓冲配置
+func newTunedSession(name string, buffers SessionBufferConfig) *tunedSession {
+	props := etw.NewRealTimeEventTraceSessionProperties(name)
+	if buffers.BufferSize != 0 {
+		props.BufferSize = buffers.BufferSize
+	}
+	props.MinimumBuffers = buffers.MinimumBuffers
+	props.MaximumBuffers = buffers.MaximumBuffers
+	props.FlushTimer = buffers.FlushTimer
+
+	return &tunedSession{
+		properties: props,
+		traceName:  name,
+	}
+}
+
+// 启动会话，同名会话已存在时先停止再重建
+func (s *tunedSession) start() error {
+	name, err := syscall.UTF16PtrFromString(s.traceName)
+	if err != nil {
+		return err
+	}
+
+	if err = etw.StartTrace(&s.sessionHandle, name, s.properties); err == etw.ERROR_ALREADY_EXISTS {
+		// ControlTrace 会改写属性结构，使用副本以免影响后续 StartTrace
+		prop := *s.properties
+		etw.ControlTrace(0, name, &prop, etw.EVENT_TRACE_CONTROL_STOP)
+		err = etw.StartTrace(&s.sessionHandle, name, s.properties)
+	}
+	return err
+}
+
+// EnableProvider 在会话上启用指定 Provider，会话未启动时自动启动
+func (s *tunedSession) EnableProvider(prov etw.Provider) error {
+	if s.sessionHandle == 0 {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+
+	guid, err := etw.ParseGUID(prov.GUID)
+	if err != nil {
+		return err
+	}
+
+	params := etw.EnableTraceParameters{Version: 2}
+	if len(prov.Filter) > 0 {
+		if fds := prov.BuildFilterDesc(); len(fds) > 0 {
+			params.EnableFilterDesc = (*etw.EventFilterDescriptor)(unsafe.Pointer(&fds[0]))
+			params.FilterDescCount = uint32(len(fds))
+		}
+	}
+
+	if err := etw.EnableTraceEx2(
+		s.sessionHandle,
+		guid,
+		etw.EVENT_CONTROL_CODE_ENABLE_PROVIDER,
+		prov.EnableLevel,
+		prov.MatchAnyKeyword,
+		prov.MatchAllKeyword,
+		0,
+		&params,
+	); err != nil {
+		return err
+	}
+
+	s.providers = append(s.providers, prov)
+	return nil
+}
+
+// TraceName 实现 etw.Session 接口
+func (s *tunedSession) TraceName() string {
+	return s.traceName
+}
+
+// Providers 实现 etw.Session 接口
+func (s *tunedSession) Providers() []etw.Provider {
+	return s.providers
+}
+
+// Stop 停止会话
+func (s *tunedSession) Stop() error {
+	return etw.ControlTrace(s.sessionHandle, nil, s.properties, etw.EVENT_TRACE_CONTROL_STOP)
+}
@@ -0,0 +1,94 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	// 内嵌 IANA 时区数据库（约 450KB），系统缺少 zoneinfo 时（scratch 容器、Windows Server Core）
+	// time.LoadLocation 回退到内嵌数据，配置的时区名称始终可以加载
+	_ "time/tzdata"
+)
+
+// 设置输出时区的环境变量，配置文件中的 timezone 字段优先
+const timezoneEnv = "DNSMONITOR_TZ"
+
+// 输出时间使用的时区，由 run 按配置设置
+var displayLocation = time.Local
+
+// 按名称加载时区：空或 Local 为系统本地时区，也可以是 IANA 名称（如 Asia/Shanghai）
+// 或固定偏移（如 +08:00、UTC+8、-0530）。系统时区数据库优先，缺失时使用内嵌的数据；
+// 名称无法识别时返回错误，而不是静默回退到 UTC
+func loadTimezone(name string) (*time.Location, error) {
+	switch name {
+	case "", "Local", "local":
+		return time.Local, nil
+	}
+	if loc, ok := parseUTCOffset(name); ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无法加载时区 %q: %v，应为 IANA 时区名称（如 Asia/Shanghai）或固定偏移（如 +08:00）", ErrConfig, name, err)
+	}
+	return loc, nil
+}
+
+// 解析固定偏移形式的时区：+08:00、-0530、+8、UTC+8、GMT-03:30
+func parseUTCOffset(s string) (*time.Location, bool) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(s, "UTC"), "GMT")
+	if rest == "" || (rest[0] != '+' && rest[0] != '-') {
+		return nil, false
+	}
+	sign := 1
+	if rest[0] == '-' {
+		sign = -1
+	}
+	rest = rest[1:]
+
+	hourText, minuteText, hasColon := strings.Cut(rest, ":")
+	if !hasColon && len(rest) == 4 {
+		hourText, minuteText = rest[:2], rest[2:]
+	}
+	hours, err := strconv.Atoi(hourText)
+	if err != nil || hours > 14 {
+		return nil, false
+	}
+	minutes := 0
+	if minuteText != "" {
+		if minutes, err = strconv.Atoi(minuteText); err != nil || minutes > 59 {
+			return nil, false
+		}
+	}
+	offset := sign * (hours*3600 + minutes*60)
+	return time.FixedZone(s, offset), true
+}
+
+// 设置输出时区
+func setTimezone(name string) error {
+	loc, err := loadTimezone(name)
+	if err != nil {
+		return err
+	}
+	displayLocation = loc
+	return nil
+}
+
+// 转换为输出时区的时间
+func displayTime(t time.Time) time.Time {
+	return t.In(displayLocation)
+}
+
+// 时区名称，用于启动信息
+func timezoneName(name string) string {
+	if name == "" {
+		return "Local"
+	}
+	return name
+}
+
+// 默认时区取自环境变量，未设置时为系统本地时区
+func defaultTimezone() string {
+	return os.Getenv(timezoneEnv)
+}
@@ -0,0 +1,274 @@
+package platform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 将 YAML 配置转换为 JSON，再按 JSON 配置合并。只支持配置文件用到的子集：
+// 块映射、块序列（含 "- key: value" 形式的映射项）、行内序列 [a, b]、
+// 引号字符串、整数（含 0x 十六进制）、浮点数、布尔值和 null，以及 # 注释
+func yamlToJSON(data []byte) ([]byte, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("第 %d 行: 不能使用 Tab 缩进", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return []byte("{}"), nil
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("第 %d 行: 缩进不正确", p.lines[p.pos].number)
+	}
+	return json.Marshal(value)
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// 去掉行中引号之外的 # 注释
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// 解析缩进为 indent 的块，根据第一行判断是序列还是映射
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := &p.lines[p.pos]
+		if line.indent < indent || !isYAMLSequenceItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("第 %d 行: 缩进不正确", line.number)
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		switch {
+		case rest == "":
+			// 值在下一行，缩进更深
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			value, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		case isYAMLMappingEntry(rest):
+			// "- key: value"：把该行视为从 "- " 之后开始的映射的第一行
+			line.indent += len(line.text) - len(rest)
+			line.text = rest
+			value, err := p.parseMapping(line.indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		default:
+			value, err := parseYAMLScalar(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			p.pos++
+		}
+	}
+	return items, nil
+}
+
+// 是否为 key: value 形式（冒号后为空白或行尾，且不在引号或行内序列中）
+func isYAMLMappingEntry(text string) bool {
+	_, _, ok := cutYAMLKey(text)
+	return ok
+}
+
+func cutYAMLKey(text string) (key, value string, ok bool) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") || strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func (p *yamlParser) parseMapping(indent int) (any, error) {
+	fields := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("第 %d 行: 缩进不正确", line.number)
+		}
+		key, rest, ok := cutYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("第 %d 行: 应为 key: value 形式", line.number)
+		}
+		if _, dup := fields[key]; dup {
+			return nil, fmt.Errorf("第 %d 行: 重复的键 %q", line.number, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLScalar(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = value
+			continue
+		}
+
+		// 值在后续行：缩进更深的块，或与键同级的序列
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLSequenceItem(next.text)) {
+				value, err := p.parseBlock(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				fields[key] = value
+				continue
+			}
+		}
+		fields[key] = nil
+	}
+	return fields, nil
+}
+
+// 解析标量或行内序列
+func parseYAMLScalar(text string, number int) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("第 %d 行: 行内序列缺少 ]", number)
+		}
+		items := []any{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			value, err := parseYAMLScalar(part, number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("第 %d 行: 不支持行内映射，请改用缩进形式", number)
+	case strings.HasPrefix(text, "\""):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: 无效的字符串 %s", number, text)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("第 %d 行: 无效的字符串 %s", number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+
+	switch text {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		if n, err := strconv.ParseUint(text[2:], 16, 64); err == nil {
+			return json.Number(strconv.FormatUint(n, 10)), nil
+		}
+	}
+	if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return json.Number(text), nil
+	}
+	if _, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return json.Number(text), nil
+	}
+	if strings.Trim(text, "0123456789.eE+-") == "" {
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return text, nil
+		}
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+// 按引号之外的逗号拆分行内序列
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, text[start:])
+}